@@ -0,0 +1,195 @@
+package GoMybatis
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/zhuxiujia/GoMybatis/tx"
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//批量执行的语句
+type BatchStatement struct {
+	Sql    string
+	Args   []interface{}
+	Result *Result //Flush 后写入执行结果
+}
+
+//批量执行session（类似JDBC的BatchExecutor）
+//ExecPrepare 只把语句加入队列并返回一个待填充的 *Result，Flush()/Commit() 时在同一个事务中依次执行（相同sql文本只预编译一次），
+//执行后每条语句的结果写回对应的 *Result，也可通过 Results() 获取。
+//每条语句仍是一次数据库往返（database/sql 没有通用的批量接口，多语句拼接依赖驱动且无法返回每条语句的影响行数），暂不支持单次往返执行
+type BatchSession struct {
+	*LocalSession
+
+	statements []*BatchStatement //等待执行的语句
+	results    []*Result         //最近一次Flush的结果
+}
+
+func (it BatchSession) New(session *LocalSession) BatchSession {
+	it.LocalSession = session
+	it.statements = []*BatchStatement{}
+	return it
+}
+
+//加入批量队列，返回的 *Result 在 Flush 之后才有值
+func (it *BatchSession) ExecPrepare(sqlPrepare string, args ...interface{}) (*Result, error) {
+	if it.isClosed == true {
+		return nil, utils.NewError("BatchSession", " can not Exec() a Closed Session!")
+	}
+	var statement = &BatchStatement{
+		Sql:    sqlPrepare,
+		Args:   args,
+		Result: &Result{},
+	}
+	it.statements = append(it.statements, statement)
+	return statement.Result, nil
+}
+
+//未执行的语句数量
+func (it *BatchSession) Pending() int {
+	return len(it.statements)
+}
+
+//最近一次Flush的执行结果，顺序与加入队列的顺序一致
+func (it *BatchSession) Results() []*Result {
+	return it.results
+}
+
+//执行队列中的全部语句。当前已开启事务则在该事务中执行，否则开启一个新事务，全部成功才提交。
+//执行失败时：新开启的事务回滚，队列保持不变（可再次 Flush 重试，或 Rollback/Close 丢弃）；
+//在已开启的事务中时，已执行的语句移出队列，失败及之后的语句保留在队列中，其 *Result 不会被填充
+func (it *BatchSession) Flush() ([]*Result, error) {
+	if it.isClosed == true {
+		return nil, utils.NewError("BatchSession", " can not Flush() a Closed Session!")
+	}
+	if len(it.statements) == 0 {
+		return nil, nil
+	}
+	var statements = it.statements
+
+	var t, _ = it.txStack.Last()
+	var ownTx = t == nil
+	if ownTx {
+		var err error
+		t, err = it.db.Begin()
+		err = it.dbErrorPack(err)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var stmtMap = make(map[string]*sql.Stmt)
+	defer func() {
+		for _, stmt := range stmtMap {
+			stmt.Close()
+		}
+	}()
+	if it.logSystem != nil {
+		it.logSystem.Println([]byte("[GoMybatis] [" + it.Id() + "] Flush batch statements:" + strconv.Itoa(len(statements))))
+	}
+	var results = make([]*Result, 0, len(statements))
+	var fail = func(executed int, err error) ([]*Result, error) {
+		if ownTx {
+			t.Rollback()
+			//事务已回滚，已写入的结果作废
+			for _, result := range results {
+				*result = Result{}
+			}
+		} else {
+			it.statements = statements[executed:]
+		}
+		return nil, err
+	}
+	for i, statement := range statements {
+		var stmt = stmtMap[statement.Sql]
+		if stmt == nil {
+			var err error
+			stmt, err = t.Prepare(statement.Sql)
+			err = it.dbErrorPack(err)
+			if err != nil {
+				return fail(i, err)
+			}
+			stmtMap[statement.Sql] = stmt
+		}
+		var result, err = stmt.Exec(statement.Args...)
+		err = it.dbErrorPack(err)
+		if err != nil {
+			return fail(i, err)
+		}
+		statement.Result.LastInsertId, _ = result.LastInsertId()
+		statement.Result.RowsAffected, _ = result.RowsAffected()
+		results = append(results, statement.Result)
+	}
+	if ownTx {
+		var err = it.dbErrorPack(t.Commit())
+		if err != nil {
+			return fail(len(statements), err)
+		}
+	}
+	it.statements = []*BatchStatement{}
+	it.results = results
+	return results, nil
+}
+
+//查询前先执行队列中的语句，保证能读到之前的写入
+func (it *BatchSession) Query(sqlorArgs string) ([]map[string][]byte, error) {
+	if _, err := it.Flush(); err != nil {
+		return nil, err
+	}
+	return it.LocalSession.Query(sqlorArgs)
+}
+
+func (it *BatchSession) QueryNew(sqlorArgs string) (*sql.Rows, error) {
+	if _, err := it.Flush(); err != nil {
+		return nil, err
+	}
+	return it.LocalSession.QueryNew(sqlorArgs)
+}
+
+func (it *BatchSession) QueryPrepare(sqlPrepare string, args ...interface{}) ([]map[string][]byte, error) {
+	if _, err := it.Flush(); err != nil {
+		return nil, err
+	}
+	return it.LocalSession.QueryPrepare(sqlPrepare, args...)
+}
+
+func (it *BatchSession) QueryPrepareNew(sqlPrepare string, args ...interface{}) (*sql.Rows, error) {
+	if _, err := it.Flush(); err != nil {
+		return nil, err
+	}
+	return it.LocalSession.QueryPrepareNew(sqlPrepare, args...)
+}
+
+func (it *BatchSession) Exec(sqlorArgs string) (*Result, error) {
+	if _, err := it.Flush(); err != nil {
+		return nil, err
+	}
+	return it.LocalSession.Exec(sqlorArgs)
+}
+
+func (it *BatchSession) Begin(p *tx.Propagation) error {
+	if _, err := it.Flush(); err != nil {
+		return err
+	}
+	return it.LocalSession.Begin(p)
+}
+
+//提交前先执行队列中的语句，执行结果可通过 Results() 获取
+func (it *BatchSession) Commit() error {
+	if _, err := it.Flush(); err != nil {
+		return err
+	}
+	return it.LocalSession.Commit()
+}
+
+//回滚时丢弃未执行的语句
+func (it *BatchSession) Rollback() error {
+	it.statements = []*BatchStatement{}
+	return it.LocalSession.Rollback()
+}
+
+//关闭时丢弃未执行的语句
+func (it *BatchSession) Close() {
+	it.statements = []*BatchStatement{}
+	it.LocalSession.Close()
+}
@@ -0,0 +1,164 @@
+package GoMybatis
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zhuxiujia/GoMybatis/example"
+)
+
+type BatchTestMapper struct {
+	UpdateName func(session *Session, id string, name string) (int64, error) `mapperParams:"session,id,name"`
+}
+
+func TestBatchSession_Commit(t *testing.T) {
+	resetTestDriverState("TestBatchSession_Commit")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	if _, err := engine.Open(TestDriverName, "TestBatchSession_Commit"); err != nil {
+		t.Fatal(err)
+	}
+	var state = testDriverState("TestBatchSession_Commit")
+	var session, err = engine.SessionFactory().NewSession("", SessionType_Batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	var batch = session.(*BatchSession)
+
+	var results []*Result
+	for i := 0; i < 3; i++ {
+		var r, err = session.ExecPrepare("update biz_activity set name = ? where id = ?", "name", i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, r)
+	}
+	r, err := session.ExecPrepare("delete from biz_activity where id = ?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results = append(results, r)
+	if batch.Pending() != 4 || len(state.Execs) != 0 {
+		t.Fatal("ExecPrepare must be queued until flush")
+	}
+
+	if err = session.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Execs) != 4 {
+		t.Fatal("commit must flush all statements,execs =", len(state.Execs))
+	}
+	if state.Prepares != 2 {
+		t.Fatal("statements must be prepared once per sql text,prepares =", state.Prepares)
+	}
+	if state.Begins != 1 || state.Commits != 1 {
+		t.Fatal("batch must run in a single tx,begins =", state.Begins, "commits =", state.Commits)
+	}
+	if len(batch.Results()) != 4 {
+		t.Fatal("Results() must report every statement")
+	}
+	for _, item := range results {
+		if item.RowsAffected != 1 {
+			t.Fatal("queued result not filled after flush")
+		}
+	}
+}
+
+func TestBatchSession_Mapper(t *testing.T) {
+	resetTestDriverState("TestBatchSession_Mapper")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	if _, err := engine.Open(TestDriverName, "TestBatchSession_Mapper"); err != nil {
+		t.Fatal(err)
+	}
+	var state = testDriverState("TestBatchSession_Mapper")
+	var mapper BatchTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <update id="updateName">update biz_activity set name = #{name} where id = #{id}</update>
+</mapper>`))
+	var session, err = engine.SessionFactory().NewSession("", SessionType_Batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	var acts = []example.Activity{{Id: "1", Name: "a"}, {Id: "2", Name: "b"}}
+	for _, act := range acts {
+		if _, err := mapper.UpdateName(&session, act.Id, act.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(state.Execs) != 0 {
+		t.Fatal("mapper exec must be queued")
+	}
+	results, err := session.(*BatchSession).Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || len(state.Execs) != 2 || state.Prepares != 1 {
+		t.Fatal("flush fail,results =", len(results), "execs =", len(state.Execs), "prepares =", state.Prepares)
+	}
+	if state.ClosedStmts != state.Prepares {
+		t.Fatal("prepared stmt must be closed after flush")
+	}
+}
+
+func TestBatchSession_FlushFail(t *testing.T) {
+	resetTestDriverState("TestBatchSession_FlushFail")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	if _, err := engine.Open(TestDriverName, "TestBatchSession_FlushFail"); err != nil {
+		t.Fatal(err)
+	}
+	var state = testDriverState("TestBatchSession_FlushFail")
+	var session, err = engine.SessionFactory().NewSession("", SessionType_Batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	var batch = session.(*BatchSession)
+
+	var results []*Result
+	for i := 0; i < 2; i++ {
+		var r, _ = session.ExecPrepare("update biz_activity set name = ? where id = ?", "name", i)
+		results = append(results, r)
+	}
+	state.lock(func() { state.ExecErr = errors.New("exec fail") })
+	if _, err := batch.Flush(); err == nil {
+		t.Fatal("flush must return exec error")
+	}
+	if batch.Pending() != 2 || state.Rollbacks != 1 {
+		t.Fatal("failed flush must rollback and keep the queue,pending =", batch.Pending())
+	}
+	state.lock(func() { state.ExecErr = nil })
+	if _, err := batch.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if batch.Pending() != 0 || len(state.Execs) != 2 || results[1].RowsAffected != 1 {
+		t.Fatal("retry flush must execute the kept statements")
+	}
+}
+
+//返回非 *LocalSession 的路由
+type captureSessionRouter struct {
+	GoMybatisDataSourceRouter
+}
+
+func (it *captureSessionRouter) Router(mapperName string, engine SessionEngine) (Session, error) {
+	return &CaptureSession{}, nil
+}
+
+func TestBatchSession_NotLocalSession(t *testing.T) {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var router = captureSessionRouter{GoMybatisDataSourceRouter{}.New(nil)}
+	engine.SetDataSourceRouter(&router)
+	var factory = SessionFactory{}.New(&engine)
+	if _, err := factory.NewSession("", SessionType_Batch); err == nil {
+		t.Fatal("batch session of not *LocalSession must fail")
+	}
+	if session, err := factory.NewSession("", SessionType_Default); err != nil || session == nil {
+		t.Fatal("default session fail:", err)
+	}
+}
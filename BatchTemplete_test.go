@@ -0,0 +1,107 @@
+package GoMybatis
+
+import (
+	"testing"
+
+	"github.com/zhuxiujia/GoMybatis/example"
+)
+
+type BatchTempleteTestMapper struct {
+	UpdateBatch     func(session *Session, args []example.Activity) (int64, error) `mapperParams:"session,args"`
+	UpdateStatement func(session *Session, args []example.Activity) (int64, error) `mapperParams:"session,args"`
+	DeleteByIds     func(session *Session, ids []string) (int64, error)            `mapperParams:"session,ids"`
+	DeleteBatch     func(session *Session, args []example.Activity) (int64, error) `mapperParams:"session,args"`
+}
+
+func newBatchTempleteTestMapper() BatchTempleteTestMapper {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper BatchTempleteTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <resultMap id="BaseResultMap" tables="biz_activity">
+        <id column="id" property="id"/>
+        <result column="name" property="name" langType="string"/>
+        <result column="remark" property="remark" langType="string"/>
+        <result column="version" property="version" langType="int" version_enable="true"/>
+        <result column="delete_flag" property="delete_flag" langType="int" logic_enable="true" logic_undelete="1" logic_deleted="0"/>
+    </resultMap>
+    <updateTemplete id="updateBatch" sets="name?name = #{name}"/>
+    <updateTemplete id="updateStatement" batch="statements"/>
+    <deleteTemplete id="deleteByIds"/>
+    <deleteTemplete id="deleteBatch"/>
+</mapper>`))
+	return mapper
+}
+
+func TestBatchTemplete_UpdateCase(t *testing.T) {
+	var mapper = newBatchTempleteTestMapper()
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	var acts = []example.Activity{{Id: "1", Name: "a", Version: 1}, {Id: "2", Name: "b", Version: 5}}
+	if _, err := mapper.UpdateBatch(&session, acts); err != nil {
+		t.Fatal(err)
+	}
+	var expect = "update biz_activity set name = case when id = ? then ? when id = ? then ? else name end,version = version + 1 " +
+		"where delete_flag = 1 and ((id = ? and version = ?) or (id = ? and version = ?))"
+	if compactSql(capture.LastSql()) != compactSql(expect) {
+		t.Fatal("batch update fail:", capture.LastSql())
+	}
+	var args = capture.LastArgs()
+	if len(args) != 8 || args[1] != "a" || args[5] != 1 || args[7] != 5 {
+		t.Fatal("batch update args fail:", args)
+	}
+}
+
+func TestBatchTemplete_UpdateStatements(t *testing.T) {
+	var mapper = newBatchTempleteTestMapper()
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	var acts = []example.Activity{{Id: "1", Name: "a", Remark: "r1"}, {Id: "2", Name: "b", Remark: "r2"}}
+	if _, err := mapper.UpdateStatement(&session, acts); err != nil {
+		t.Fatal(err)
+	}
+	var row = "update biz_activity set name = ?,remark = ?,version = version + 1 where delete_flag = 1 and id = ? and version = ?"
+	if compactSql(capture.LastSql()) != compactSql(row+";"+row) {
+		t.Fatal("batch update statements fail:", capture.LastSql())
+	}
+	if len(capture.LastArgs()) != 8 {
+		t.Fatal("batch update args fail:", capture.LastArgs())
+	}
+}
+
+func TestBatchTemplete_Delete(t *testing.T) {
+	var mapper = newBatchTempleteTestMapper()
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	if _, err := mapper.DeleteByIds(&session, []string{"1", "2", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	var expect = "update biz_activity set delete_flag = 0 where delete_flag = 1 and id in (?,?,?)"
+	if compactSql(capture.LastSql()) != compactSql(expect) || len(capture.LastArgs()) != 3 {
+		t.Fatal("batch delete fail:", capture.LastSql(), capture.LastArgs())
+	}
+
+	if _, err := mapper.DeleteBatch(&session, []example.Activity{{Id: "4"}, {Id: "5"}}); err != nil {
+		t.Fatal(err)
+	}
+	if compactSql(capture.LastSql()) != compactSql("update biz_activity set delete_flag = 0 where delete_flag = 1 and id in (?,?)") ||
+		capture.LastArgs()[1] != "5" {
+		t.Fatal("batch delete by struct fail:", capture.LastSql(), capture.LastArgs())
+	}
+}
+
+func TestBatchTemplete_Empty(t *testing.T) {
+	var mapper = newBatchTempleteTestMapper()
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	if n, err := mapper.UpdateBatch(&session, []example.Activity{}); err != nil || n != 0 {
+		t.Fatal("empty batch update must return 0 rows,", n, err)
+	}
+	if n, err := mapper.DeleteByIds(&session, nil); err != nil || n != 0 {
+		t.Fatal("empty batch delete must return 0 rows,", n, err)
+	}
+	if capture.LastSql() != "" {
+		t.Fatal("empty batch must not execute sql:", capture.LastSql())
+	}
+}
@@ -0,0 +1,17 @@
+//go:build go1.17
+// +build go1.17
+
+package GoMybatis
+
+import (
+	"database/sql"
+	"database/sql/driver"
+)
+
+//关闭并丢弃连接（不归还连接池），Raw返回 driver.ErrBadConn 时 database/sql 关闭该连接
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(driverConn interface{}) error {
+		return driver.ErrBadConn
+	})
+	conn.Close()
+}
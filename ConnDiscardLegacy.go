@@ -0,0 +1,13 @@
+//go:build !go1.17
+// +build !go1.17
+
+package GoMybatis
+
+import (
+	"database/sql"
+)
+
+//go1.17以下 sql.Conn 不支持 Raw，无法丢弃连接，只能归还连接池
+func discardConn(conn *sql.Conn) {
+	conn.Close()
+}
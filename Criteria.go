@@ -0,0 +1,216 @@
+package GoMybatis
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//运行期查询条件，作为 selectTemplete、deleteTemplete 方法的参数，条件与 wheres、逻辑删除条件以 and 合并。例如
+//
+//	SelectByCriteria func(criteria GoMybatis.Criteria) ([]Activity, error) `mapperParams:"criteria"`
+//	<selectTemplete id="selectByCriteria"/>
+//
+//	mapper.SelectByCriteria(GoMybatis.Criteria{}.New().Eq("name", "a").Or(GoMybatis.IsNull("remark"), GoMybatis.Like("remark", "%a%")).OrderBy("create_time desc"))
+//
+//每个方法返回新的Criteria，原Criteria不变，可安全复用
+type Criteria struct {
+	conditions []Condition
+	orders     []string
+}
+
+func (it Criteria) New() Criteria {
+	return Criteria{}
+}
+
+//追加条件，条件之间为 and
+func (it Criteria) Where(conditions ...Condition) Criteria {
+	it.conditions = append(it.conditions[:len(it.conditions):len(it.conditions)], conditions...)
+	return it
+}
+
+func (it Criteria) Eq(column string, value interface{}) Criteria {
+	return it.Where(Eq(column, value))
+}
+
+func (it Criteria) Ne(column string, value interface{}) Criteria {
+	return it.Where(Ne(column, value))
+}
+
+func (it Criteria) Gt(column string, value interface{}) Criteria {
+	return it.Where(Gt(column, value))
+}
+
+func (it Criteria) Ge(column string, value interface{}) Criteria {
+	return it.Where(Ge(column, value))
+}
+
+func (it Criteria) Lt(column string, value interface{}) Criteria {
+	return it.Where(Lt(column, value))
+}
+
+func (it Criteria) Le(column string, value interface{}) Criteria {
+	return it.Where(Le(column, value))
+}
+
+func (it Criteria) Like(column string, pattern interface{}) Criteria {
+	return it.Where(Like(column, pattern))
+}
+
+func (it Criteria) In(column string, values ...interface{}) Criteria {
+	return it.Where(In(column, values...))
+}
+
+func (it Criteria) NotIn(column string, values ...interface{}) Criteria {
+	return it.Where(NotIn(column, values...))
+}
+
+func (it Criteria) Between(column string, from interface{}, to interface{}) Criteria {
+	return it.Where(Between(column, from, to))
+}
+
+func (it Criteria) IsNull(column string) Criteria {
+	return it.Where(IsNull(column))
+}
+
+func (it Criteria) IsNotNull(column string) Criteria {
+	return it.Where(IsNotNull(column))
+}
+
+//or 条件组：(c1 or c2 ...)
+func (it Criteria) Or(conditions ...Condition) Criteria {
+	return it.Where(Or(conditions...))
+}
+
+//排序，例如 OrderBy("create_time desc", "id")，只对 selectTemplete 有效
+func (it Criteria) OrderBy(columns ...string) Criteria {
+	it.orders = append(it.orders[:len(it.orders):len(it.orders)], columns...)
+	return it
+}
+
+//构建where条件（以 " and " 开头，由<where>去除多余的and），参数追加到args
+func (it Criteria) buildWheres(args *[]interface{}) (string, error) {
+	var writer = &sqlWriter{args: args}
+	var wheres = []string{}
+	for _, condition := range it.conditions {
+		var where, err = condition.build(writer)
+		if err != nil {
+			return "", err
+		}
+		if where != "" {
+			wheres = append(wheres, where)
+		}
+	}
+	if len(wheres) == 0 {
+		return "", nil
+	}
+	return " and " + strings.Join(wheres, " and "), nil
+}
+
+//<#criteria name="参数名" part="where|orderBy" required="true"/>，只由模板生成，执行期输出Criteria参数的条件或排序。
+//required:条件不能为空（没有其他where条件的deleteTemplete，避免误删全表）
+type criteriaNode struct {
+	name     string
+	part     string
+	required bool
+}
+
+func init() {
+	ast.RegisterNode(Element_Criteria, func(element *etree.Element, childs []ast.Node, holder *ast.NodeConfigHolder) (ast.Node, error) {
+		var name = element.SelectAttrValue("name", "")
+		if name == "" {
+			return nil, utils.NewError("Criteria", "<"+Element_Criteria+"> attribute 'name' can not be empty!")
+		}
+		return &criteriaNode{name: name, part: element.SelectAttrValue("part", "where"), required: element.SelectAttrValue("required", "") == "true"}, nil
+	})
+}
+
+func (it *criteriaNode) Type() ast.NodeType {
+	return ast.NCustom
+}
+
+func (it *criteriaNode) Eval(env map[string]interface{}, arg_array *[]interface{}) ([]byte, error) {
+	var criteria Criteria
+	switch v := env[it.name].(type) {
+	case Criteria:
+		criteria = v
+	case *Criteria:
+		if v != nil {
+			criteria = *v
+		}
+	case nil:
+	default:
+		return nil, utils.NewError("Criteria", "arg '", it.name, "' must be a GoMybatis.Criteria, but got ", reflect.TypeOf(v).String())
+	}
+	var sql string
+	var err error
+	if it.part == "orderBy" {
+		sql, err = buildOrderBy(criteria.orders)
+	} else {
+		sql, err = criteria.buildWheres(arg_array)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if it.required && sql == "" {
+		return nil, utils.NewError("Criteria", "arg '", it.name, "' conditions can not be empty!")
+	}
+	return []byte(sql), nil
+}
+
+var criteriaType = reflect.TypeOf(Criteria{})
+
+//在模板的<where>中加入Criteria条件，select时追加排序
+func appendCriteriaElement(mapper *etree.Element, name string, required bool) {
+	var whereRoot = mapper.Child[len(mapper.Child)-1].(*etree.Element)
+	var where = whereRoot.CreateElement(Element_Criteria)
+	where.CreateAttr("name", name)
+	if required {
+		where.CreateAttr("required", "true")
+	}
+	if mapper.Tag == Element_Select {
+		var orderBy = mapper.CreateElement(Element_Criteria)
+		orderBy.CreateAttr("name", name)
+		orderBy.CreateAttr("part", "orderBy")
+	}
+}
+
+//模板生成的Criteria排序与语句中的 <orderBy> 不能同时使用（会生成两个 order by）
+func checkCriteriaOrderBy(statement *etree.Element) {
+	var criteriaOrderBy = false
+	for _, child := range statement.ChildElements() {
+		if child.Tag == Element_Criteria && child.SelectAttrValue("part", "") == "orderBy" {
+			criteriaOrderBy = true
+		}
+	}
+	if criteriaOrderBy && len(statement.FindElements(".//"+Element_OrderBy)) != 0 {
+		panic(utils.NewError("GoMybatisTempleteDecoder", "<selectTemplete> with Criteria arg can not contain <"+Element_OrderBy+">, use Criteria.OrderBy() instead! mapper id = ", statement.SelectAttrValue("id", "")))
+	}
+}
+
+//查找方法的Criteria参数名，没有返回""
+func findCriteriaArgName(method *reflect.StructField) string {
+	if method == nil {
+		return ""
+	}
+	var mapperParams = method.Tag.Get("mapperParams")
+	var params = strings.Split(mapperParams, ",")
+	for i := 0; i < method.Type.NumIn(); i++ {
+		var argType = method.Type.In(i)
+		if argType.Kind() == reflect.Ptr {
+			argType = argType.Elem()
+		}
+		if argType != criteriaType {
+			continue
+		}
+		if mapperParams != "" && i < len(params) && params[i] != "" {
+			return params[i]
+		}
+		return DefaultOneArg + strconv.Itoa(i)
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+package GoMybatis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type CriteriaTestMapper struct {
+	SelectByCriteria func(session *Session, criteria Criteria) ([]map[string]string, error)               `mapperParams:"session,criteria"`
+	SelectByName     func(session *Session, name string, criteria *Criteria) ([]map[string]string, error) `mapperParams:"session,name,criteria"`
+	DeleteByCriteria func(session *Session, criteria Criteria) (int64, error)                             `mapperParams:"session,criteria"`
+}
+
+func newCriteriaTestMapper() CriteriaTestMapper {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper CriteriaTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <resultMap id="BaseResultMap" tables="biz_activity">
+        <id column="id" property="id"/>
+        <result column="name" property="name" langType="string"/>
+        <result column="delete_flag" property="delete_flag" langType="int" logic_enable="true" logic_undelete="1" logic_deleted="0"/>
+    </resultMap>
+    <selectTemplete id="selectByCriteria"/>
+    <selectTemplete id="selectByName" wheres="name?name = #{name}"/>
+    <deleteTemplete id="deleteByCriteria"/>
+</mapper>`))
+	return mapper
+}
+
+func TestCriteria_SelectTemplete(t *testing.T) {
+	var mapper = newCriteriaTestMapper()
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	var criteria = Criteria{}.New().Eq("name", "a").In("id", []string{"1", "2"}).Or(IsNull("remark"), Like("remark", "%a%")).OrderBy("id desc")
+	mapper.SelectByCriteria(&session, criteria)
+	var expect = "select * from biz_activity where delete_flag = 1 and name = ? and id in (?,?) and (remark is null or remark like ?) order by id desc"
+	if compactSql(capture.LastSql()) != compactSql(expect) {
+		t.Fatal("criteria select fail:", capture.LastSql())
+	}
+	if args := capture.LastArgs(); len(args) != 4 || args[0] != "a" || args[3] != "%a%" {
+		t.Fatal("criteria select args fail:", args)
+	}
+
+	//与wheres合并，nil Criteria只保留原有条件
+	mapper.SelectByName(&session, "b", nil)
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity where delete_flag = 1 and name = ?") {
+		t.Fatal("nil criteria fail:", capture.LastSql())
+	}
+	var between = Criteria{}.New().Between("version", 1, 3)
+	mapper.SelectByName(&session, "b", &between)
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity where delete_flag = 1 and name = ? and version between ? and ?") {
+		t.Fatal("criteria merge wheres fail:", capture.LastSql())
+	}
+	if args := capture.LastArgs(); len(args) != 3 || args[0] != "b" || args[2] != 3 {
+		t.Fatal("criteria merge wheres args fail:", args)
+	}
+
+	//列名校验
+	if _, err := mapper.SelectByCriteria(&session, Criteria{}.New().Eq("1=1 or name", 1)); err == nil {
+		t.Fatal("illegal column must fail")
+	}
+}
+
+func TestCriteria_DeleteTemplete(t *testing.T) {
+	var mapper = newCriteriaTestMapper()
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	if _, err := mapper.DeleteByCriteria(&session, Criteria{}.New().Lt("version", 3)); err != nil {
+		t.Fatal(err)
+	}
+	if compactSql(capture.LastSql()) != compactSql("update biz_activity set delete_flag = 0 where delete_flag = 1 and version < ?") {
+		t.Fatal("criteria logic delete fail:", capture.LastSql())
+	}
+	//没有条件时不允许删除全表
+	if _, err := mapper.DeleteByCriteria(&session, Criteria{}.New()); err == nil {
+		t.Fatal("empty criteria delete must fail")
+	}
+}
+
+func TestCriteria_OrderByConflict(t *testing.T) {
+	defer func() {
+		if e := recover(); e == nil || !strings.Contains(fmt.Sprint(e), "Criteria.OrderBy()") {
+			t.Fatal("Criteria arg with <orderBy> must panic,", e)
+		}
+	}()
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper CriteriaTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <resultMap id="BaseResultMap" tables="biz_activity">
+        <id column="id" property="id"/>
+        <result column="name" property="name" langType="string"/>
+    </resultMap>
+    <selectTemplete id="selectByCriteria"><orderBy param="sort"/></selectTemplete>
+    <selectTemplete id="selectByName"/>
+    <deleteTemplete id="deleteByCriteria"/>
+</mapper>`))
+}
+
+func TestCriteria_Internal(t *testing.T) {
+	defer func() {
+		if e := recover(); e == nil {
+			t.Fatal("criteria element in mapper xml must panic")
+		}
+	}()
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper CriteriaTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectByCriteria">select * from biz_activity <where><criteria name="criteria"/></where></select>
+    <select id="selectByName">select * from biz_activity</select>
+    <delete id="deleteByCriteria">delete from biz_activity</delete>
+</mapper>`))
+}
@@ -1,6 +1,7 @@
 package GoMybatis
 
 import (
+	"context"
 	"database/sql"
 )
 
@@ -15,3 +16,48 @@ type DataSourceRouter interface {
 
 	Name() string
 }
+
+//可列出数据源的路由，见 GoMybatisEngine.DataSourceStats
+type DataSourceLister interface {
+	//map[数据源名称]*sql.DB
+	DataSources() map[string]*sql.DB
+}
+
+//持有后台任务（例如 HealthChecker）的路由，GoMybatisEngine.Shutdown 在关闭连接池之前调用 Stop
+type StoppableRouter interface {
+	Stop()
+}
+
+//按语句路由的请求
+type RouterRequest struct {
+	MapperName  string          //mapper包名+名称，同 DataSourceRouter.Router
+	StatementId string          //mapper xml中语句的id
+	ReadOnly    bool            //<select>、<selectTemplete>语句，且不在事务中
+	ForceMaster bool            //强制使用主库，见 WithForceMaster、mapper方法tag forceMaster:"true"
+	DataSource  string          //mapper tag datasource:"" 指定的数据源名称，见 GoMybatisDataSourceRouter
+	Context     context.Context //mapper方法参数中的context.Context，没有为nil
+}
+
+//按语句路由的数据源路由（例如读写分离），执行mapper方法新建session时优先使用。事务session仍由 Router 创建（tag datasource 指定数据源时除外）
+type StatementDataSourceRouter interface {
+	DataSourceRouter
+	RouterStatement(request RouterRequest, engine SessionEngine) (Session, error)
+}
+
+//分片数据源路由，见 ShardingDataSourceRouter
+type ShardingRouter interface {
+	DataSourceRouter
+	//构建sql使用的databaseId
+	DatabaseId() string
+	//计算sql涉及的分片，sql中没有分片表返回nil
+	Shards(sql string, paramMap map[string]interface{}) ([]Shard, error)
+	//分片的session
+	RouterShard(shard Shard, engine SessionEngine) (Session, error)
+}
+
+//多租户数据源路由，见 TenantDataSourceRouter
+type TenantRouter interface {
+	StatementDataSourceRouter
+	//解析ctx中的租户
+	ResolveTenant(ctx context.Context) (string, error)
+}
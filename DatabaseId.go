@@ -0,0 +1,176 @@
+package GoMybatis
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//多数据库厂商支持（databaseId）。语句和<sql>可按数据库定义多个同id的版本，执行期按session的驱动选择，没有匹配的使用不带databaseId的版本，例如
+//
+//	<select id="selectPage">select * from biz_activity limit #{size} offset #{offset}</select>
+//	<select id="selectPage" databaseId="sqlserver">select * from biz_activity order by id offset #{offset} rows fetch next #{size} rows only</select>
+//
+//<if>、<where>、<when>等元素也可带 databaseId，表达式中可使用 _databaseId 变量（见 ast.DatabaseIdVar）
+
+//提供databaseId的session，LocalSession按驱动名称返回
+type DatabaseIdSession interface {
+	DatabaseId() string
+}
+
+//驱动名称转为databaseId，已知驱动返回方言名称（见 DialectOf），例如 sqlite3 返回 sqlite，其余返回小写的驱动名称
+func DatabaseIdOf(driver string) string {
+	if dialect := DialectOf(driver); dialect != "" {
+		return dialect
+	}
+	return strings.ToLower(strings.TrimSpace(driver))
+}
+
+//session的databaseId（见 DatabaseIdOf），未实现 DatabaseIdSession 返回""
+func sessionDatabaseId(session Session) string {
+	if s, ok := session.(DatabaseIdSession); ok {
+		return DatabaseIdOf(s.DatabaseId())
+	}
+	return ""
+}
+
+//元素及子元素的 databaseId 属性转为 DatabaseIdOf 的结果，例如 sqlite3 与 sqlite、postgresql 与 postgres 相同
+func normalizeDatabaseIds(element *etree.Element) {
+	if attr := element.SelectAttr(ast.DatabaseIdAttr); attr != nil {
+		attr.Value = DatabaseIdOf(attr.Value)
+	}
+	for _, child := range element.ChildElements() {
+		normalizeDatabaseIds(child)
+	}
+}
+
+//没有匹配的databaseId版本且没有默认版本时返回错误
+type databaseIdMismatchNode struct {
+	elementId   string
+	databaseIds string
+}
+
+func init() {
+	ast.RegisterNode(Element_DatabaseIdMismatch, func(element *etree.Element, childs []ast.Node, holder *ast.NodeConfigHolder) (ast.Node, error) {
+		return &databaseIdMismatchNode{elementId: element.SelectAttrValue(ID, ""), databaseIds: element.SelectAttrValue("databaseIds", "")}, nil
+	})
+}
+
+func (it *databaseIdMismatchNode) Type() ast.NodeType {
+	return ast.NCustom
+}
+
+func (it *databaseIdMismatchNode) Eval(env map[string]interface{}, arg_array *[]interface{}) ([]byte, error) {
+	var databaseId, _ = env[ast.DatabaseIdVar].(string)
+	return nil, utils.NewError("GoMybatisSqlBuilder", "[GoMybatis] element '"+it.elementId+"' has no version for "+ast.DatabaseIdVar+"='"+databaseId+"' (defined: "+it.databaseIds+") and no default version without "+ast.DatabaseIdAttr+"!")
+}
+
+//合并同id的各数据库版本为一个元素：<choose><when test="true" databaseId="mysql">...</when><otherwise>默认版本</otherwise></choose>，
+//没有默认版本时<otherwise>返回错误（见 databaseIdMismatchNode）。各版本除 databaseId 外的属性（resultMap、resultType等）必须相同
+func mergeDatabaseIdElements(elements []*etree.Element) (*etree.Element, error) {
+	var first = elements[0]
+	var base *etree.Element
+	var variants = []*etree.Element{}
+	var databaseIds = map[string]bool{}
+	for _, element := range elements {
+		if element.Tag != Element_Sql && (!isMethodElement(element.Tag) || strings.HasSuffix(element.Tag, "Templete")) {
+			return nil, &xmlElementError{element: element, message: "[GoMybatis] xml <" + element.Tag + "> not support attribute '" + ast.DatabaseIdAttr + "'"}
+		}
+		if element.Tag != first.Tag {
+			return nil, &xmlElementError{element: element, message: "[GoMybatis] xml element Id can not repeat with different element <" + first.Tag + "> and <" + element.Tag + ">! elementId=" + first.SelectAttrValue(ID, "")}
+		}
+		if key := differentAttr(first, element); key != "" {
+			return nil, &xmlElementError{element: element, message: "[GoMybatis] xml element attribute '" + key + "' must be the same in all " + ast.DatabaseIdAttr + " versions! elementId=" + first.SelectAttrValue(ID, "")}
+		}
+		var databaseId = DatabaseIdOf(element.SelectAttrValue(ast.DatabaseIdAttr, ""))
+		if databaseId == "" {
+			if base != nil {
+				return nil, &xmlElementError{element: element, message: "[GoMybatis] element Id can not repeat in xml! elementId=" + element.SelectAttrValue(ID, "")}
+			}
+			base = element
+			continue
+		}
+		if databaseIds[databaseId] {
+			return nil, &xmlElementError{element: element, message: "[GoMybatis] element Id can not repeat in xml with same " + ast.DatabaseIdAttr + "=\"" + databaseId + "\"! elementId=" + element.SelectAttrValue(ID, "")}
+		}
+		databaseIds[databaseId] = true
+		variants = append(variants, element)
+	}
+	if base != nil {
+		first = base
+	}
+	var merged = etree.NewElement(first.Tag)
+	for _, attr := range first.Attr {
+		if attr.Key != ast.DatabaseIdAttr {
+			merged.Attr = append(merged.Attr, attr)
+		}
+	}
+	var choose = merged.CreateElement(Element_choose)
+	var names = make([]string, 0, len(variants))
+	for _, variant := range variants {
+		var databaseId = DatabaseIdOf(variant.SelectAttrValue(ast.DatabaseIdAttr, ""))
+		var when = choose.CreateElement(Element_when)
+		when.CreateAttr("test", "true")
+		when.CreateAttr(ast.DatabaseIdAttr, databaseId)
+		moveChilds(variant, when)
+		names = append(names, databaseId)
+	}
+	if base != nil {
+		moveChilds(base, choose.CreateElement(Element_otherwise))
+	} else {
+		var mismatch = choose.CreateElement(Element_otherwise).CreateElement(Element_DatabaseIdMismatch)
+		mismatch.CreateAttr(ID, first.SelectAttrValue(ID, ""))
+		mismatch.CreateAttr("databaseIds", strings.Join(names, ","))
+	}
+	return merged, nil
+}
+
+//两个版本中不同的属性名（忽略 databaseId），相同返回""
+func differentAttr(a *etree.Element, b *etree.Element) string {
+	var attrs = func(element *etree.Element) map[string]string {
+		var m = make(map[string]string, len(element.Attr))
+		for _, attr := range element.Attr {
+			if attr.Key != ast.DatabaseIdAttr && !ast.IsPositionAttr(attr) {
+				m[attr.Key] = attr.Value
+			}
+		}
+		return m
+	}
+	var attrsA, attrsB = attrs(a), attrs(b)
+	var keys = []string{}
+	for k, v := range attrsA {
+		if value, ok := attrsB[k]; !ok || value != v {
+			keys = append(keys, k)
+		}
+	}
+	for k := range attrsB {
+		if _, ok := attrsA[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	return keys[0]
+}
+
+func moveChilds(from *etree.Element, to *etree.Element) {
+	//AddChild会从原元素中移除child，先复制
+	for _, child := range append([]etree.Token{}, from.Child...) {
+		to.AddChild(child)
+	}
+}
+
+//同id元素中是否有带 databaseId 的版本
+func hasDatabaseId(elements []*etree.Element) bool {
+	for _, element := range elements {
+		if element.SelectAttrValue(ast.DatabaseIdAttr, "") != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package GoMybatis
+
+import (
+	"strings"
+	"testing"
+)
+
+type DatabaseIdTestMapper struct {
+	SelectPage func(session *Session, size int) ([]map[string]string, error)    `mapperParams:"session,size"`
+	SelectName func(session *Session, name string) ([]map[string]string, error) `mapperParams:"session,name"`
+	UpdateName func(name string) (int64, error)                                 `mapperParams:"name"`
+}
+
+const databaseIdTestXml = `<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <sql id="table">biz_activity</sql>
+    <sql id="table" databaseId="sqlite">main.biz_activity</sql>
+    <select id="selectPage">select * from <include refid="table"/> limit #{size}</select>
+    <select id="selectPage" databaseId="sqlserver">select top ${size} * from <include refid="table"/></select>
+    <select id="selectName">select * from <include refid="table"/>
+        <where>
+            <if test="name != nil" databaseId="mysql">name like concat('%',#{name},'%')</if>
+            <if test="name != nil and _databaseId == 'sqlite'">name like '%' || #{name} || '%'</if>
+        </where>
+    </select>
+    <update id="updateName" databaseId="gomybatis_test">update biz_activity set name = #{name}</update>
+</mapper>`
+
+type databaseIdCaptureSession struct {
+	CaptureSession
+	databaseId string
+}
+
+func (it *databaseIdCaptureSession) DatabaseId() string {
+	return it.databaseId
+}
+
+func TestDatabaseId(t *testing.T) {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper DatabaseIdTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(databaseIdTestXml))
+
+	for databaseId, expect := range map[string]string{
+		"mysql":     "select * from biz_activity limit ?",
+		"sqlite":    "select * from main.biz_activity limit ?",
+		"sqlserver": "select top 10 * from biz_activity",
+		"":          "select * from biz_activity limit ?",
+	} {
+		var capture = databaseIdCaptureSession{databaseId: databaseId}
+		var session = Session(&capture)
+		mapper.SelectPage(&session, 10)
+		if compactSql(capture.LastSql()) != compactSql(expect) {
+			t.Fatal("databaseId '"+databaseId+"' statement fail:", capture.LastSql())
+		}
+	}
+	for databaseId, expect := range map[string]string{
+		"mysql":  "select * from biz_activity where name like concat('%',?,'%')",
+		"sqlite": "select * from main.biz_activity where name like '%' || ? || '%'",
+		"":       "select * from biz_activity",
+	} {
+		var capture = databaseIdCaptureSession{databaseId: databaseId}
+		var session = Session(&capture)
+		mapper.SelectName(&session, "a")
+		if compactSql(capture.LastSql()) != compactSql(expect) {
+			t.Fatal("databaseId '"+databaseId+"' element fail:", capture.LastSql())
+		}
+	}
+}
+
+func TestDatabaseId_Router(t *testing.T) {
+	resetTestDriverState("TestDatabaseId_Router")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	if _, err := engine.Open(TestDriverName, "TestDatabaseId_Router"); err != nil {
+		t.Fatal(err)
+	}
+	var mapper DatabaseIdTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(databaseIdTestXml))
+	//databaseId由路由选择的session驱动确定
+	if _, err := mapper.UpdateName("a"); err != nil {
+		t.Fatal(err)
+	}
+	var execs = testDriverState("TestDatabaseId_Router").Execs
+	if len(execs) != 1 || compactSql(execs[0]) != compactSql("update biz_activity set name = ?") {
+		t.Fatal("databaseId router fail:", execs)
+	}
+}
+
+func TestDatabaseId_Repeat(t *testing.T) {
+	for _, xml := range []string{
+		`<mapper><select id="a" databaseId="mysql">select 1</select><select id="a" databaseId="MySQL">select 2</select></mapper>`,
+		`<mapper><select id="a">select 1</select><select id="a" databaseId="mysql">select 2</select><select id="a">select 3</select></mapper>`,
+		`<mapper><select id="a">select 1</select><update id="a" databaseId="mysql">update t set a = 1</update></mapper>`,
+		`<mapper><selectTemplete id="a" databaseId="mysql"/></mapper>`,
+		`<mapper><select id="a" resultMap="A">select 1</select><select id="a" databaseId="mysql" resultMap="B">select 2</select></mapper>`,
+		`<mapper><select id="a">select 1</select><select id="a" databaseId="mysql" resultType="int">select 2</select></mapper>`,
+	} {
+		func() {
+			defer func() {
+				var e = recover()
+				if e == nil || !strings.Contains(e.(error).Error(), "[GoMybatis]") {
+					t.Fatal("xml must fail:", xml, e)
+				}
+			}()
+			LoadMapperXml([]byte(xml))
+		}()
+	}
+}
+
+func TestDatabaseId_Normalize(t *testing.T) {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper DatabaseIdTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectPage" databaseId="sqlite3">select * from biz_activity limit #{size}</select>
+    <select id="selectPage" databaseId="postgresql">select * from biz_activity fetch first #{size} rows only</select>
+    <select id="selectName">select * from biz_activity<where><if test="name != nil" databaseId="PostgreSQL">name ilike #{name}</if></where></select>
+    <update id="updateName">update biz_activity set name = #{name}</update>
+</mapper>`))
+	//xml与session的databaseId均按 DatabaseIdOf 转换，sqlite3 与 sqlite、postgresql 与 pgx 相同
+	for databaseId, expect := range map[string]string{
+		"sqlite": "select * from biz_activity limit ?",
+		"pgx":    "select * from biz_activity fetch first ? rows only",
+	} {
+		var capture = databaseIdCaptureSession{databaseId: databaseId}
+		var session = Session(&capture)
+		if _, err := mapper.SelectPage(&session, 10); err != errCaptureQuery {
+			t.Fatal(err)
+		}
+		if compactSql(capture.LastSql()) != compactSql(expect) {
+			t.Fatal("databaseId '"+databaseId+"' statement fail:", capture.LastSql())
+		}
+	}
+	var capture = databaseIdCaptureSession{databaseId: "postgres"}
+	var session = Session(&capture)
+	mapper.SelectName(&session, "a")
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity where name ilike ?") {
+		t.Fatal("databaseId element fail:", capture.LastSql())
+	}
+
+	//没有匹配的版本且没有默认版本返回错误
+	capture = databaseIdCaptureSession{databaseId: "mysql"}
+	session = Session(&capture)
+	var _, err = mapper.SelectPage(&session, 10)
+	if err == nil || !strings.Contains(err.Error(), "selectPage") || !strings.Contains(err.Error(), "mysql") {
+		t.Fatal("databaseId mismatch must fail:", err)
+	}
+	if len(capture.Sqls) != 0 {
+		t.Fatal("databaseId mismatch must not execute:", capture.Sqls)
+	}
+}
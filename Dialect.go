@@ -0,0 +1,48 @@
+package GoMybatis
+
+import "strings"
+
+//数据库方言，模板按方言生成不同的sql
+type Dialect = string
+
+const (
+	Dialect_MySQL     Dialect = "mysql"
+	Dialect_Postgres  Dialect = "postgres"
+	Dialect_SQLite    Dialect = "sqlite"
+	Dialect_SQLServer Dialect = "sqlserver"
+)
+
+//按方言名称或驱动名称获取方言，未知返回""
+func DialectOf(name string) Dialect {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "mysql", "mariadb", "tidb":
+		return Dialect_MySQL
+	case "postgres", "postgresql", "pgx", "pq", "cockroach":
+		return Dialect_Postgres
+	case "sqlite", "sqlite3":
+		return Dialect_SQLite
+	case "sqlserver", "mssql":
+		return Dialect_SQLServer
+	}
+	return ""
+}
+
+//按方言给标识符加引号，例如 mysql: `a`.`name`，postgres、sqlite: "a"."name"，sqlserver: [a].[name]。未知方言原样返回
+func QuoteIdentifier(dialect Dialect, name string) string {
+	var left, right string
+	switch dialect {
+	case Dialect_MySQL:
+		left, right = "`", "`"
+	case Dialect_Postgres, Dialect_SQLite:
+		left, right = `"`, `"`
+	case Dialect_SQLServer:
+		left, right = "[", "]"
+	default:
+		return name
+	}
+	var parts = strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = left + part + right
+	}
+	return strings.Join(parts, ".")
+}
@@ -16,6 +16,7 @@ const (
 	Element_Delete_Templete ElementType = "deleteTemplete"
 	Element_Update_Templete ElementType = `updateTemplete`
 	Element_Select_Templete ElementType = "selectTemplete"
+	Element_Upsert_Templete ElementType = "upsertTemplete"
 
 	//child elements
 	Element_bind      ElementType = "bind"
@@ -29,12 +30,16 @@ const (
 	Element_otherwise ElementType = "otherwise"
 	Element_where     ElementType = "where"
 	Element_Include   ElementType = "include"
+	Element_Criteria  ElementType = "#criteria" //只由模板生成（不是合法的xml元素名，mapper xml中无法使用），见 Criteria
+	Element_OrderBy   ElementType = "orderBy"  //见 orderByNode
+
+	Element_DatabaseIdMismatch ElementType = "#databaseIdMismatch" //只由 databaseId 合并生成，没有匹配的版本时返回错误，见 mergeDatabaseIdElements
 )
 
 func isMethodElement(tag ElementType) bool {
 	switch tag {
 	case Element_Insert, Element_Delete, Element_Update, Element_Select,
-		Element_Insert_Templete, Element_Delete_Templete, Element_Update_Templete, Element_Select_Templete:
+		Element_Insert_Templete, Element_Delete_Templete, Element_Update_Templete, Element_Select_Templete, Element_Upsert_Templete:
 		return true
 	}
 	return false
@@ -0,0 +1,106 @@
+package GoMybatis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//关闭时检查执行中的语句、事务是否结束的间隔
+const shutdownPollInterval = 10 * time.Millisecond
+
+//引擎生命周期，记录执行中的语句和事务，见 GoMybatisEngine.Shutdown
+type engineLifecycle struct {
+	mutex    sync.Mutex
+	shutdown bool
+	active   int //执行中的mapper方法、AOP事务数量
+}
+
+//登记一个执行中的语句或事务
+func (it *engineLifecycle) begin() {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.active++
+}
+
+func (it *engineLifecycle) end() {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.active--
+}
+
+//开始关闭，已关闭返回false
+func (it *engineLifecycle) beginShutdown() bool {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	if it.shutdown {
+		return false
+	}
+	it.shutdown = true
+	return true
+}
+
+func (it *engineLifecycle) isShutdown() bool {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	return it.shutdown
+}
+
+//等待执行中的语句、事务结束且idle()返回true，ctx结束时返回ctx.Err()
+func (it *engineLifecycle) wait(ctx context.Context, idle func() bool) error {
+	var ticker = time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		it.mutex.Lock()
+		var active = it.active
+		it.mutex.Unlock()
+		if active <= 0 && idle() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+//可关闭的引擎（GoMybatisEngine），执行期登记执行中的语句、事务
+type lifecycleEngine interface {
+	lifecycle() *engineLifecycle
+}
+
+//登记执行中的语句或事务，返回结束时调用的函数；引擎不支持关闭时不登记
+func trackActive(sessionEngine SessionEngine) func() {
+	var engine, ok = sessionEngine.(lifecycleEngine)
+	if !ok || engine.lifecycle() == nil {
+		return func() {}
+	}
+	var lifecycle = engine.lifecycle()
+	lifecycle.begin()
+	return lifecycle.end
+}
+
+//引擎关闭后不再创建新session
+func checkAcceptSession(sessionEngine SessionEngine) error {
+	var engine, ok = sessionEngine.(lifecycleEngine)
+	if ok && engine.lifecycle() != nil && engine.lifecycle().isShutdown() {
+		return utils.NewError("GoMybatisEngine", "engine is shutdown, can not create new session!")
+	}
+	return nil
+}
+
+//SessionFactory中是否还有未关闭的session
+func sessionMapEmpty(factory *SessionFactory) bool {
+	if factory == nil {
+		return true
+	}
+	var empty = true
+	factory.SessionMap.Range(func(key, value interface{}) bool {
+		empty = false
+		return false
+	})
+	return empty
+}
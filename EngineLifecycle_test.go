@@ -0,0 +1,128 @@
+package GoMybatis
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhuxiujia/GoMybatis/tx"
+)
+
+type ShutdownTestMapper struct {
+	SelectAll func() ([]map[string]string, error)
+	SelectTx  func(session *Session) ([]map[string]string, error) `mapperParams:"session"`
+}
+
+func newShutdownTestEngine(t *testing.T, dsn string) (*GoMybatisEngine, ShutdownTestMapper) {
+	resetTestDriverState(dsn)
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	if _, err := engine.Open(TestDriverName, dsn); err != nil {
+		t.Fatal(err)
+	}
+	var mapper ShutdownTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectAll">select * from biz_activity</select>
+    <select id="selectTx">select * from biz_activity</select>
+</mapper>`))
+	return &engine, mapper
+}
+
+func TestGoMybatisEngine_Shutdown(t *testing.T) {
+	var engine, mapper = newShutdownTestEngine(t, "TestShutdown")
+	var session, err = engine.SessionFactory().NewSession("", SessionType_Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var propagation = tx.PROPAGATION_REQUIRED
+	if err := session.Begin(&propagation); err != nil {
+		t.Fatal(err)
+	}
+	var done = make(chan error)
+	go func() {
+		done <- engine.Shutdown(context.Background())
+	}()
+	for !engine.lifecycle().isShutdown() {
+		time.Sleep(time.Millisecond)
+	}
+	//不再创建新session，执行中的事务可以继续
+	if _, err := mapper.SelectAll(); err == nil {
+		t.Fatal("new session after shutdown must fail")
+	}
+	if _, err := mapper.SelectTx(&session); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		t.Fatal("shutdown must wait session close:", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	session.Commit()
+	session.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.DataSourceRouter().(DataSourceLister).DataSources()["default"].Ping(); err == nil {
+		t.Fatal("shutdown must close pool")
+	}
+	if err := engine.Shutdown(context.Background()); err == nil {
+		t.Fatal("shutdown twice must fail")
+	}
+}
+
+func TestGoMybatisEngine_ShutdownTimeout(t *testing.T) {
+	var engine, _ = newShutdownTestEngine(t, "TestShutdownTimeout")
+	var session, err = engine.SessionFactory().NewSession("", SessionType_Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var propagation = tx.PROPAGATION_REQUIRED
+	if err := session.Begin(&propagation); err != nil {
+		t.Fatal(err)
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := engine.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatal("shutdown timeout must return ctx error:", err)
+	}
+	//超时后关闭剩余的session，回滚事务
+	var state = testDriverState("TestShutdownTimeout")
+	if !sessionMapEmpty(engine.SessionFactory()) || state.Rollbacks != 1 {
+		t.Fatal("shutdown timeout must close sessions:", state.Rollbacks)
+	}
+}
+
+func TestGoMybatisEngine_ShutdownStopHealthChecker(t *testing.T) {
+	resetTestDriverState("TestShutdownHealth")
+	var router = GoMybatisDataSourceRouter{}.New(nil)
+	var db, _ = openPool(TestDriverName, "TestShutdownHealth", PoolOptions{})
+	router.RegisterDataSource("default", TestDriverName, "TestShutdownHealth", db)
+	var checker = HealthChecker{}.New(&router)
+	checker.SetInterval(5 * time.Millisecond)
+	var mutex sync.Mutex
+	var events = []HealthEvent{}
+	checker.OnChange(func(event HealthEvent) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, event)
+	})
+	router.SetHealthChecker(&checker)
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	engine.SetDataSourceRouter(&router)
+	if err := checker.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	//Shutdown后健康检查已停止，不会ping已关闭的连接池
+	time.Sleep(30 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(events) != 0 {
+		t.Fatal("health checker must stop before close data sources:", events)
+	}
+}
@@ -0,0 +1,111 @@
+package GoMybatis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+	"github.com/zhuxiujia/GoMybatis/engines"
+	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
+)
+
+type forEachTestQuery struct {
+	Ids   []string
+	Roles map[string][]int
+}
+
+type forEachTestIterator struct {
+	items []string
+}
+
+func (it *forEachTestIterator) Next() (interface{}, bool) {
+	if len(it.items) == 0 {
+		return nil, false
+	}
+	var item = it.items[0]
+	it.items = it.items[1:]
+	return item, true
+}
+
+func evalForEachTest(xml string, env map[string]interface{}) (string, []interface{}, error) {
+	var builder = GoMybatisSqlBuilder{}.New(GoMybatisSqlArgTypeConvert{}, ExpressionEngineProxy{}.New(&engines.ExpressionEngineGoExpress{}, true), &LogStandard{}, false)
+	var mapperTree = LoadMapperXml([]byte(`<?xml version="1.0" encoding="UTF-8"?><mapper><select id="select">` + xml + `</select></mapper>`))
+	var nodes = builder.nodeParser.Parser(mapperTree["select"].(*etree.Element).Child)
+	var args = []interface{}{}
+	var sql, err = ast.DoChildNodes(nodes, env, &args)
+	return compactSql(strings.Replace(string(sql), ast.SQLPlaceholder, "?", -1)), args, err
+}
+
+func TestForEach_Expression(t *testing.T) {
+	var query = forEachTestQuery{Ids: []string{"1", "2"}}
+	var sql, args, err = evalForEachTest(`<foreach collection="query.Ids" item="id" separator="," open="(" close=")">#{id}</foreach>`, map[string]interface{}{"query": &query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sql != "(?,?)" || len(args) != 2 || args[1] != "2" {
+		t.Fatal("foreach expression fail:", sql, args)
+	}
+	//空集合不输出
+	sql, _, err = evalForEachTest(`<foreach collection="query.Ids" item="id" open="(" close=")">#{id}</foreach>`, map[string]interface{}{"query": &forEachTestQuery{}})
+	if err != nil || sql != "" {
+		t.Fatal("foreach empty fail:", sql, err)
+	}
+	//collection拼写错误或为nil返回错误
+	_, _, err = evalForEachTest(`<foreach collection="query.Idz" item="id">#{id}</foreach>`, map[string]interface{}{"query": &query})
+	if err == nil || !strings.Contains(err.Error(), `collection="query.Idz"`) || !strings.Contains(err.Error(), "at line 1") {
+		t.Fatal("foreach misspelled collection must fail:", err)
+	}
+	_, _, err = evalForEachTest(`<foreach collection="ids" item="id">#{id}</foreach>`, map[string]interface{}{"ids": nil})
+	if err == nil || !strings.Contains(err.Error(), `collection="ids"`) {
+		t.Fatal("foreach nil collection must fail:", err)
+	}
+}
+
+func TestForEach_ArrayChanIterator(t *testing.T) {
+	var sql, _, err = evalForEachTest(`<foreach collection="ids" separator=",">${item}</foreach>`, map[string]interface{}{"ids": [3]int{3, 2, 1}})
+	if err != nil || sql != "3,2,1" {
+		t.Fatal("foreach array fail:", sql, err)
+	}
+	var ch = make(chan string, 2)
+	ch <- "a"
+	ch <- "b"
+	close(ch)
+	sql, _, err = evalForEachTest(`<foreach collection="ids" separator=",">${index}${item}</foreach>`, map[string]interface{}{"ids": ch})
+	if err != nil || sql != "0a,1b" {
+		t.Fatal("foreach chan fail:", sql, err)
+	}
+	sql, _, err = evalForEachTest(`<foreach collection="ids" separator=",">${item}</foreach>`, map[string]interface{}{"ids": &forEachTestIterator{items: []string{"x", "y"}}})
+	if err != nil || sql != "x,y" {
+		t.Fatal("foreach iterator fail:", sql, err)
+	}
+	//不支持的类型返回错误
+	if _, _, err = evalForEachTest(`<foreach collection="ids">${item}</foreach>`, map[string]interface{}{"ids": 1}); err == nil {
+		t.Fatal("foreach int must fail")
+	}
+	if _, _, err = evalForEachTest(`<foreach collection="">${item}</foreach>`, map[string]interface{}{}); err == nil {
+		t.Fatal("foreach empty collection must fail")
+	}
+}
+
+func TestForEach_MapSortedAndNested(t *testing.T) {
+	var query = forEachTestQuery{Roles: map[string][]int{"c": {5}, "a": {1, 2}, "b": {3}}}
+	var env = map[string]interface{}{"query": query, "item": "outer"}
+	var sql, _, err = evalForEachTest(`<foreach collection="query.Roles" index="name" item="ids" separator="or">(${name}<foreach collection="ids" separator="" open=":">${item}</foreach>)</foreach>${item}`, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sql != "(a:12)or(b:3)or(c:5)outer" {
+		t.Fatal("foreach map nested fail:", sql)
+	}
+	if _, ok := env["ids"]; ok {
+		t.Fatal("foreach item must be removed after loop")
+	}
+	if _, ok := env["index"]; ok {
+		t.Fatal("foreach index must be removed after loop")
+	}
+	//int key按数值排序
+	sql, _, err = evalForEachTest(`<foreach collection="ids" separator=",">${index}</foreach>`, map[string]interface{}{"ids": map[int]string{10: "a", 9: "b", 100: "c"}})
+	if err != nil || sql != "9,10,100" {
+		t.Fatal("foreach int key fail:", sql, err)
+	}
+}
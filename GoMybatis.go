@@ -2,10 +2,12 @@ package GoMybatis
 
 import (
 	"bytes"
+	"context"
 	"log"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/zhuxiujia/GoMybatis/ast"
 	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
@@ -17,6 +19,12 @@ const NewSessionFunc = "NewSession" //NewSession method,auto write implement bod
 type Mapper struct {
 	xml   *etree.Element
 	nodes []ast.Node
+
+	idProperties []*ResultProperty //插入前需要生成的主键属性
+	tenant       TenantMode        //租户处理方式
+	sensitive    map[string]bool   //方法参数结构体中 gm:"sensitive" 的属性名，日志中脱敏
+
+	batchCollection string //批量模板的切片参数名，为空切片时不执行
 }
 
 //推荐默认使用单例传入
@@ -51,21 +59,57 @@ func WriteMapperPtrByEngine(ptr interface{}, xmls [][]byte, sessionEngine Sessio
 //func的结构体参数无需指定mapperParams的tag，框架会自动扫描它的属性，封装为map处理掉
 //使用WriteMapper函数设置代理后即可正常使用。
 func WriteMapper(bean reflect.Value, xmls [][]byte, sessionEngine SessionEngine) {
+	var files = make([]MapperFile, len(xmls))
+	for i, xml := range xmls {
+		files[i] = MapperFile{Data: xml}
+	}
+	WriteMapperFiles(bean, files, sessionEngine)
+}
+
+//同 WriteMapper，MapperFile.Name 会出现在解析错误与元素位置信息中
+func WriteMapperFiles(bean reflect.Value, files []MapperFile, sessionEngine SessionEngine) {
 	beanCheck(bean)
 	var mapperTree = make(map[string]etree.Token)
-	for _, xml := range xmls {
-		for k, v := range LoadMapperXmlNew(xml,false) {
+	//include refid查找表，<mapper namespace="">中的元素以 namespace.id 注册，可跨xml引用
+	var includeRefs = newIncludeRefs()
+	for _, file := range files {
+		var items, namespace = loadMapperXml(file.Data, file.Name)
+		for k, v := range items {
+			var element = v.(*etree.Element)
+			var registered = includeRefs.add(namespace, k, element)
+			if old := mapperTree[k]; old != nil {
+				//不同namespace中的同id <sql> 片段通过 namespace.id 区分，其余元素id不能跨xml重复
+				if !registered || element.Tag != Element_Sql || old.(*etree.Element).Tag != Element_Sql {
+					panic("[GoMybatis] element Id can not repeat in xmls! elementId=" + k + xmlPositionString(element))
+				}
+				continue
+			}
 			mapperTree[k] = v
 		}
 	}
-	processIncludeElement(&mapperTree)
+	//方法tag定义的sql
+	var tagItems, err = loadMapperTags(bean.Type())
+	if err != nil {
+		panic(err)
+	}
+	for name, element := range tagItems {
+		if xmlElement := findMapperXml(mapperTree, name); xmlElement != nil {
+			panic("[GoMybatis] method " + bean.Type().String() + "." + name + "() define sql both in struct tag and xml" + xmlPositionString(xmlElement) + "!")
+		}
+		mapperTree[name] = element
+	}
+	if err := processIncludeElementByRefs(mapperTree, includeRefs); err != nil {
+		panic(err)
+	}
 
 	sessionEngine.TempleteDecoder().DecodeTree(mapperTree, bean.Type())
 	//构建期使用的map，无需考虑并发安全
-	var methodXmlMap = makeMethodXmlMap(bean, mapperTree, sessionEngine.SqlBuilder())
 	var resultMaps = makeResultMaps(mapperTree)
+	resolveOrderByColumns(mapperTree, resultMaps)
+	var methodXmlMap = makeMethodXmlMap(bean, mapperTree, sessionEngine)
 	var returnTypeMap = makeReturnTypeMap(bean.Elem().Type())
 	var beanName = bean.Type().PkgPath() + bean.Type().String()
+	var mapperDataSource = findMapperDataSource(bean.Elem().Type())
 
 	ProxyValue(bean, func(funcField reflect.StructField, field reflect.Value) func(arg ProxyArg) []reflect.Value {
 		//构建期
@@ -76,6 +120,17 @@ func WriteMapper(bean reflect.Value, xmls [][]byte, sessionEngine SessionEngine)
 		}
 		//mapper
 		var mapper = methodXmlMap[funcName]
+		var request = RouterRequest{
+			MapperName:  beanName,
+			ForceMaster: funcField.Tag.Get("forceMaster") == "true",
+			DataSource:  funcField.Tag.Get(DataSourceTag),
+		}
+		if request.DataSource == "" {
+			request.DataSource = mapperDataSource
+		}
+		if request.DataSource != "" {
+			checkDataSourceTag(sessionEngine, request.DataSource, beanName+"."+funcName)
+		}
 		//resultMaps
 		var resultMap map[string]*ResultProperty
 
@@ -84,6 +139,12 @@ func WriteMapper(bean reflect.Value, xmls [][]byte, sessionEngine SessionEngine)
 			if resultMapId != "" {
 				resultMap = resultMaps[resultMapId]
 			}
+			if mapper.xml.Tag == Element_Insert {
+				mapper.idProperties = findIdGeneratorProperties(resultMap)
+			}
+			mapper.tenant = engineTenantFilter(sessionEngine).statementMode(mapper.xml)
+			mapper.batchCollection = mapper.xml.SelectAttrValue(batchCollectionAttr, "")
+			request.StatementId = mapper.xml.SelectAttrValue("id", "")
 		}
 
 		//执行期
@@ -101,10 +162,20 @@ func WriteMapper(bean reflect.Value, xmls [][]byte, sessionEngine SessionEngine)
 					}
 					returnValue = &returnV
 				}
-				var session = sessionEngine.SessionFactory().NewSession(beanName, SessionType_Default)
-				var err error
+				if sessionEngine.SessionFactory() == nil {
+					panic("[GoMybatis] NewSession need a SessionFactory!")
+				}
+				//与mapper方法相同，使用tag datasource 指定的数据源
+				var session, err = routerSession(request, sessionEngine)
+				if err != nil {
+					return buildReturnValues(returnType, returnValue, err)
+				}
+				session, err = sessionEngine.SessionFactory().wrapSession(session, SessionType_Default)
+				if err != nil {
+					return buildReturnValues(returnType, returnValue, err)
+				}
 				returnValue.Elem().Set(reflect.ValueOf(session).Elem().Addr().Convert(*returnType.ReturnOutType))
-				return buildReturnValues(returnType, returnValue, err)
+				return buildReturnValues(returnType, returnValue, nil)
 			}
 			return proxyFunc
 		} else {
@@ -121,8 +192,18 @@ func WriteMapper(bean reflect.Value, xmls [][]byte, sessionEngine SessionEngine)
 					}
 					returnValue = &returnV
 				}
+				if len(mapper.idProperties) != 0 {
+					var e = fillGeneratedIds(sessionEngine, mapper.idProperties, &arg)
+					if e != nil {
+						return buildReturnValues(returnType, returnValue, e)
+					}
+				}
+				if mapper.batchCollection != "" && isEmptyCollectionArg(arg, mapper.batchCollection) {
+					//空切片不执行，影响行数为0
+					return buildReturnValues(returnType, returnValue, nil)
+				}
 				//exe sql
-				var e = exeMethodByXml(mapper.xml.Tag, beanName, sessionEngine, arg, mapper.nodes, resultMap, returnValue)
+				var e = exeMethodByXml(mapper.xml.Tag, sessionEngine, arg, mapper.nodes, resultMap, returnValue, request, mapper.tenant, mapper.sensitive)
 				return buildReturnValues(returnType, returnValue, e)
 			}
 			return proxyFunc
@@ -145,7 +226,7 @@ func beanCheck(value reflect.Value) {
 		var customLen = 0
 		for argIndex := 0; argIndex < fieldItem.Type.NumIn(); argIndex++ {
 			var inType = fieldItem.Type.In(argIndex)
-			if isCustomStruct(inType) {
+			if inType != contextType && isCustomStruct(inType) {
 				customLen++
 			}
 		}
@@ -258,6 +339,7 @@ func makeResultPropertyMap(xmlItem *etree.Element) map[string]*ResultProperty {
 			Property: elementItem.SelectAttrValue("property", ""),
 			LangType: elementItem.SelectAttrValue("langType", ""),
 			IsPrimary: elementItem.Tag == "id",
+			Generator: elementItem.SelectAttrValue("generator", ""),
 		}
 
 		if elementItem.Tag == "association" || elementItem.Tag == "collection" {
@@ -279,8 +361,19 @@ func makeResultPropertyMap(xmlItem *etree.Element) map[string]*ResultProperty {
 	return resultPropertyMap
 }
 
+//查找配置了 generator 的主键属性
+func findIdGeneratorProperties(resultMap map[string]*ResultProperty) []*ResultProperty {
+	var properties []*ResultProperty
+	for _, property := range resultMap {
+		if property.IsPrimary && property.Generator != "" && !strings.Contains(property.Property, ".") {
+			properties = append(properties, property)
+		}
+	}
+	return properties
+}
+
 //return a map map[`method`]*MapperXml
-func makeMethodXmlMap(bean reflect.Value, mapperTree map[string]etree.Token, sqlBuilder SqlBuilder) map[string]*Mapper {
+func makeMethodXmlMap(bean reflect.Value, mapperTree map[string]etree.Token, sessionEngine SessionEngine) map[string]*Mapper {
 	var beanType = bean.Type()
 	if beanType.Kind() == reflect.Ptr {
 		beanType = beanType.Elem()
@@ -295,9 +388,12 @@ func makeMethodXmlMap(bean reflect.Value, mapperTree map[string]etree.Token, sql
 			methodFieldCheck(&beanType, &fieldItem)
 			var mapperXml = findMapperXml(mapperTree, fieldItem.Name)
 			if mapperXml != nil {
+				var parser = engineNodeParser(sessionEngine)
+				parser.Mapper = beanType.String() + "." + fieldItem.Name
 				methodXmlMap[fieldItem.Name] = &Mapper{
-					xml:   mapperXml,
-					nodes: sqlBuilder.NodeParser().Parser(mapperXml.Child),
+					xml:       mapperXml,
+					nodes:     parser.Parser(mapperXml.Child),
+					sensitive: sensitiveArgNames(fieldItem.Type),
 				}
 			} else {
 				if fieldItem.Name == NewSessionFunc {
@@ -307,7 +403,7 @@ func makeMethodXmlMap(bean reflect.Value, mapperTree map[string]etree.Token, sql
 				panic("[GoMybatis] can not find method " + beanType.String() + "." + fieldItem.Name + "() in xml !")
 			}
 		} else if fieldItem.Anonymous { // 支持扫描定义在被继承的结构体上的方法
-			for k, v := range makeMethodXmlMap(reflect.New(fieldItem.Type), mapperTree, sqlBuilder) {
+			for k, v := range makeMethodXmlMap(reflect.New(fieldItem.Type), mapperTree, sessionEngine) {
 				methodXmlMap[k] = v
 			}
 		}
@@ -363,97 +459,180 @@ func findMapperXml(mapperTree map[string]etree.Token, methodName string) *etree.
 	return nil
 }
 
-func exeMethodByXml(elementType ElementType, beanName string, sessionEngine SessionEngine, proxyArg ProxyArg, nodes []ast.Node, resultMap map[string]*ResultProperty, returnValue *reflect.Value) error {
+//request为构建期的路由请求（mapper名称和tag），执行期补充读写类型和ctx；tenant为构建期确定的租户处理方式；sensitive为日志中脱敏的属性名
+func exeMethodByXml(elementType ElementType, sessionEngine SessionEngine, proxyArg ProxyArg, nodes []ast.Node, resultMap map[string]*ResultProperty, returnValue *reflect.Value, request RouterRequest, tenant TenantMode, sensitive map[string]bool) error {
 	//TODO　CallBack and Session must Location in build step!
-	var session Session
-	var sql string
-	var err error
-	var array_arg = []interface{}{}
-	session, sql, err = buildSql(proxyArg, nodes, sessionEngine.SqlBuilder(), &array_arg)
+	defer trackActive(sessionEngine)()
+	var session, paramMap = buildParamMap(proxyArg)
+	var ctx = findContextArg(proxyArg)
+	request.ReadOnly = elementType == Element_Select
+	request.ForceMaster = request.ForceMaster || IsForceMaster(ctx)
+	request.Context = ctx
+	if sensitive != nil {
+		paramMap[SensitiveArgsVar] = sensitive
+	}
+	if tenant != TenantMode_None {
+		var tenantId, err = engineTenantFilter(sessionEngine).TenantId(ctx)
+		if err != nil {
+			return err
+		}
+		paramMap[TenantVar] = tenantId
+	}
+	if router, ok := sessionEngine.DataSourceRouter().(ShardingRouter); ok {
+		return exeShardingMethod(router, elementType, sessionEngine, session, paramMap, nodes, resultMap, returnValue, request, tenant)
+	}
+	//先确定session，sql构建时可使用session的 _databaseId
+	session, created, err := openSession(request, sessionEngine, session)
 	if err != nil {
 		return err
 	}
-	if sessionEngine.SessionFactory() == nil && session == nil {
+	if created {
+		defer session.Close()
+	}
+	paramMap[ast.DatabaseIdVar] = sessionDatabaseId(session)
+	sql, array_arg, err := buildStatementSql(sessionEngine, paramMap, nodes, tenant)
+	if err != nil {
+		return err
+	}
+	return exeSql(elementType, request, sessionEngine, session, sql, array_arg, resultMap, returnValue)
+}
+
+//构建sql，tenant为 TenantMode_Rewrite 时改写sql追加租户条件
+func buildStatementSql(sessionEngine SessionEngine, paramMap map[string]interface{}, nodes []ast.Node, tenant TenantMode) (string, []interface{}, error) {
+	var array_arg = []interface{}{}
+	sql, err := sessionEngine.SqlBuilder().BuildSql(paramMap, nodes, &array_arg)
+	if err != nil {
+		return "", nil, err
+	}
+	if tenant == TenantMode_Rewrite {
+		return rewriteTenantSelect(sql, engineTenantFilter(sessionEngine).Column(), array_arg, paramMap[TenantVar])
+	}
+	return sql, array_arg, nil
+}
+
+//session为nil时使用协程绑定的（事务）session或经数据源路由新建session，created为是否新建（需要调用方关闭）
+func openSession(request RouterRequest, sessionEngine SessionEngine, session Session) (Session, bool, error) {
+	if session != nil {
+		return session, false, checkSessionTenant(request, sessionEngine, session)
+	}
+	if sessionEngine.SessionFactory() == nil {
 		panic("[GoMybatis] exe sql need a SessionFactory or Session!")
 	}
-	//session
-	if session == nil {
-		var goroutineID int64 //协程id
-		if sessionEngine.GoroutineIDEnable() {
-			goroutineID = utils.GoroutineID()
-		} else {
-			goroutineID = 0
-		}
-		session = sessionEngine.GoroutineSessionMap().Get(goroutineID)
+	session = goroutineSession(sessionEngine)
+	if session != nil {
+		return session, false, checkSessionTenant(request, sessionEngine, session)
 	}
-	if session == nil {
-		var s, err = sessionEngine.NewSession(beanName)
-		if err != nil {
-			return err
-		}
-		session = s
+	var s, err = routerSession(request, sessionEngine)
+	if err != nil {
+		return nil, false, err
+	}
+	return s, true, nil
+}
+
+//经数据源路由新建session，路由支持按语句路由时使用request（例如tag datasource 指定的数据源）
+func routerSession(request RouterRequest, sessionEngine SessionEngine) (Session, error) {
+	if err := checkAcceptSession(sessionEngine); err != nil {
+		return nil, err
+	}
+	if router, ok := sessionEngine.DataSourceRouter().(StatementDataSourceRouter); ok {
+		return router.RouterStatement(request, sessionEngine)
+	}
+	return sessionEngine.NewSession(request.MapperName)
+}
+
+//多租户路由时，已有session（参数或事务）的租户必须与ctx的租户一致
+func checkSessionTenant(request RouterRequest, sessionEngine SessionEngine, session Session) error {
+	var router, ok = sessionEngine.DataSourceRouter().(TenantRouter)
+	if !ok || request.Context == nil {
+		return nil
+	}
+	var tenantSession, isTenant = session.(interface{ Tenant() string })
+	if !isTenant {
+		return nil
+	}
+	var tenant, err = router.ResolveTenant(request.Context)
+	if err != nil {
+		return err
+	}
+	if tenantSession.Tenant() != tenant {
+		return utils.NewError("TenantDataSourceRouter", "session of tenant '"+tenantSession.Tenant()+"' can not execute statement of tenant '"+tenant+"'!")
+	}
+	return nil
+}
+
+//协程绑定的（事务）session，没有返回nil
+func goroutineSession(sessionEngine SessionEngine) Session {
+	var goroutineID int64 //协程id
+	if sessionEngine.GoroutineIDEnable() {
+		goroutineID = utils.GoroutineID()
+	} else {
+		goroutineID = 0
+	}
+	return sessionEngine.GoroutineSessionMap().Get(goroutineID)
+}
+
+//执行已构建的sql，session为nil时使用协程绑定的session或新建session。request提供日志中的mapper和语句id
+func exeSql(elementType ElementType, request RouterRequest, sessionEngine SessionEngine, session Session, sql string, array_arg []interface{}, resultMap map[string]*ResultProperty, returnValue *reflect.Value) error {
+	request.ReadOnly = elementType == Element_Select
+	session, created, err := openSession(request, sessionEngine, session)
+	if err != nil {
+		return err
+	}
+	if created {
 		defer session.Close()
 	}
 	var haveLastReturnValue = returnValue != nil && (*returnValue).IsNil() == false
 
 	sql = session.ProcessSQL(sql)
+	//日志中脱敏的参数，执行时还原
+	array_arg, logArgs := ast.SplitRedactedArgs(array_arg)
+	var event = SqlEvent{
+		Time:        time.Now(),
+		StatementId: request.StatementId,
+		Mapper:      request.MapperName,
+		SessionId:   session.Id(),
+		Sql:         sql,
+		Args:        logArgs,
+		Query:       elementType == Element_Select && haveLastReturnValue,
+	}
 	//do CRUD
-	if elementType == Element_Select && haveLastReturnValue {
+	if event.Query {
 		//is select and have return value
-		if sessionEngine.LogEnable() {
-			sessionEngine.LogSystem().SendLog("[GoMybatis] [", session.Id(), "] Query ==> "+sql)
-			sessionEngine.LogSystem().SendLog("[GoMybatis] [", session.Id(), "] Args  ==> "+utils.SprintArray(array_arg))
-		}
-		rows, err := session.QueryPrepareNew(sql, array_arg...)
-		if err != nil {
-			return err
-		}
-		defer rows.Close()
-
-		rowCount, err := sessionEngine.SqlResultDecoder().DecodeNew(resultMap, rows, returnValue.Interface())
-		if err != nil {
-			return err
-		}
-
-		defer func() {
-			if sessionEngine.LogEnable() {
-				var RowsAffected = "0"
-				if err == nil && rows != nil {
-					RowsAffected = strconv.Itoa(rowCount)
-				}
-				sessionEngine.LogSystem().SendLog("[GoMybatis] [", session.Id(), "] ReturnRows <== "+RowsAffected)
-				if err != nil {
-					sessionEngine.LogSystem().SendLog("[GoMybatis] [", session.Id(), "] error == "+err.Error())
-				}
-			}
-		}()
+		err = querySql(sessionEngine, session, sql, array_arg, resultMap, returnValue, &event.Rows)
 	} else {
-		if sessionEngine.LogEnable() {
-			sessionEngine.LogSystem().SendLog("[GoMybatis] [", session.Id(), "] Exec ==> "+sql)
-			sessionEngine.LogSystem().SendLog("[GoMybatis] [", session.Id(), "] Args ==> "+utils.SprintArray(array_arg))
-		}
-		var res, err = session.ExecPrepare(sql, array_arg...)
-		defer func() {
-			if sessionEngine.LogEnable() {
-				var RowsAffected = "0"
-				if err == nil && res != nil {
-					RowsAffected = strconv.FormatInt(res.RowsAffected, 10)
-				}
-				sessionEngine.LogSystem().SendLog("[GoMybatis] [", session.Id(), "] RowsAffected <== "+RowsAffected)
-				if err != nil {
-					sessionEngine.LogSystem().SendLog("[GoMybatis] [", session.Id(), "] error == "+err.Error())
-				}
+		var res *Result
+		res, err = session.ExecPrepare(sql, array_arg...)
+		if err == nil && res != nil {
+			event.Rows = res.RowsAffected
+			if haveLastReturnValue {
+				returnValue.Elem().SetInt(res.RowsAffected)
 			}
-		}()
-
-		if err != nil {
-			return err
-		}
-		if haveLastReturnValue {
-			returnValue.Elem().SetInt(res.RowsAffected)
 		}
 	}
-	return nil
+	event.Elapsed = time.Since(event.Time)
+	event.Err = err
+	var threshold = engineSlowSqlThreshold(sessionEngine)
+	event.Slow = threshold > 0 && event.Elapsed >= threshold
+	//关闭日志时慢sql仍然输出
+	if sessionEngine.LogEnable() || event.Slow {
+		if session.LastPROPAGATION() != nil {
+			event.TxId = session.Id()
+		}
+		engineSqlLogger(sessionEngine).LogSql(event)
+	}
+	return err
+}
+
+//执行查询并解码到returnValue，rowCount为返回行数
+func querySql(sessionEngine SessionEngine, session Session, sql string, array_arg []interface{}, resultMap map[string]*ResultProperty, returnValue *reflect.Value, rowCount *int64) error {
+	rows, err := session.QueryPrepareNew(sql, array_arg...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	count, err := sessionEngine.SqlResultDecoder().DecodeNew(resultMap, rows, returnValue.Interface())
+	*rowCount = int64(count)
+	return err
 }
 
 func closeSession(factory *SessionFactory, session Session) {
@@ -464,7 +643,28 @@ func closeSession(factory *SessionFactory, session Session) {
 	session.Close()
 }
 
-func buildSql(proxyArg ProxyArg, nodes []ast.Node, sqlBuilder SqlBuilder, array_arg *[]interface{}) (Session, string, error) {
+//方法参数中名为collection的切片（或map）是否为空
+func isEmptyCollectionArg(proxyArg ProxyArg, collection string) bool {
+	var _, paramMap = buildParamMap(proxyArg)
+	var v = reflect.ValueOf(paramMap[collection])
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr:
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() == 0
+	}
+	return false
+}
+
+//方法参数转为sql构建参数，返回参数中的session
+func buildParamMap(proxyArg ProxyArg) (Session, map[string]interface{}) {
 	var session Session
 	var paramMap = make(map[string]interface{})
 	var tagArgsLen = proxyArg.TagArgsLen
@@ -479,6 +679,8 @@ func buildSql(proxyArg ProxyArg, nodes []ast.Node, sqlBuilder SqlBuilder, array_
 		} else if argInterface != nil && arg.Kind() == reflect.Interface && arg.Type().String() == GoMybatis_Session {
 			session = argInterface.(Session)
 			continue
+		} else if arg.Type() == contextType {
+			continue
 		}
 		if isCustomStruct(arg.Type()) {
 			customLen++
@@ -516,8 +718,47 @@ func buildSql(proxyArg ProxyArg, nodes []ast.Node, sqlBuilder SqlBuilder, array_
 		}
 	}
 
-	result, err := sqlBuilder.BuildSql(paramMap, nodes, array_arg)
-	return session, result, err
+	return session, paramMap
+}
+
+//mapper tag，指定方法或结构体使用的数据源名称，见 GoMybatisDataSourceRouter
+const DataSourceTag = "datasource"
+
+//结构体非方法字段（例如 _ struct{}）tag指定的数据源
+func findMapperDataSource(beanType reflect.Type) string {
+	for i := 0; i < beanType.NumField(); i++ {
+		var field = beanType.Field(i)
+		if field.Type.Kind() == reflect.Func {
+			continue
+		}
+		if name := field.Tag.Get(DataSourceTag); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+//构建期检查tag datasource 指定的数据源已注册，数据源路由需实现 HasDataSource（例如 GoMybatisDataSourceRouter）
+func checkDataSourceTag(sessionEngine SessionEngine, name string, method string) {
+	var router, ok = sessionEngine.DataSourceRouter().(interface{ HasDataSource(name string) bool })
+	if !ok {
+		panic("[GoMybatis] data source router " + sessionEngine.DataSourceRouter().Name() + " not support tag datasource! method = " + method)
+	}
+	if !router.HasDataSource(name) {
+		panic("[GoMybatis] datasource '" + name + "' not registered! open it before WriteMapper, method = " + method)
+	}
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+//方法参数中的context.Context，没有返回nil
+func findContextArg(proxyArg ProxyArg) context.Context {
+	for _, arg := range proxyArg.Args {
+		if arg.Type() == contextType && !arg.IsNil() {
+			return arg.Interface().(context.Context)
+		}
+	}
+	return nil
 }
 
 //scan params
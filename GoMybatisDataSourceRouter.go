@@ -2,65 +2,239 @@ package GoMybatis
 
 import (
 	"database/sql"
+	"strconv"
+	"sync"
+
 	"github.com/zhuxiujia/GoMybatis/utils"
 )
 
 //动态数据源路由
+//数据源按名称注册，默认数据源为 SetDefaultDataSource 指定的数据源，未指定时为第一个注册的数据源。
+//SetDB（GoMybatisEngine.Open）注册的数据源名称为 default，之后不同url的依次为 default_1、default_2...，
+//避免连接池统计、健康事件中出现url中的账号密码；url仍可作为名称使用（例如 routerFunc 返回url）。
+//mapper可通过tag指定数据源，方法tag优先于结构体tag（非方法字段，例如 _ struct{} `datasource:"orders"`）：
+//
+//	type OrderMapper struct {
+//		_          struct{}                           `datasource:"orders"`
+//		SelectById func(id string) (Order, error)     `mapperParams:"id"`
+//		SelectLog  func(id string) ([]OrderLog, error) `mapperParams:"id" datasource:"logs"`
+//	}
+//
+//设置 SetHealthChecker 后，默认数据源不健康时使用 SetFailover 列表中第一个健康的数据源
 type GoMybatisDataSourceRouter struct {
-	dbMap      map[string]*sql.DB
-	driverMap  map[string]string
-	routerFunc func(mapperName string) *string
+	mutex         *sync.RWMutex
+	dbMap         map[string]*sql.DB
+	driverMap     map[string]string
+	urlMap        map[string]string //数据源url，用于新建事务传播所需的独立session
+	urlNames      map[string]string //SetDB 的url -> 数据源名称
+	names         []string //注册顺序
+	defaultName   string
+	routerFunc    func(mapperName string) *string
+	healthChecker *HealthChecker
+	failover      []string //默认数据源的故障转移列表
 }
 
-//初始化路由，routerFunc为nil或者routerFunc返回nil，则框架自行选择第一个数据库作为数据源
+//初始化路由，routerFunc为nil或者routerFunc返回nil，则使用默认数据源
 func (it GoMybatisDataSourceRouter) New(routerFunc func(mapperName string) *string) GoMybatisDataSourceRouter {
 	if routerFunc == nil {
 		routerFunc = func(mapperName string) *string {
 			return nil
 		}
 	}
+	it.mutex = &sync.RWMutex{}
 	it.dbMap = make(map[string]*sql.DB)
 	it.driverMap = make(map[string]string)
+	it.urlMap = make(map[string]string)
+	it.urlNames = make(map[string]string)
+	it.names = []string{}
 	it.routerFunc = routerFunc
 	return it
 }
 
+//注册数据源，名称为 default（已被其他url使用时为 default_1、default_2...），同url覆盖，该方法会被 GoMybatisEngine.Open 调用
 func (it *GoMybatisDataSourceRouter) SetDB(driver string, url string, db *sql.DB) {
-	it.dbMap[url] = db
-	it.driverMap[url] = driver
+	it.mutex.Lock()
+	var name, ok = it.urlNames[url]
+	if !ok {
+		name = "default"
+		for i := 1; it.dbMap[name] != nil; i++ {
+			name = "default_" + strconv.Itoa(i)
+		}
+		it.urlNames[url] = name
+	}
+	it.mutex.Unlock()
+	it.RegisterDataSource(name, driver, url, db)
+}
+
+//SetDB 注册的数据源可使用url作为名称，调用方需持有锁
+func (it *GoMybatisDataSourceRouter) resolveName(name string) string {
+	if it.dbMap[name] == nil {
+		if urlName, ok := it.urlNames[name]; ok {
+			return urlName
+		}
+	}
+	return name
+}
+
+//按名称注册数据源，同名覆盖
+func (it *GoMybatisDataSourceRouter) RegisterDataSource(name string, driver string, url string, db *sql.DB) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	if _, ok := it.dbMap[name]; !ok {
+		it.names = append(it.names, name)
+	}
+	it.dbMap[name] = db
+	it.driverMap[name] = driver
+	it.urlMap[name] = url
+}
+
+//是否已注册名称为name的数据源，WriteMapper 时检查mapper tag datasource
+func (it *GoMybatisDataSourceRouter) HasDataSource(name string) bool {
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	return it.dbMap[it.resolveName(name)] != nil
+}
+
+//设置默认数据源，数据源需已注册
+func (it *GoMybatisDataSourceRouter) SetDefaultDataSource(name string) error {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	name = it.resolveName(name)
+	if it.dbMap[name] == nil {
+		return utils.NewError("GoMybatisDataSourceRouter", "datasource '"+name+"' not registered!")
+	}
+	it.defaultName = name
+	return nil
+}
+
+//默认数据源名称，没有数据源返回""
+func (it *GoMybatisDataSourceRouter) DefaultDataSource() string {
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	if it.defaultName != "" {
+		return it.defaultName
+	}
+	for _, name := range it.names {
+		if it.dbMap[name] != nil {
+			return name
+		}
+	}
+	return ""
+}
+
+//设置健康检查，见 HealthChecker
+func (it *GoMybatisDataSourceRouter) SetHealthChecker(checker *HealthChecker) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.healthChecker = checker
+}
+
+//停止设置的健康检查，见 StoppableRouter
+func (it *GoMybatisDataSourceRouter) Stop() {
+	it.mutex.RLock()
+	var checker = it.healthChecker
+	it.mutex.RUnlock()
+	if checker != nil {
+		checker.Stop()
+	}
+}
+
+//默认数据源的故障转移列表，按顺序选择第一个健康的数据源，需设置 SetHealthChecker
+func (it *GoMybatisDataSourceRouter) SetFailover(names ...string) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.failover = names
+}
+
+//当前使用的默认数据源：默认数据源不健康时为故障转移列表中第一个健康的数据源，都不健康时仍为默认数据源
+func (it *GoMybatisDataSourceRouter) ActiveDataSource() string {
+	var name = it.DefaultDataSource()
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	if name == "" || it.healthChecker.IsHealthy(name) {
+		return name
+	}
+	for _, candidate := range it.failover {
+		if candidate != name && it.dbMap[candidate] != nil && it.healthChecker.IsHealthy(candidate) {
+			return candidate
+		}
+	}
+	return name
+}
+
+//已注册的数据源
+func (it *GoMybatisDataSourceRouter) DataSources() map[string]*sql.DB {
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	var dataSources = make(map[string]*sql.DB, len(it.dbMap))
+	for name, db := range it.dbMap {
+		if db != nil {
+			dataSources[name] = db
+		}
+	}
+	return dataSources
 }
 
 func (it *GoMybatisDataSourceRouter) Router(mapperName string, engine SessionEngine) (Session, error) {
 	var key *string
-	var db *sql.DB
-
 	if it.routerFunc != nil {
 		key = it.routerFunc(mapperName)
 	}
-
 	if key != nil && *key != "" {
-		db = it.dbMap[*key]
-	} else {
-		for k, v := range it.dbMap {
-			if v != nil {
-				db = v
-				key = &k
-				break
-			}
-		}
+		return it.routerDataSource(*key, engine)
 	}
-	if db == nil {
+	var name = it.ActiveDataSource()
+	if name == "" {
 		return nil, utils.NewError("GoMybatisDataSourceRouter", "router not find datasource opened ! do you forget invoke GoMybatis.GoMybatisEngine{}.New().Open(\"driverName\", Uri)?")
 	}
-	var url = ""
-	if key != nil {
-		url = *key
+	return it.routerDataSource(name, engine)
+}
+
+//mapper tag指定了数据源时使用该数据源，否则同 Router
+func (it *GoMybatisDataSourceRouter) RouterStatement(request RouterRequest, engine SessionEngine) (Session, error) {
+	if request.DataSource != "" {
+		return it.routerDataSource(request.DataSource, engine)
 	}
-	var local = LocalSession{}.New(it.driverMap[url], url, db, engine.Log())
-	var session = Session(&local)
-	return session, nil
+	return it.Router(request.MapperName, engine)
+}
+
+func (it *GoMybatisDataSourceRouter) routerDataSource(name string, engine SessionEngine) (Session, error) {
+	it.mutex.RLock()
+	name = it.resolveName(name)
+	var db = it.dbMap[name]
+	var driver = it.driverMap[name]
+	var url = it.urlMap[name]
+	it.mutex.RUnlock()
+	if db == nil {
+		return nil, utils.NewError("GoMybatisDataSourceRouter", "datasource '"+name+"' not registered!")
+	}
+	var local = newRouterSession(driver, url, db, engine).(*LocalSession)
+	local.dataSource = name
+	return local, nil
 }
 
 func (it *GoMybatisDataSourceRouter) Name() string {
 	return "GoMybatisDataSourceRouter"
 }
+
+//提供所属数据源名称的session，见 LocalSession.DataSource
+type DataSourceSession interface {
+	DataSource() string
+}
+
+//session所属的数据源名称，未实现 DataSourceSession 返回""
+func sessionDataSource(session Session) string {
+	if s, ok := session.(DataSourceSession); ok {
+		return s.DataSource()
+	}
+	return ""
+}
+
+//路由选择数据源后创建session
+func newRouterSession(driver string, url string, db *sql.DB, engine SessionEngine) Session {
+	var local = LocalSession{}.New(driver, url, db, engine.Log())
+	if size := engineStmtCacheSize(engine); size != DefaultStmtCacheSize {
+		local.SetStmtCacheSize(size)
+	}
+	return Session(&local)
+}
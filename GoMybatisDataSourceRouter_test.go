@@ -0,0 +1,187 @@
+package GoMybatis
+
+import (
+	"testing"
+
+	"github.com/zhuxiujia/GoMybatis/tx"
+)
+
+type DataSourceTestMapper struct {
+	_          struct{} `datasource:"orders"`
+	SelectAll  func() ([]map[string]string, error)
+	SelectLog  func() ([]map[string]string, error) `datasource:"logs"`
+	NewSession func() (Session, error)
+}
+
+type MissingDataSourceTestMapper struct {
+	SelectBad func() ([]map[string]string, error) `datasource:"missing"`
+}
+
+type DataSourceTestService struct {
+	_         struct{}     `datasource:"orders"`
+	UpdateLog func() error `tx:"" datasource:"logs"`
+	Update    func() error `tx:""`
+}
+
+type DefaultDataSourceTestMapper struct {
+	SelectAll func() ([]map[string]string, error)
+}
+
+const dataSourceTestXml = `<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectAll">select * from t</select>
+    <select id="selectLog">select * from t_log</select>
+    <select id="selectBad">select 1</select>
+</mapper>`
+
+func TestGoMybatisDataSourceRouter_Named(t *testing.T) {
+	resetTestDriverState("TestDataSourceRouter_main", "TestDataSourceRouter_orders", "TestDataSourceRouter_logs")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	for _, name := range []string{"main", "orders", "logs"} {
+		if _, err := engine.OpenDataSource(name, TestDriverName, "TestDataSourceRouter_"+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var mapper DataSourceTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(dataSourceTestXml))
+	var defaultMapper DefaultDataSourceTestMapper
+	engine.WriteMapperPtr(&defaultMapper, []byte(dataSourceTestXml))
+
+	if _, err := mapper.SelectAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mapper.SelectLog(); err != nil {
+		t.Fatal(err)
+	}
+	//未指定数据源使用第一个注册的数据源
+	for i := 0; i < 5; i++ {
+		if _, err := defaultMapper.SelectAll(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(testDriverState("TestDataSourceRouter_orders").Queries); n != 1 {
+		t.Fatal("struct tag datasource fail:", n)
+	}
+	if n := len(testDriverState("TestDataSourceRouter_logs").Queries); n != 1 {
+		t.Fatal("func tag datasource fail:", n)
+	}
+	if n := len(testDriverState("TestDataSourceRouter_main").Queries); n != 5 {
+		t.Fatal("default datasource fail:", n)
+	}
+
+	//显式指定默认数据源
+	var router = engine.DataSourceRouter().(*GoMybatisDataSourceRouter)
+	if err := router.SetDefaultDataSource("logs"); err != nil {
+		t.Fatal(err)
+	}
+	defaultMapper.SelectAll()
+	if n := len(testDriverState("TestDataSourceRouter_logs").Queries); n != 2 {
+		t.Fatal("explicit default datasource fail:", n)
+	}
+	if err := router.SetDefaultDataSource("missing"); err == nil {
+		t.Fatal("unknown default datasource must fail")
+	}
+
+	//mapper的NewSession同样使用tag指定的数据源
+	session, err := mapper.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Query("select * from t"); err != nil {
+		t.Fatal(err)
+	}
+	session.Close()
+	if n := len(testDriverState("TestDataSourceRouter_orders").Queries); n != 2 {
+		t.Fatal("NewSession datasource fail:", n)
+	}
+
+	//未注册的数据源在WriteMapper时报错
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("unknown datasource must panic")
+			}
+		}()
+		var missing MissingDataSourceTestMapper
+		engine.WriteMapperPtr(&missing, []byte(dataSourceTestXml))
+	}()
+}
+
+func TestGoMybatisDataSourceRouter_Aop(t *testing.T) {
+	resetTestDriverState("TestDataSourceRouter_main", "TestDataSourceRouter_orders", "TestDataSourceRouter_logs")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	for _, name := range []string{"main", "orders", "logs"} {
+		if _, err := engine.OpenDataSource(name, TestDriverName, "TestDataSourceRouter_"+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var service = DataSourceTestService{}
+	service.UpdateLog = func() error { return nil }
+	service.Update = func() error { return nil }
+	AopProxyService(&service, &engine)
+	service.UpdateLog()
+	service.Update()
+	if n := testDriverState("TestDataSourceRouter_logs").Begins; n != 1 {
+		t.Fatal("func tag datasource fail:", n)
+	}
+	if n := testDriverState("TestDataSourceRouter_orders").Begins; n != 1 {
+		t.Fatal("struct tag datasource fail:", n)
+	}
+	if n := testDriverState("TestDataSourceRouter_main").Begins; n != 0 {
+		t.Fatal("default datasource must not be used:", n)
+	}
+}
+
+func TestGoMybatisDataSourceRouter_RouterFunc(t *testing.T) {
+	resetTestDriverState("TestDataSourceRouter_func")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var name = "missing"
+	var router = GoMybatisDataSourceRouter{}.New(func(mapperName string) *string {
+		return &name
+	})
+	engine.SetDataSourceRouter(&router)
+	engine.Open(TestDriverName, "TestDataSourceRouter_func")
+	if _, err := engine.NewSession(""); err == nil {
+		t.Fatal("unknown datasource must fail")
+	}
+	name = "TestDataSourceRouter_func"
+	var session, err = engine.NewSession("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Close()
+}
+
+func TestGoMybatisDataSourceRouter_RequiresNew(t *testing.T) {
+	resetTestDriverState("TestDataSourceRouter_main", "TestDataSourceRouter_orders")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	for _, name := range []string{"main", "orders"} {
+		if _, err := engine.OpenDataSource(name, TestDriverName, "TestDataSourceRouter_"+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var router = engine.DataSourceRouter().(*GoMybatisDataSourceRouter)
+	session, err := router.RouterStatement(RouterRequest{DataSource: "orders"}, &engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	//REQUIRES_NEW 使用数据源的url新建session，而不是数据源名称
+	var p = tx.PROPAGATION_REQUIRES_NEW
+	if err := session.Begin(&p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Query("select * from t"); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(testDriverState("TestDataSourceRouter_orders").Queries); n != 1 {
+		t.Fatal("requires new session must use datasource url:", n)
+	}
+}
@@ -1,12 +1,14 @@
 package GoMybatis
 
 import (
+	"context"
 	"database/sql"
 	"github.com/zhuxiujia/GoMybatis/ast"
 	"github.com/zhuxiujia/GoMybatis/engines"
 	"github.com/zhuxiujia/GoMybatis/utils"
 	"reflect"
 	"sync"
+	"time"
 )
 
 type GoMybatisEngine struct {
@@ -16,6 +18,15 @@ type GoMybatisEngine struct {
 	objMap map[string]interface{}
 	varsMap map[string]interface{}
 
+	idGeneratorMap map[string]IdGenerator //主键生成器
+	stmtCacheSize  int                    //session预编译语句缓存容量
+	rawSqlGuard    *RawSqlGuard           //${}sql注入检查
+	tenantFilter   *TenantFilter          //多租户行过滤
+	lifecycleState *engineLifecycle       //执行中的语句、事务，见 Shutdown
+	sqlLogger      SqlLogger              //结构化sql日志，nil时使用 LogSystem 输出文本日志
+	slowThreshold  time.Duration          //慢sql阈值
+	logRedactor    *LogRedactor           //sql日志参数脱敏
+
 	dataSourceRouter    DataSourceRouter      //动态数据源路由器
 	log                 Log                   //日志实现类
 	logEnable           bool                  //是否允许日志输出（默认开启）
@@ -56,8 +67,22 @@ func (it GoMybatisEngine) New() GoMybatisEngine {
 	if it.sqlResultDecoder == nil {
 		it.sqlResultDecoder = GoMybatisSqlResultDecoder{}
 	}
+	if it.tenantFilter == nil {
+		var filter = TenantFilter{}.New()
+		it.tenantFilter = &filter
+	}
 	if it.templeteDecoder == nil {
-		it.SetTempleteDecoder(&GoMybatisTempleteDecoder{})
+		it.SetTempleteDecoder(&GoMybatisTempleteDecoder{tenantFilter: it.tenantFilter})
+	}
+
+	if it.rawSqlGuard == nil {
+		var guard = RawSqlGuard{}.New(it.log)
+		it.rawSqlGuard = &guard
+	}
+
+	if it.logRedactor == nil {
+		var redactor = LogRedactor{}.New()
+		it.logRedactor = &redactor
 	}
 
 	if it.sqlBuilder == nil {
@@ -78,8 +103,18 @@ func (it GoMybatisEngine) New() GoMybatisEngine {
 		var gr = GoroutineSessionMap{}.New()
 		it.goroutineSessionMap = &gr
 	}
+	it.lifecycleState = &engineLifecycle{}
 	it.objMap = map[string]interface{}{}
 	it.varsMap = map[string]interface{}{}
+	if it.idGeneratorMap == nil {
+		var snowflake, _ = SnowflakeIdGenerator{}.New(0)
+		it.idGeneratorMap = map[string]IdGenerator{
+			IdGenerator_Snowflake: &snowflake,
+			IdGenerator_UUID:      UUIDIdGenerator{},
+			IdGenerator_ULID:      ULIDIdGenerator{},
+		}
+	}
+	it.stmtCacheSize = DefaultStmtCacheSize
 	it.goroutineIDEnable = true
 	return it
 }
@@ -95,6 +130,23 @@ func (it *GoMybatisEngine) WriteMapperPtr(ptr interface{}, xmls ...[]byte) {
 	WriteMapperPtrByEngine(ptr, xmls, it)
 }
 
+//同 WriteMapperPtr，MapperFile.Name 会出现在解析错误与元素位置信息中
+func (it *GoMybatisEngine) WriteMapperFilePtr(ptr interface{}, files ...MapperFile) {
+	it.initCheck()
+	var v = reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr {
+		panic("AopProxy: AopProxy arg must be a pointer")
+	}
+	WriteMapperFiles(v, files, it)
+	it.RegisterObj(v.Interface(), v.Type().Elem().Name())
+}
+
+//校验mapper与xml，返回诊断信息（见 ValidateMapper），可在 WriteMapperPtr 之前调用以提前发现错误
+func (it *GoMybatisEngine) Validate(ptr interface{}, files ...MapperFile) []Diagnostic {
+	it.initCheck()
+	return ValidateMapper(ptr, files, it)
+}
+
 func (it *GoMybatisEngine) Name() string {
 	return "GoMybatisEngine"
 }
@@ -110,6 +162,9 @@ func (it *GoMybatisEngine) SetDataSourceRouter(router DataSourceRouter) {
 
 func (it *GoMybatisEngine) NewSession(mapperName string) (Session, error) {
 	it.initCheck()
+	if err := checkAcceptSession(it); err != nil {
+		return nil, err
+	}
 	var session, err = it.DataSourceRouter().Router(mapperName, SessionEngine(it))
 	return session, err
 }
@@ -127,6 +182,31 @@ func (it *GoMybatisEngine) SetLogEnable(enable bool) {
 	it.sqlBuilder.SetEnableLog(enable)
 }
 
+//设置结构化sql日志（例如 JsonLinesSqlLogger、SlogSqlLogger），nil恢复为 LogSystem 输出的文本日志
+func (it *GoMybatisEngine) SetSqlLogger(logger SqlLogger) {
+	it.initCheck()
+	it.sqlLogger = logger
+}
+
+//结构化sql日志
+func (it *GoMybatisEngine) SqlLogger() SqlLogger {
+	if it.sqlLogger == nil {
+		return logSystemSqlLogger{logSystem: it.logSystem}
+	}
+	return it.sqlLogger
+}
+
+//设置慢sql阈值，耗时达到阈值的语句 SqlEvent.Slow 为true（LogEnable() 为false时同样输出到 SqlLogger），<=0 不标记（默认）
+func (it *GoMybatisEngine) SetSlowSqlThreshold(threshold time.Duration) {
+	it.initCheck()
+	it.slowThreshold = threshold
+}
+
+//慢sql阈值
+func (it *GoMybatisEngine) SlowSqlThreshold() time.Duration {
+	return it.slowThreshold
+}
+
 //获取日志实现类
 func (it *GoMybatisEngine) Log() Log {
 	it.initCheck()
@@ -137,6 +217,7 @@ func (it *GoMybatisEngine) Log() Log {
 func (it *GoMybatisEngine) SetLog(log Log) {
 	it.initCheck()
 	it.log = log
+	it.rawSqlGuard.SetLog(log)
 }
 
 //session工厂
@@ -204,8 +285,13 @@ func (it *GoMybatisEngine) SetSqlResultDecoder(decoder SqlResultDecoder) {
 //打开数据库
 //driverName: 驱动名称例如"mysql", dataSourceName: string 数据库url
 func (it *GoMybatisEngine) Open(driverName, dataSourceName string) (*sql.DB, error) {
+	return it.OpenWithOptions(driverName, dataSourceName, PoolOptions{})
+}
+
+//打开数据库并设置连接池
+func (it *GoMybatisEngine) OpenWithOptions(driverName, dataSourceName string, options PoolOptions) (*sql.DB, error) {
 	it.initCheck()
-	db, err := sql.Open(driverName, dataSourceName)
+	db, err := openPool(driverName, dataSourceName, options)
 	if err != nil {
 		return nil, err
 	}
@@ -213,6 +299,40 @@ func (it *GoMybatisEngine) Open(driverName, dataSourceName string) (*sql.DB, err
 	return db, nil
 }
 
+//按名称打开数据源，数据源路由需支持命名数据源（例如 GoMybatisDataSourceRouter）
+func (it *GoMybatisEngine) OpenDataSource(name string, driverName, dataSourceName string) (*sql.DB, error) {
+	return it.OpenDataSourceWithOptions(name, driverName, dataSourceName, PoolOptions{})
+}
+
+//按名称打开数据源并设置连接池
+func (it *GoMybatisEngine) OpenDataSourceWithOptions(name string, driverName, dataSourceName string, options PoolOptions) (*sql.DB, error) {
+	it.initCheck()
+	var router, ok = it.dataSourceRouter.(interface {
+		RegisterDataSource(name string, driver string, url string, db *sql.DB)
+	})
+	if !ok {
+		return nil, utils.NewError("GoMybatisEngine", "data source router "+it.dataSourceRouter.Name()+" not support named datasource!")
+	}
+	db, err := openPool(driverName, dataSourceName, options)
+	if err != nil {
+		return nil, err
+	}
+	router.RegisterDataSource(name, driverName, dataSourceName, db)
+	return db, nil
+}
+
+//数据源路由中全部数据源的连接池统计，key为数据源名称，数据源路由需实现 DataSourceLister
+func (it *GoMybatisEngine) DataSourceStats() map[string]sql.DBStats {
+	it.initCheck()
+	var stats = map[string]sql.DBStats{}
+	if lister, ok := it.dataSourceRouter.(DataSourceLister); ok {
+		for name, db := range lister.DataSources() {
+			stats[name] = db.Stats()
+		}
+	}
+	return stats
+}
+
 //模板解析器
 func (it *GoMybatisEngine) TempleteDecoder() TempleteDecoder {
 	return it.templeteDecoder
@@ -231,6 +351,47 @@ func (it *GoMybatisEngine) AddVar(name string, val interface{})  {
 	it.varsMap[name] = val
 }
 
+//注册主键生成器，name对应resultMap中 <id generator="name"/>，同名则覆盖（例如重新注册指定了workerId的snowflake）
+func (it *GoMybatisEngine) RegisterIdGenerator(name string, generator IdGenerator) {
+	it.initCheck()
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.idGeneratorMap[name] = generator
+}
+
+//获取主键生成器
+func (it *GoMybatisEngine) IdGenerator(name string) IdGenerator {
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	return it.idGeneratorMap[name]
+}
+
+//设置session的预编译语句缓存容量（<=0 不缓存），只对之后创建的session生效
+func (it *GoMybatisEngine) SetStmtCacheSize(size int) {
+	it.initCheck()
+	it.stmtCacheSize = size
+}
+
+//session的预编译语句缓存容量
+func (it *GoMybatisEngine) StmtCacheSize() int {
+	return it.stmtCacheSize
+}
+
+//${}sql注入检查，默认不检查（RawGuardMode_Off），需在 WriteMapperPtr 之前启用
+func (it *GoMybatisEngine) RawSqlGuard() *RawSqlGuard {
+	return it.rawSqlGuard
+}
+
+//sql日志参数脱敏
+func (it *GoMybatisEngine) LogRedactor() *LogRedactor {
+	return it.logRedactor
+}
+
+//多租户行过滤，默认不启用，需在 WriteMapperPtr 之前启用
+func (it *GoMybatisEngine) TenantFilter() *TenantFilter {
+	return it.tenantFilter
+}
+
 func (it *GoMybatisEngine) RegisterObj(ptr interface{}, name string) {
 	var v = reflect.ValueOf(ptr)
 	if v.Kind() != reflect.Ptr {
@@ -254,3 +415,36 @@ func (it *GoMybatisEngine) GoroutineIDEnable() bool {
 func (it *GoMybatisEngine) LogSystem() *LogSystem {
 	return it.logSystem
 }
+
+func (it *GoMybatisEngine) lifecycle() *engineLifecycle {
+	return it.lifecycleState
+}
+
+//关闭引擎：不再创建新session，等待执行中的语句、事务和 SessionFactory 中的session结束（最长到ctx结束），
+//超时后关闭剩余的session（未提交的事务回滚）。然后关闭日志系统（输出队列中剩余的日志），停止路由的后台任务（路由需实现 StoppableRouter，例如健康检查），
+//最后关闭数据源路由中的连接池（路由需实现 DataSourceLister）。
+//超时返回ctx.Err()，重复调用返回错误
+func (it *GoMybatisEngine) Shutdown(ctx context.Context) error {
+	it.initCheck()
+	if !it.lifecycleState.beginShutdown() {
+		return utils.NewError("GoMybatisEngine", "engine is shutdown!")
+	}
+	var err = it.lifecycleState.wait(ctx, func() bool {
+		return sessionMapEmpty(it.sessionFactory)
+	})
+	if err != nil && it.sessionFactory != nil {
+		it.sessionFactory.CloseAll("")
+	}
+	if it.logSystem != nil {
+		it.logSystem.Close()
+	}
+	if stoppable, ok := it.dataSourceRouter.(StoppableRouter); ok {
+		stoppable.Stop()
+	}
+	if lister, ok := it.dataSourceRouter.(DataSourceLister); ok {
+		for _, db := range lister.DataSources() {
+			db.Close()
+		}
+	}
+	return err
+}
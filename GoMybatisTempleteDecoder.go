@@ -9,12 +9,16 @@ import (
 	"strings"
 )
 
+//批量模板生成的语句记录切片参数名，执行期切片为空时不执行（影响行数为0），避免生成 id in () 之类的非法sql
+const batchCollectionAttr = "_batchCollection"
+
 var equalOperator = []string{"/", "+", "-", "*", "**", "|", "^", "&", "%", "<", ">", ">=", "<=", " in ", " not in ", " or ", "||", " and ", "&&", "==", "!="}
 
 /**
 TODO sqlTemplete解析器，目前直接操作*etree.Element实现，后期应该改成操作xml，换取更好的维护性
 */
 type GoMybatisTempleteDecoder struct {
+	tenantFilter *TenantFilter //多租户行过滤，为nil时不处理
 }
 
 type LogicDeleteData struct {
@@ -65,6 +69,9 @@ func (it *GoMybatisTempleteDecoder) DecodeTree(tree map[string]etree.Token, bean
 			var success, _ = it.Decode(method, newTree, tree)
 			newTree.Child = append(newTree.Child, oldChilds...)
 			*v = *newTree
+			if success {
+				checkCriteriaOrderBy(v)
+			}
 
 			//println
 			if success {
@@ -102,7 +109,14 @@ func printElement(element *etree.Element, v *string) {
 	*v += "</" + element.Tag + ">\n"
 }
 
+//设置多租户行过滤，GoMybatisEngine创建的解析器使用引擎的 TenantFilter()
+func (it *GoMybatisTempleteDecoder) SetTenantFilter(filter *TenantFilter) {
+	it.tenantFilter = filter
+}
+
 func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *etree.Element, tree map[string]etree.Token) (bool, error) {
+	var templeteTag = mapper.Tag
+	var tenantColumn = it.tenantFilter.statementColumn(mapper)
 
 	switch mapper.Tag {
 
@@ -121,6 +135,8 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 		var resultMap = mapper.SelectAttrValue("resultMap", "")
 		if resultMap == "" {
 			resultMap = "BaseResultMap"
+			//写回resultMap，<orderBy>据此将属性名转为列名（见 resolveOrderByColumns）
+			mapper.CreateAttr("resultMap", resultMap)
 		}
 		var resultMapData = tree[resultMap].(*etree.Element)
 		if resultMapData == nil {
@@ -138,13 +154,17 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 		sql.WriteString(columns)
 		sql.WriteString(" from ")
 		sql.WriteString(tables)
-		if len(wheres) > 0 {
+		var criteriaName = findCriteriaArgName(method)
+		if len(wheres) > 0 || criteriaName != "" || tenantColumn != "" {
 			//sql.WriteString(" where ")
 			mapper.Child = append(mapper.Child, &etree.CharData{
 				Data: sql.String(),
 			})
 			//TODO decode wheres
 			it.DecodeWheres(wheres, mapper, logic, nil)
+			if criteriaName != "" {
+				appendCriteriaElement(mapper, criteriaName, false)
+			}
 		}
 		break
 	case "insertTemplete": //已支持批量
@@ -161,6 +181,8 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 		var resultMap = mapper.SelectAttrValue("resultMap", "")
 		if resultMap == "" {
 			resultMap = "BaseResultMap"
+			//写回resultMap，执行期据此查找 <id generator=""/> 主键生成器
+			mapper.CreateAttr("resultMap", resultMap)
 		}
 		if inserts == "" {
 			inserts = "*?*"
@@ -175,6 +197,8 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 		var logic = it.decodeLogicDelete(resultMapData)
 
 		var collectionName = it.DecodeCollectionName(method)
+		//启用租户时租户列由租户值写入
+		var insertElements = tenantInsertElements(resultMapData, tenantColumn)
 
 		//start builder
 		var sql bytes.Buffer
@@ -198,7 +222,7 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 
 		//cloumns
 		if collectionName != "" {
-			for _, v := range resultMapData.ChildElements() {
+			for _, v := range insertElements {
 				if inserts == "*" || inserts == "*?*" {
 					trimColumn.Child = append(trimColumn.Child, &etree.CharData{
 						Data: v.SelectAttrValue("column", "") + ",",
@@ -206,7 +230,7 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 				}
 			}
 		} else {
-			for _, v := range resultMapData.ChildElements() {
+			for _, v := range insertElements {
 				if collectionName == "" && inserts == "*?*" {
 					trimColumn.Child = append(trimColumn.Child, &etree.Element{
 						Tag: Element_If,
@@ -227,6 +251,11 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 			}
 		}
 
+		if tenantColumn != "" {
+			trimColumn.Child = append(trimColumn.Child, &etree.CharData{
+				Data: tenantColumn + ",",
+			})
+		}
 		mapper.Child = append(mapper.Child, &trimColumn)
 
 		//args
@@ -237,7 +266,7 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 		}
 
 		if collectionName == "" {
-			for _, v := range resultMapData.ChildElements() {
+			for _, v := range insertElements {
 				if logic.Enable && v.SelectAttrValue("property", "") == logic.Property {
 					tempElement.Child = append(tempElement.Child, &etree.CharData{
 						Data: logic.Undelete_value + ",",
@@ -260,48 +289,30 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 					})
 				}
 			}
+			if tenantColumn != "" {
+				tempElement.Child = append(tempElement.Child, &etree.CharData{
+					Data: "#{" + TenantVar + "},",
+				})
+			}
 		} else {
 			tempElement.Attr = []etree.Attr{}
 			tempElement.Tag = Element_Foreach
 			tempElement.Attr = []etree.Attr{{Key: "open", Value: "values "}, {Key: "close", Value: ""}, {Key: "separator", Value: ","}, {Key: "collection", Value: collectionName}}
 			tempElement.Child = []etree.Token{}
-			for index, v := range resultMapData.ChildElements() {
+			for index, v := range insertElements {
 				var prefix = ""
 				if index == 0 {
 					prefix = "("
 				}
-				//TODO serch property
-				var defProperty = v.SelectAttrValue("property", "")
-				if method != nil {
-					for i := 0; i < method.Type.NumIn(); i++ {
-						var argItem = method.Type.In(i)
-						if argItem.Kind() == reflect.Ptr {
-							argItem = argItem.Elem()
-						}
-						if argItem.Kind() == reflect.Slice || argItem.Kind() == reflect.Array {
-							argItem = argItem.Elem()
-						}
-						if argItem.Kind() == reflect.Struct {
-							for k := 0; k < argItem.NumField(); k++ {
-								var argStructField = argItem.Field(k)
-								var js = argStructField.Tag.Get("json") //扫描json tag
-								if strings.Index(js, ",") != -1 {
-									js = strings.Split(js, ",")[0]
-								}
-								if strings.ToLower(strings.Replace(defProperty, "_", "", -1)) ==
-									strings.ToLower(strings.Replace(argStructField.Name, "_", "", -1)) ||
-									js == defProperty {
-									defProperty = argStructField.Name
-								}
-							}
-						}
-					}
-				}
+				var defProperty = it.findArgFieldName(method, v.SelectAttrValue("property", ""))
 				var value = prefix + "#{" + "item." + defProperty + "}"
 				if logic.Enable && v.SelectAttrValue("property", "") == logic.Property {
 					value = `'` + logic.Undelete_value + "'"
 				}
-				if index+1 == len(resultMapData.ChildElements()) {
+				if index+1 == len(insertElements) {
+					if tenantColumn != "" {
+						value += ",#{" + TenantVar + "}"
+					}
 					value += ")"
 				} else {
 					value += ","
@@ -341,6 +352,13 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 
 		var versionData = it.decodeVersionData(resultMapData)
 
+		//参数为结构体切片时批量更新
+		var collectionName = it.DecodeCollectionName(method)
+		if collectionName != "" && it.decodeCollectionElemType(method).Kind() == reflect.Struct {
+			it.decodeBatchUpdate(method, mapper, resultMapData, tables, columns, wheres, collectionName, logic, versionData)
+			break
+		}
+
 		var sql bytes.Buffer
 		sql.WriteString("update ")
 		sql.WriteString(tables)
@@ -369,7 +387,7 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 			sql.Reset()
 			it.DecodeSets(columns, mapper, LogicDeleteData{}, versionData)
 		}
-		if len(wheres) > 0 || logic.Enable {
+		if len(wheres) > 0 || logic.Enable || tenantColumn != "" {
 			//sql.WriteString(" where ")
 			mapper.Child = append(mapper.Child, &etree.CharData{
 				Data: sql.String(),
@@ -400,6 +418,14 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 		checkTablesValue(mapper, &tables, resultMapData)
 
 		var logic = it.decodeLogicDelete(resultMapData)
+		var criteriaName = findCriteriaArgName(method)
+
+		//参数为切片时按主键批量删除
+		var collectionName = it.DecodeCollectionName(method)
+		if collectionName != "" {
+			it.decodeBatchDelete(method, mapper, resultMapData, tables, wheres, collectionName, logic)
+			break
+		}
 		if logic.Enable {
 			//enable logic delete
 			var sql bytes.Buffer
@@ -411,13 +437,16 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 			})
 			sql.Reset()
 			it.DecodeSets("", mapper, logic, nil)
-			if len(wheres) > 0 {
+			if len(wheres) > 0 || criteriaName != "" {
 				//sql.WriteString(" where ")
 				mapper.Child = append(mapper.Child, &etree.CharData{
 					Data: sql.String(),
 				})
 				//TODO decode wheres
 				it.DecodeWheres(wheres, mapper, logic, nil)
+				if criteriaName != "" {
+					appendCriteriaElement(mapper, criteriaName, wheres == "")
+				}
 			}
 			break
 		} else {
@@ -425,22 +454,442 @@ func (it *GoMybatisTempleteDecoder) Decode(method *reflect.StructField, mapper *
 			var sql bytes.Buffer
 			sql.WriteString("delete from ")
 			sql.WriteString(tables)
-			if len(wheres) > 0 {
+			if len(wheres) > 0 || criteriaName != "" {
 				//sql.WriteString(" where ")
 				mapper.Child = append(mapper.Child, &etree.CharData{
 					Data: sql.String(),
 				})
 				//TODO decode wheres
 				it.DecodeWheres(wheres, mapper, LogicDeleteData{}, nil)
+				if criteriaName != "" {
+					appendCriteriaElement(mapper, criteriaName, wheres == "")
+				}
+			}
+		}
+	case "upsertTemplete": //已支持批量
+		mapper.Tag = Element_Insert
+
+		var id = mapper.SelectAttrValue("id", "")
+		if id == "" {
+			mapper.CreateAttr("id", "upsertTemplete")
+		}
+
+		var tables = mapper.SelectAttrValue("tables", "")
+		var conflict = mapper.SelectAttrValue("conflict", "")
+		var dialectName = mapper.SelectAttrValue("dialect", "")
+
+		var resultMap = mapper.SelectAttrValue("resultMap", "")
+		if resultMap == "" {
+			resultMap = "BaseResultMap"
+			//写回resultMap，执行期据此查找 <id generator=""/> 主键生成器
+			mapper.CreateAttr("resultMap", resultMap)
+		}
+
+		var resultMapData = tree[resultMap].(*etree.Element)
+		if resultMapData == nil {
+			panic(utils.NewError("GoMybatisTempleteDecoder", "resultMap not define! id = ", resultMap))
+		}
+		checkTablesValue(mapper, &tables, resultMapData)
+
+		var dialect = Dialect_MySQL
+		if dialectName != "" {
+			dialect = DialectOf(dialectName)
+			if dialect == "" {
+				panic(utils.NewError("GoMybatisTempleteDecoder", "<upsertTemplete> not support dialect = ", dialectName, ",mapper id = ", mapper.SelectAttrValue("id", "")))
 			}
 		}
 
+		var logic = it.decodeLogicDelete(resultMapData)
+		var versionData = it.decodeVersionData(resultMapData)
+		var collectionName = it.DecodeCollectionName(method)
+
+		it.decodeUpsert(method, mapper, resultMapData, tables, conflict, dialect, collectionName, logic, versionData, tenantColumn)
+		break
 	default:
 		return false, nil
 	}
+	if tenantColumn != "" {
+		switch templeteTag {
+		case "selectTemplete", "updateTemplete", "deleteTemplete":
+			appendTenantWheres(mapper, tenantColumn)
+		}
+		//执行期据此绑定租户值
+		mapper.CreateAttr(TenantAttr, "true")
+	}
 	return true, nil
 }
 
+//生成upsert语句。冲突列取 conflict 属性（逗号分隔的列名），否则取 resultMap 中的 <id> 列；
+//冲突时更新除冲突列外的全部列（逻辑删除列恢复为未删除值），启用乐观锁时只更新版本号相等的行并使版本号+1
+func (it *GoMybatisTempleteDecoder) decodeUpsert(method *reflect.StructField, mapper *etree.Element, resultMapData *etree.Element, tables string, conflict string, dialect Dialect, collectionName string, logic LogicDeleteData, versionData *VersionData, tenantColumn string) {
+	var columns = []string{}
+	var values = []string{}
+	var conflictColumns = []string{}
+	for _, v := range tenantInsertElements(resultMapData, tenantColumn) {
+		var column = v.SelectAttrValue("column", "")
+		var property = v.SelectAttrValue("property", "")
+		var value string
+		if logic.Enable && property == logic.Property {
+			value = `'` + logic.Undelete_value + "'"
+		} else if collectionName != "" {
+			value = "#{item." + it.findArgFieldName(method, property) + "}"
+		} else {
+			value = "#{" + property + "}"
+		}
+		columns = append(columns, column)
+		values = append(values, value)
+		if conflict == "" && v.Tag == "id" {
+			conflictColumns = append(conflictColumns, column)
+		}
+	}
+	if tenantColumn != "" {
+		columns = append(columns, tenantColumn)
+		values = append(values, "#{"+TenantVar+"}")
+	}
+	if conflict != "" {
+		for _, c := range strings.Split(conflict, ",") {
+			c = strings.TrimSpace(c)
+			if c == "" {
+				continue
+			}
+			var find = false
+			for _, column := range columns {
+				if column == c {
+					find = true
+					break
+				}
+			}
+			if !find {
+				panic(utils.NewError("GoMybatisTempleteDecoder", "<upsertTemplete> conflict column '", c, "' not define in resultMap! mapper id = ", mapper.SelectAttrValue("id", "")))
+			}
+			conflictColumns = append(conflictColumns, c)
+		}
+	}
+	if len(conflictColumns) == 0 {
+		panic(utils.NewError("GoMybatisTempleteDecoder", "<upsertTemplete> need attribute conflict=\"\" or <id> in resultMap! mapper id = ", mapper.SelectAttrValue("id", "")))
+	}
+
+	//冲突时需要更新的列，版本号列单独处理
+	var updateColumns = []string{}
+	for _, column := range columns {
+		var isConflict = false
+		for _, c := range conflictColumns {
+			if c == column {
+				isConflict = true
+				break
+			}
+		}
+		if isConflict || (versionData != nil && column == versionData.Column) || column == tenantColumn {
+			continue
+		}
+		updateColumns = append(updateColumns, column)
+	}
+
+	var columnsSql = strings.Join(columns, ",")
+	var head bytes.Buffer
+	var tail bytes.Buffer
+	switch dialect {
+	case Dialect_SQLServer:
+		head.WriteString("merge into " + tables + " as t using (values ")
+		tail.WriteString(") as s (" + columnsSql + ") on ")
+		for i, c := range conflictColumns {
+			if i > 0 {
+				tail.WriteString(" and ")
+			}
+			tail.WriteString("t." + c + " = s." + c)
+		}
+		if len(updateColumns) != 0 || versionData != nil {
+			tail.WriteString(" when matched")
+			if versionData != nil {
+				tail.WriteString(" and t." + versionData.Column + " = s." + versionData.Column)
+			}
+			tail.WriteString(" then update set ")
+			for i, c := range updateColumns {
+				if i > 0 {
+					tail.WriteString(",")
+				}
+				tail.WriteString(c + " = s." + c)
+			}
+			if versionData != nil {
+				if len(updateColumns) != 0 {
+					tail.WriteString(",")
+				}
+				tail.WriteString(versionData.Column + " = t." + versionData.Column + " + 1")
+			}
+		}
+		tail.WriteString(" when not matched then insert (" + columnsSql + ") values (s." + strings.Join(columns, ",s.") + ");")
+	case Dialect_Postgres, Dialect_SQLite:
+		head.WriteString("insert into " + tables + " (" + columnsSql + ") values ")
+		tail.WriteString(" on conflict (" + strings.Join(conflictColumns, ",") + ") do ")
+		if len(updateColumns) == 0 && versionData == nil {
+			tail.WriteString("nothing")
+			break
+		}
+		tail.WriteString("update set ")
+		for i, c := range updateColumns {
+			if i > 0 {
+				tail.WriteString(",")
+			}
+			tail.WriteString(c + " = excluded." + c)
+		}
+		if versionData != nil {
+			if len(updateColumns) != 0 {
+				tail.WriteString(",")
+			}
+			tail.WriteString(versionData.Column + " = " + tables + "." + versionData.Column + " + 1")
+			tail.WriteString(" where " + tables + "." + versionData.Column + " = excluded." + versionData.Column)
+		}
+	default:
+		head.WriteString("insert into " + tables + " (" + columnsSql + ") values ")
+		tail.WriteString(" on duplicate key update ")
+		if len(updateColumns) == 0 && versionData == nil {
+			tail.WriteString(conflictColumns[0] + " = " + conflictColumns[0])
+			break
+		}
+		//mysql不支持条件更新，按版本号是否相等选择新值或旧值，版本号列必须最后赋值
+		for i, c := range updateColumns {
+			if i > 0 {
+				tail.WriteString(",")
+			}
+			if versionData != nil {
+				tail.WriteString(c + " = if(" + versionData.Column + " = values(" + versionData.Column + "),values(" + c + ")," + c + ")")
+			} else {
+				tail.WriteString(c + " = values(" + c + ")")
+			}
+		}
+		if versionData != nil {
+			if len(updateColumns) != 0 {
+				tail.WriteString(",")
+			}
+			tail.WriteString(versionData.Column + " = if(" + versionData.Column + " = values(" + versionData.Column + ")," + versionData.Column + " + 1," + versionData.Column + ")")
+		}
+	}
+
+	var row = "(" + strings.Join(values, ",") + ")"
+	mapper.Child = append(mapper.Child, &etree.CharData{
+		Data: head.String(),
+	})
+	if collectionName != "" {
+		mapper.Child = append(mapper.Child, &etree.Element{
+			Tag:   Element_Foreach,
+			Attr:  []etree.Attr{{Key: "collection", Value: collectionName}, {Key: "separator", Value: ","}},
+			Child: []etree.Token{&etree.CharData{Data: row}},
+		})
+	} else {
+		mapper.Child = append(mapper.Child, &etree.CharData{
+			Data: row,
+		})
+	}
+	mapper.Child = append(mapper.Child, &etree.CharData{
+		Data: tail.String(),
+	})
+}
+
+//在方法参数（或参数集合元素）的结构体中查找property对应的字段名，找不到返回property本身
+func (it *GoMybatisTempleteDecoder) findArgFieldName(method *reflect.StructField, property string) string {
+	var defProperty = property
+	if method != nil {
+		for i := 0; i < method.Type.NumIn(); i++ {
+			var argItem = method.Type.In(i)
+			if argItem.Kind() == reflect.Ptr {
+				argItem = argItem.Elem()
+			}
+			if argItem.Kind() == reflect.Slice || argItem.Kind() == reflect.Array {
+				argItem = argItem.Elem()
+			}
+			if argItem.Kind() == reflect.Struct {
+				for k := 0; k < argItem.NumField(); k++ {
+					var argStructField = argItem.Field(k)
+					var js = argStructField.Tag.Get("json") //扫描json tag
+					if strings.Index(js, ",") != -1 {
+						js = strings.Split(js, ",")[0]
+					}
+					if strings.ToLower(strings.Replace(property, "_", "", -1)) ==
+						strings.ToLower(strings.Replace(argStructField.Name, "_", "", -1)) ||
+						js == property {
+						defProperty = argStructField.Name
+					}
+				}
+			}
+		}
+	}
+	return defProperty
+}
+
+//批量更新，batch="case"(默认)生成一条 case when 语句，batch="statements" 生成分号分隔的多条update语句（需要驱动支持多语句，例如mysql的multiStatements=true）。
+//sets 为空时更新除主键、版本号外的全部列，否则只更新sets中列出的属性（"property" 或 "property?..." 取?前的属性名）；
+//启用乐观锁时每一行都按各自的版本号匹配，版本号不一致的行不会被更新，可通过影响行数判断
+func (it *GoMybatisTempleteDecoder) decodeBatchUpdate(method *reflect.StructField, mapper *etree.Element, resultMapData *etree.Element, tables string, sets string, wheres string, collectionName string, logic LogicDeleteData, versionData *VersionData) {
+	var id = mapper.SelectAttrValue("id", "")
+	var batch = mapper.SelectAttrValue("batch", "case")
+	if batch != "case" && batch != "statements" {
+		panic(utils.NewError("GoMybatisTempleteDecoder", `<updateTemplete> batch must be "case" or "statements"! mapper id = `, id))
+	}
+	var keyColumns, keyProperties = it.decodeKeyColumns(method, resultMapData, mapper)
+	mapper.CreateAttr(batchCollectionAttr, collectionName)
+	var setProperties = map[string]bool{}
+	for _, v := range strings.Split(sets, ",") {
+		var property = strings.TrimSpace(strings.Split(v, "?")[0])
+		if property != "" {
+			setProperties[property] = true
+		}
+	}
+	var updateColumns = []string{}
+	var updateProperties = []string{}
+	for _, v := range resultMapData.ChildElements() {
+		var property = v.SelectAttrValue("property", "")
+		if v.Tag == "id" || v.SelectAttrValue("version_enable", "") == "true" {
+			continue
+		}
+		if logic.Enable && property == logic.Property {
+			continue
+		}
+		if len(setProperties) != 0 && !setProperties[property] {
+			continue
+		}
+		updateColumns = append(updateColumns, v.SelectAttrValue("column", ""))
+		updateProperties = append(updateProperties, it.findArgFieldName(method, property))
+	}
+	if len(updateColumns) == 0 {
+		panic(utils.NewError("GoMybatisTempleteDecoder", "<updateTemplete> no column to update! mapper id = ", id))
+	}
+	var versionSet = ""
+	if versionData != nil {
+		versionSet = "," + versionData.Column + " = " + versionData.Column + " + 1"
+	}
+	//单行匹配条件，例如 id = #{item.Id} and version = #{item.Version}
+	var rowCondition = it.makeKeyCondition(keyColumns, keyProperties)
+	if versionData != nil {
+		rowCondition += " and " + versionData.Column + " = #{item." + it.findArgFieldName(method, versionData.Property) + "}"
+	}
+
+	if batch == "statements" {
+		var foreach = &etree.Element{
+			Tag:   Element_Foreach,
+			Attr:  []etree.Attr{{Key: "collection", Value: collectionName}, {Key: "separator", Value: ";"}},
+			Child: []etree.Token{},
+		}
+		var sql bytes.Buffer
+		sql.WriteString("update " + tables + " set ")
+		for i, c := range updateColumns {
+			if i > 0 {
+				sql.WriteString(",")
+			}
+			sql.WriteString(c + " = #{item." + updateProperties[i] + "}")
+		}
+		sql.WriteString(versionSet)
+		foreach.Child = append(foreach.Child, &etree.CharData{Data: sql.String()})
+		it.appendBatchWheres(foreach, wheres, logic, &etree.CharData{Data: rowCondition})
+		mapper.Child = append(mapper.Child, foreach)
+		return
+	}
+
+	mapper.Child = append(mapper.Child, &etree.CharData{
+		Data: "update " + tables + " set ",
+	})
+	var keyCondition = it.makeKeyCondition(keyColumns, keyProperties)
+	for i, c := range updateColumns {
+		var prefix = ""
+		if i > 0 {
+			prefix = ","
+		}
+		mapper.Child = append(mapper.Child, &etree.CharData{Data: prefix + c + " = case"})
+		mapper.Child = append(mapper.Child, &etree.Element{
+			Tag:   Element_Foreach,
+			Attr:  []etree.Attr{{Key: "collection", Value: collectionName}, {Key: "separator", Value: " "}},
+			Child: []etree.Token{&etree.CharData{Data: " when " + keyCondition + " then #{item." + updateProperties[i] + "}"}},
+		})
+		mapper.Child = append(mapper.Child, &etree.CharData{Data: " else " + c + " end"})
+	}
+	if versionSet != "" {
+		mapper.Child = append(mapper.Child, &etree.CharData{Data: versionSet})
+	}
+	it.appendBatchWheres(mapper, wheres, logic,
+		&etree.CharData{Data: "("},
+		&etree.Element{
+			Tag:   Element_Foreach,
+			Attr:  []etree.Attr{{Key: "collection", Value: collectionName}, {Key: "separator", Value: " or "}},
+			Child: []etree.Token{&etree.CharData{Data: "(" + rowCondition + ")"}},
+		},
+		&etree.CharData{Data: ")"})
+}
+
+//批量删除，生成 id in (...)（复合主键时为 or 连接的条件）。切片元素为结构体时取主键属性，否则切片元素即主键值
+func (it *GoMybatisTempleteDecoder) decodeBatchDelete(method *reflect.StructField, mapper *etree.Element, resultMapData *etree.Element, tables string, wheres string, collectionName string, logic LogicDeleteData) {
+	var keyColumns, keyProperties = it.decodeKeyColumns(method, resultMapData, mapper)
+	var isStruct = it.decodeCollectionElemType(method).Kind() == reflect.Struct
+	mapper.CreateAttr(batchCollectionAttr, collectionName)
+	if logic.Enable {
+		mapper.Child = append(mapper.Child, &etree.CharData{
+			Data: "update " + tables + " set " + logic.Column + " = " + logic.Deleted_value,
+		})
+	} else {
+		mapper.Child = append(mapper.Child, &etree.CharData{
+			Data: "delete from " + tables,
+		})
+	}
+	if len(keyColumns) == 1 {
+		var item = "#{item}"
+		if isStruct {
+			item = "#{item." + keyProperties[0] + "}"
+		}
+		it.appendBatchWheres(mapper, wheres, logic,
+			&etree.Element{
+				Tag:   Element_Foreach,
+				Attr:  []etree.Attr{{Key: "collection", Value: collectionName}, {Key: "open", Value: " " + keyColumns[0] + " in ("}, {Key: "close", Value: ")"}, {Key: "separator", Value: ","}},
+				Child: []etree.Token{&etree.CharData{Data: item}},
+			})
+		return
+	}
+	if !isStruct {
+		panic(utils.NewError("GoMybatisTempleteDecoder", "<deleteTemplete> composite <id> need a struct slice arg! mapper id = ", mapper.SelectAttrValue("id", "")))
+	}
+	it.appendBatchWheres(mapper, wheres, logic,
+		&etree.CharData{Data: "("},
+		&etree.Element{
+			Tag:   Element_Foreach,
+			Attr:  []etree.Attr{{Key: "collection", Value: collectionName}, {Key: "separator", Value: " or "}},
+			Child: []etree.Token{&etree.CharData{Data: "(" + it.makeKeyCondition(keyColumns, keyProperties) + ")"}},
+		},
+		&etree.CharData{Data: ")"})
+}
+
+//resultMap中 <id> 的列名及切片元素中对应的字段名
+func (it *GoMybatisTempleteDecoder) decodeKeyColumns(method *reflect.StructField, resultMapData *etree.Element, mapper *etree.Element) ([]string, []string) {
+	var columns = []string{}
+	var properties = []string{}
+	for _, v := range resultMapData.ChildElements() {
+		if v.Tag == "id" {
+			columns = append(columns, v.SelectAttrValue("column", ""))
+			properties = append(properties, it.findArgFieldName(method, v.SelectAttrValue("property", "")))
+		}
+	}
+	if len(columns) == 0 {
+		panic(utils.NewError("GoMybatisTempleteDecoder", "batch <"+mapper.Tag+"Templete> need <id> in resultMap! mapper id = ", mapper.SelectAttrValue("id", "")))
+	}
+	return columns, properties
+}
+
+//例如 id = #{item.Id} and code = #{item.Code}
+func (it *GoMybatisTempleteDecoder) makeKeyCondition(columns []string, properties []string) string {
+	var condition bytes.Buffer
+	for i, c := range columns {
+		if i > 0 {
+			condition.WriteString(" and ")
+		}
+		condition.WriteString(c + " = #{item." + properties[i] + "}")
+	}
+	return condition.String()
+}
+
+//生成 <where>（逻辑删除条件、wheres）并追加批量匹配条件
+func (it *GoMybatisTempleteDecoder) appendBatchWheres(parent *etree.Element, wheres string, logic LogicDeleteData, condition ...etree.Token) {
+	it.DecodeWheres(wheres, parent, logic, nil)
+	var whereRoot = parent.Child[len(parent.Child)-1].(*etree.Element)
+	whereRoot.Child = append(whereRoot.Child, &etree.CharData{Data: " and "})
+	whereRoot.Child = append(whereRoot.Child, condition...)
+}
+
 func checkTablesValue(mapper *etree.Element, tables *string, resultMapData *etree.Element) {
 	if *tables == "" {
 		*tables = resultMapData.SelectAttrValue("tables", "")
@@ -624,6 +1073,24 @@ func (it *GoMybatisTempleteDecoder) decodeVersionData(xml *etree.Element) *Versi
 	return nil
 }
 
+//反射解码得到 集合参数的元素类型（指针取其指向的类型），没有集合参数返回nil
+func (it *GoMybatisTempleteDecoder) decodeCollectionElemType(method *reflect.StructField) reflect.Type {
+	if method == nil {
+		return nil
+	}
+	for i := 0; i < method.Type.NumIn(); i++ {
+		var itemType = method.Type.In(i)
+		if itemType.Kind() == reflect.Slice || itemType.Kind() == reflect.Array {
+			var elemType = itemType.Elem()
+			if elemType.Kind() == reflect.Ptr {
+				elemType = elemType.Elem()
+			}
+			return elemType
+		}
+	}
+	return nil
+}
+
 //反射解码得到 集合名词
 func (it *GoMybatisTempleteDecoder) DecodeCollectionName(method *reflect.StructField) string {
 	var collection string
@@ -1,8 +1,12 @@
 package GoMybatis
 
 import (
+	"database/sql"
+	"errors"
+	"github.com/zhuxiujia/GoMybatis/ast"
 	"github.com/zhuxiujia/GoMybatis/example"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -46,3 +50,37 @@ func BenchmarkScanStructArgFields(b *testing.B) {
 		scanStructArgFields(reflect.ValueOf(act), nil)
 	}
 }
+
+//记录执行的sql和参数，不连接数据库
+type CaptureSession struct {
+	TestSession
+	Sqls [][]interface{}
+}
+
+func (it *CaptureSession) ProcessSQL(sql string) string {
+	return strings.ReplaceAll(sql, ast.SQLPlaceholder, "?")
+}
+func (it *CaptureSession) ExecPrepare(sqlorArgs string, args ...interface{}) (*Result, error) {
+	it.Sqls = append(it.Sqls, append([]interface{}{sqlorArgs}, args...))
+	return &Result{RowsAffected: 1}, nil
+}
+//查询同样只记录sql，返回 errCaptureQuery
+func (it *CaptureSession) QueryPrepareNew(sqlorArgs string, args ...interface{}) (*sql.Rows, error) {
+	it.Sqls = append(it.Sqls, append([]interface{}{sqlorArgs}, args...))
+	return nil, errCaptureQuery
+}
+
+var errCaptureQuery = errors.New("CaptureSession: query not executed")
+
+func (it *CaptureSession) LastSql() string {
+	if len(it.Sqls) == 0 {
+		return ""
+	}
+	return it.Sqls[len(it.Sqls)-1][0].(string)
+}
+func (it *CaptureSession) LastArgs() []interface{} {
+	if len(it.Sqls) == 0 {
+		return nil
+	}
+	return it.Sqls[len(it.Sqls)-1][1:]
+}
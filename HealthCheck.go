@@ -0,0 +1,234 @@
+package GoMybatis
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//默认健康检查间隔
+const DefaultHealthCheckInterval = 10 * time.Second
+
+//默认ping超时
+const DefaultHealthCheckTimeout = 3 * time.Second
+
+//数据源健康状态变化事件
+type HealthEvent struct {
+	DataSource string    //数据源名称，见 DataSourceLister.DataSources
+	Healthy    bool      //变化后的状态
+	Err        error     //变为不健康时ping的错误
+	Time       time.Time //检查时间
+}
+
+//数据源健康状态
+type DataSourceHealth struct {
+	Healthy   bool      //是否健康，未检查过的数据源视为健康
+	Failures  int       //连续ping失败次数
+	LastErr   error     //最后一次ping的错误
+	LastCheck time.Time //最后一次检查时间
+}
+
+//数据源健康检查，定时ping路由中的全部数据源（路由需实现 DataSourceLister），连续失败达到阈值标记为不健康，ping成功恢复。例如
+//
+//	var checker = GoMybatis.HealthChecker{}.New(&router)
+//	checker.OnChange(func(event GoMybatis.HealthEvent) { log.Println(event) })
+//	router.SetHealthChecker(&checker) //GoMybatisDataSourceRouter、ReadWriteDataSourceRouter 路由时避开不健康的数据源
+//	checker.Start()
+//	defer checker.Stop()
+type HealthChecker struct {
+	mutex         *sync.Mutex
+	lister        DataSourceLister
+	interval      time.Duration
+	timeout       time.Duration
+	failThreshold int
+	status        map[string]*DataSourceHealth
+	listeners     []func(event HealthEvent)
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+func (it HealthChecker) New(lister DataSourceLister) HealthChecker {
+	if lister == nil {
+		panic("[GoMybatis] HealthChecker lister can not be nil!")
+	}
+	it.mutex = &sync.Mutex{}
+	it.lister = lister
+	it.interval = DefaultHealthCheckInterval
+	it.timeout = DefaultHealthCheckTimeout
+	it.failThreshold = 1
+	it.status = map[string]*DataSourceHealth{}
+	it.listeners = []func(event HealthEvent){}
+	return it
+}
+
+//检查间隔，Start 之前设置
+func (it *HealthChecker) SetInterval(interval time.Duration) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	if interval > 0 {
+		it.interval = interval
+	}
+}
+
+//单次ping超时
+func (it *HealthChecker) SetTimeout(timeout time.Duration) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	if timeout > 0 {
+		it.timeout = timeout
+	}
+}
+
+//连续失败多少次标记为不健康，默认1
+func (it *HealthChecker) SetFailThreshold(threshold int) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	if threshold > 0 {
+		it.failThreshold = threshold
+	}
+}
+
+//监听健康状态变化，回调在检查的goroutine中同步执行
+func (it *HealthChecker) OnChange(listener func(event HealthEvent)) {
+	if listener == nil {
+		return
+	}
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.listeners = append(it.listeners, listener)
+}
+
+//启动定时检查（立即检查一次），重复调用返回错误
+func (it *HealthChecker) Start() error {
+	it.mutex.Lock()
+	if it.stop != nil {
+		it.mutex.Unlock()
+		return utils.NewError("HealthChecker", "health checker is started!")
+	}
+	var stop, done = make(chan struct{}), make(chan struct{})
+	it.stop, it.done = stop, done
+	var interval = it.interval
+	it.mutex.Unlock()
+	go func() {
+		defer close(done)
+		var ticker = time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			it.CheckNow()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+//停止定时检查并等待正在进行的检查结束
+func (it *HealthChecker) Stop() {
+	it.mutex.Lock()
+	var stop, done = it.stop, it.done
+	it.stop, it.done = nil, nil
+	it.mutex.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+//立即检查全部数据源，返回本次产生的状态变化事件
+func (it *HealthChecker) CheckNow() []HealthEvent {
+	var dataSources = it.lister.DataSources()
+	var names = make([]string, 0, len(dataSources))
+	for name := range dataSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	it.mutex.Lock()
+	var timeout = it.timeout
+	it.mutex.Unlock()
+
+	var events = []HealthEvent{}
+	for _, name := range names {
+		var err = pingDataSource(dataSources[name], timeout)
+		if event := it.record(name, err); event != nil {
+			events = append(events, *event)
+		}
+	}
+	it.mutex.Lock()
+	//移除已不存在的数据源（例如淘汰的租户数据库）
+	for name := range it.status {
+		if _, ok := dataSources[name]; !ok {
+			delete(it.status, name)
+		}
+	}
+	var listeners = it.listeners
+	it.mutex.Unlock()
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+	return events
+}
+
+//记录一次ping结果，状态变化时返回事件
+func (it *HealthChecker) record(name string, err error) *HealthEvent {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	var health = it.status[name]
+	if health == nil {
+		health = &DataSourceHealth{Healthy: true}
+		it.status[name] = health
+	}
+	health.LastCheck = time.Now()
+	health.LastErr = err
+	if err == nil {
+		health.Failures = 0
+		if !health.Healthy {
+			health.Healthy = true
+			return &HealthEvent{DataSource: name, Healthy: true, Time: health.LastCheck}
+		}
+		return nil
+	}
+	health.Failures++
+	if health.Healthy && health.Failures >= it.failThreshold {
+		health.Healthy = false
+		return &HealthEvent{DataSource: name, Healthy: false, Err: err, Time: health.LastCheck}
+	}
+	return nil
+}
+
+//数据源是否健康，未检查过的数据源视为健康
+func (it *HealthChecker) IsHealthy(name string) bool {
+	if it == nil {
+		return true
+	}
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	var health = it.status[name]
+	return health == nil || health.Healthy
+}
+
+//全部已检查数据源的健康状态
+func (it *HealthChecker) Status() map[string]DataSourceHealth {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	var status = make(map[string]DataSourceHealth, len(it.status))
+	for name, health := range it.status {
+		status[name] = *health
+	}
+	return status
+}
+
+func pingDataSource(db *sql.DB, timeout time.Duration) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
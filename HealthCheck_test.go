@@ -0,0 +1,113 @@
+package GoMybatis
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type HealthTestMapper struct {
+	SelectAll func() ([]map[string]string, error)
+}
+
+func newHealthTestMapper(router DataSourceRouter) HealthTestMapper {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	engine.SetDataSourceRouter(router)
+	var mapper HealthTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectAll">select * from biz_activity</select>
+</mapper>`))
+	return mapper
+}
+
+func setTestPingErr(dsn string, err error) {
+	var state = testDriverState(dsn)
+	state.lock(func() { state.PingErr = err })
+}
+
+func TestHealthChecker_Failover(t *testing.T) {
+	resetTestDriverState("TestHealth_primary", "TestHealth_standby")
+	var router = GoMybatisDataSourceRouter{}.New(nil)
+	for _, name := range []string{"primary", "standby"} {
+		db, _ := openPool(TestDriverName, "TestHealth_"+name, PoolOptions{})
+		router.RegisterDataSource(name, TestDriverName, "TestHealth_"+name, db)
+	}
+	router.SetFailover("standby")
+	var checker = HealthChecker{}.New(&router)
+	checker.SetFailThreshold(2)
+	var events = []HealthEvent{}
+	checker.OnChange(func(event HealthEvent) {
+		events = append(events, event)
+	})
+	router.SetHealthChecker(&checker)
+	var mapper = newHealthTestMapper(&router)
+
+	setTestPingErr("TestHealth_primary", errors.New("connection refused"))
+	defer setTestPingErr("TestHealth_primary", nil)
+	checker.CheckNow()
+	if !checker.IsHealthy("primary") || router.ActiveDataSource() != "primary" {
+		t.Fatal("mark unhealthy before threshold")
+	}
+	checker.CheckNow()
+	if checker.IsHealthy("primary") || len(events) != 1 || events[0].DataSource != "primary" || events[0].Healthy || events[0].Err == nil {
+		t.Fatal("mark unhealthy fail:", events)
+	}
+	if _, err := mapper.SelectAll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(testDriverState("TestHealth_standby").Queries) != 1 {
+		t.Fatal("failover fail")
+	}
+
+	//恢复
+	setTestPingErr("TestHealth_primary", nil)
+	checker.CheckNow()
+	if router.ActiveDataSource() != "primary" || len(events) != 2 || !events[1].Healthy {
+		t.Fatal("recover fail:", events)
+	}
+
+	//定时检查
+	checker.SetInterval(time.Millisecond)
+	if err := checker.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.Start(); err == nil {
+		t.Fatal("start twice must fail")
+	}
+	checker.Stop()
+	if checker.Status()["standby"].LastCheck.IsZero() {
+		t.Fatal("periodic check fail")
+	}
+}
+
+func TestHealthChecker_Replica(t *testing.T) {
+	resetTestDriverState("TestHealthRW_master", "TestHealthRW_replica0", "TestHealthRW_replica1")
+	var router = ReadWriteDataSourceRouter{}.New(ReplicaStrategy_RoundRobin)
+	var master, _ = openPool(TestDriverName, "TestHealthRW_master", PoolOptions{})
+	router.SetDB(TestDriverName, "TestHealthRW_master", master)
+	router.OpenReplica(TestDriverName, "TestHealthRW_replica0", 1)
+	router.OpenReplica(TestDriverName, "TestHealthRW_replica1", 1)
+	var checker = HealthChecker{}.New(&router)
+	router.SetHealthChecker(&checker)
+	var mapper = newHealthTestMapper(&router)
+
+	setTestPingErr("TestHealthRW_replica0", errors.New("down"))
+	checker.CheckNow()
+	for i := 0; i < 3; i++ {
+		mapper.SelectAll()
+	}
+	if len(testDriverState("TestHealthRW_replica0").Queries) != 0 || len(testDriverState("TestHealthRW_replica1").Queries) != 3 {
+		t.Fatal("route away from unhealthy replica fail")
+	}
+	//从库都不健康时使用主库
+	setTestPingErr("TestHealthRW_replica1", errors.New("down"))
+	defer setTestPingErr("TestHealthRW_replica0", nil)
+	defer setTestPingErr("TestHealthRW_replica1", nil)
+	checker.CheckNow()
+	mapper.SelectAll()
+	if len(testDriverState("TestHealthRW_master").Queries) != 1 {
+		t.Fatal("all replicas unhealthy must use master")
+	}
+}
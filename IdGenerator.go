@@ -0,0 +1,170 @@
+package GoMybatis
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+const (
+	IdGenerator_Snowflake = "snowflake"
+	IdGenerator_UUID      = "uuid"
+	IdGenerator_ULID      = "ulid"
+)
+
+//主键生成器，用于 <id generator="..."/> 在插入前填充空主键
+type IdGenerator interface {
+	//生成一个新的主键值
+	Generate() (interface{}, error)
+}
+
+//支持主键生成器的引擎（GoMybatisEngine），SessionEngine 的可选扩展
+type idGeneratorEngine interface {
+	IdGenerator(name string) IdGenerator
+}
+
+//引擎中注册的主键生成器，引擎不支持时返回nil
+func engineIdGenerator(sessionEngine SessionEngine, name string) IdGenerator {
+	if engine, ok := sessionEngine.(idGeneratorEngine); ok {
+		return engine.IdGenerator(name)
+	}
+	return nil
+}
+
+//uuid 主键生成器
+type UUIDIdGenerator struct {
+}
+
+func (it UUIDIdGenerator) Generate() (interface{}, error) {
+	return utils.CreateUUID(), nil
+}
+
+//ulid 主键生成器（按时间有序）
+type ULIDIdGenerator struct {
+}
+
+func (it ULIDIdGenerator) Generate() (interface{}, error) {
+	return utils.CreateULID(), nil
+}
+
+//插入前为参数中为空的主键属性填充生成的值，slice、数组参数的每个元素各自生成。值传递的结构体、数组填充副本，调用方需传指针或slice才能读取生成的主键
+func fillGeneratedIds(engine SessionEngine, properties []*ResultProperty, proxyArg *ProxyArg) error {
+	for argIndex, arg := range proxyArg.Args {
+		var newArg, err = fillArgIds(engine, properties, arg)
+		if err != nil {
+			return err
+		}
+		proxyArg.Args[argIndex] = newArg
+	}
+	return nil
+}
+
+func fillArgIds(engine SessionEngine, properties []*ResultProperty, arg reflect.Value) (reflect.Value, error) {
+	if !arg.IsValid() {
+		return arg, nil
+	}
+	switch arg.Kind() {
+	case reflect.Ptr:
+		if arg.IsNil() || arg.Elem().Kind() != reflect.Struct || !isCustomStruct(arg.Elem().Type()) {
+			return arg, nil
+		}
+		return arg, fillStructIds(engine, properties, arg.Elem())
+	case reflect.Struct:
+		if arg.Type().String() == GoMybatis_Time {
+			return arg, nil
+		}
+		if !arg.CanSet() {
+			//值传递的结构体不可寻址，复制一份再填充
+			var copied = reflect.New(arg.Type()).Elem()
+			copied.Set(arg)
+			arg = copied
+		}
+		return arg, fillStructIds(engine, properties, arg)
+	case reflect.Slice, reflect.Array:
+		if arg.Kind() == reflect.Array && !arg.CanSet() {
+			//值传递的数组元素不可寻址，复制一份再填充（调用方的数组不会更新，需要回填主键请传指针或slice）
+			var copied = reflect.New(arg.Type()).Elem()
+			copied.Set(arg)
+			arg = copied
+		}
+		for i := 0; i < arg.Len(); i++ {
+			var item = arg.Index(i)
+			if item.Kind() == reflect.Interface && !item.IsNil() {
+				//interface中的结构体不可寻址，填充副本后写回
+				var filled, err = fillArgIds(engine, properties, item.Elem())
+				if err != nil {
+					return arg, err
+				}
+				item.Set(filled)
+				continue
+			}
+			if _, err := fillArgIds(engine, properties, item); err != nil {
+				return arg, err
+			}
+		}
+	}
+	return arg, nil
+}
+
+func fillStructIds(engine SessionEngine, properties []*ResultProperty, v reflect.Value) error {
+	for _, property := range properties {
+		var field = findPropertyField(v, property.Property)
+		if !field.IsValid() || !field.CanSet() || !field.IsZero() {
+			continue
+		}
+		var generator = engineIdGenerator(engine, property.Generator)
+		if generator == nil {
+			return utils.NewError("IdGenerator", "can not find id generator '", property.Generator, "' for property ", property.Property, ", you must call RegisterIdGenerator() first!")
+		}
+		var id, err = generator.Generate()
+		if err != nil {
+			return err
+		}
+		if err = setGeneratedId(field, id); err != nil {
+			return utils.NewError("IdGenerator", "set property ", property.Property, " fail,", err.Error())
+		}
+	}
+	return nil
+}
+
+//按属性名（忽略大小写和下划线）或json tag查找结构体字段
+func findPropertyField(v reflect.Value, property string) reflect.Value {
+	var t = v.Type()
+	var name = strings.ToLower(strings.Replace(property, "_", "", -1))
+	for i := 0; i < t.NumField(); i++ {
+		var structField = t.Field(i)
+		var js = structField.Tag.Get("json")
+		if strings.Index(js, ",") != -1 {
+			js = strings.Split(js, ",")[0]
+		}
+		if js == property || strings.ToLower(strings.Replace(structField.Name, "_", "", -1)) == name {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+func setGeneratedId(field reflect.Value, id interface{}) error {
+	var idValue = reflect.ValueOf(id)
+	var target = field
+	if target.Kind() == reflect.Ptr {
+		target = reflect.New(field.Type().Elem()).Elem()
+	}
+	switch {
+	case target.Kind() == reflect.String:
+		target.SetString(fmt.Sprint(id))
+	case idValue.Kind() == reflect.String:
+		//字符串主键不能转换为数字类型
+		return fmt.Errorf("can not convert %s to %s", idValue.Type(), target.Type())
+	case idValue.Type().ConvertibleTo(target.Type()):
+		target.Set(idValue.Convert(target.Type()))
+	default:
+		return fmt.Errorf("can not convert %s to %s", idValue.Type(), target.Type())
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(target.Addr())
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package GoMybatis
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+const (
+	snowflakeWorkerIdBits = 10
+	snowflakeSequenceBits = 12
+
+	SnowflakeMaxWorkerId = -1 ^ (-1 << snowflakeWorkerIdBits)
+	snowflakeMaxSequence = -1 ^ (-1 << snowflakeSequenceBits)
+)
+
+//雪花算法起始时间 2020-01-01 00:00:00 UTC（毫秒）
+const SnowflakeEpoch int64 = 1577836800000
+
+//雪花算法主键生成器：41位毫秒时间戳+10位机器id+12位序列号，生成int64
+type SnowflakeIdGenerator struct {
+	mutex    *sync.Mutex
+	workerId int64
+	lastTime int64
+	sequence int64
+}
+
+//workerId:机器id，取值范围 0~1023，多实例部署时每个实例必须不同
+func (it SnowflakeIdGenerator) New(workerId int64) (SnowflakeIdGenerator, error) {
+	if workerId < 0 || workerId > SnowflakeMaxWorkerId {
+		return it, utils.NewError("SnowflakeIdGenerator", "workerId must between 0 and ", SnowflakeMaxWorkerId)
+	}
+	it.mutex = &sync.Mutex{}
+	it.workerId = workerId
+	return it, nil
+}
+
+func (it *SnowflakeIdGenerator) WorkerId() int64 {
+	return it.workerId
+}
+
+func (it *SnowflakeIdGenerator) Generate() (interface{}, error) {
+	return it.NextId()
+}
+
+func (it *SnowflakeIdGenerator) NextId() (int64, error) {
+	if it.mutex == nil {
+		return 0, utils.NewError("SnowflakeIdGenerator", "not init! you must call SnowflakeIdGenerator{}.New(workerId)")
+	}
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+
+	var now = it.currentMillis()
+	if now < it.lastTime {
+		return 0, utils.NewError("SnowflakeIdGenerator", "clock moved backwards, refusing to generate id for ", it.lastTime-now, "ms")
+	}
+	if now == it.lastTime {
+		it.sequence = (it.sequence + 1) & snowflakeMaxSequence
+		if it.sequence == 0 {
+			//当前毫秒序列号用尽，等待下一毫秒
+			for now <= it.lastTime {
+				now = it.currentMillis()
+			}
+		}
+	} else {
+		it.sequence = 0
+	}
+	it.lastTime = now
+	var id = ((now - SnowflakeEpoch) << (snowflakeWorkerIdBits + snowflakeSequenceBits)) |
+		(it.workerId << snowflakeSequenceBits) |
+		it.sequence
+	return id, nil
+}
+
+func (it *SnowflakeIdGenerator) currentMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,144 @@
+package GoMybatis
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/zhuxiujia/GoMybatis/example"
+)
+
+type IdGeneratorTestMapper struct {
+	InsertTemplete      func(session *Session, arg example.Activity) (int64, error)     `mapperParams:"session,arg"`
+	InsertTempleteBatch func(session *Session, args []example.Activity) (int64, error)  `mapperParams:"session,args"`
+	InsertTempletePtr   func(session *Session, arg *example.Activity) (int64, error)    `mapperParams:"session,arg"`
+	InsertTempleteArray func(session *Session, args [2]example.Activity) (int64, error) `mapperParams:"session,args"`
+}
+
+func newIdGeneratorTestMapper(generator string) (*GoMybatisEngine, IdGeneratorTestMapper) {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper IdGeneratorTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <resultMap id="BaseResultMap" tables="biz_activity">
+        <id column="id" property="id" generator="`+generator+`"/>
+        <result column="name" property="name" langType="string"/>
+    </resultMap>
+    <insertTemplete id="insertTemplete"/>
+    <insertTemplete id="insertTempleteBatch"/>
+    <insertTemplete id="insertTempletePtr"/>
+    <insertTemplete id="insertTempleteArray"/>
+</mapper>`))
+	return &engine, mapper
+}
+
+func TestIdGenerator_InsertTemplete(t *testing.T) {
+	var _, mapper = newIdGeneratorTestMapper(IdGenerator_Snowflake)
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	_, err := mapper.InsertTemplete(&session, example.Activity{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var args = capture.LastArgs()
+	if len(args) != 2 {
+		t.Fatal("id not generated,sql =", capture.LastSql(), args)
+	}
+	if _, err := strconv.ParseInt(args[0].(string), 10, 64); err != nil {
+		t.Fatal("snowflake id must be a number,id =", args[0])
+	}
+
+	//已有主键不覆盖
+	_, err = mapper.InsertTemplete(&session, example.Activity{Id: "123", Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capture.LastArgs()[0] != "123" {
+		t.Fatal("id must not be replaced,id =", capture.LastArgs()[0])
+	}
+}
+
+func TestIdGenerator_InsertTempleteBatch(t *testing.T) {
+	var _, mapper = newIdGeneratorTestMapper(IdGenerator_ULID)
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	var acts = []example.Activity{{Name: "a"}, {Name: "b"}, {Id: "c", Name: "c"}}
+	_, err := mapper.InsertTempleteBatch(&session, acts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acts[0].Id == "" || acts[1].Id == "" || acts[0].Id == acts[1].Id {
+		t.Fatal("batch insert must generate distinct id per element,ids =", acts[0].Id, acts[1].Id)
+	}
+	if acts[2].Id != "c" {
+		t.Fatal("id must not be replaced,id =", acts[2].Id)
+	}
+	var args = capture.LastArgs()
+	if args[0] != acts[0].Id || args[2] != acts[1].Id {
+		t.Fatal("generated id not used in sql args:", args)
+	}
+
+	//值传递的数组同样生成主键（调用方的数组不回填）
+	_, err = mapper.InsertTempleteArray(&session, [2]example.Activity{{Name: "a"}, {Name: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args = capture.LastArgs()
+	var id0, _ = args[0].(string)
+	var id1, _ = args[2].(string)
+	if id0 == "" || id1 == "" || id0 == id1 {
+		t.Fatal("array by value must generate id:", capture.LastSql(), args)
+	}
+}
+
+func TestIdGenerator_Custom(t *testing.T) {
+	var engine, mapper = newIdGeneratorTestMapper("custom")
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	var act = example.Activity{Name: "test"}
+	_, err := mapper.InsertTempletePtr(&session, &act)
+	if err == nil {
+		t.Fatal("unregistered generator must return error")
+	}
+	engine.RegisterIdGenerator("custom", customIdGenerator{})
+	_, err = mapper.InsertTempletePtr(&session, &act)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if act.Id != "custom-id" {
+		t.Fatal("custom generator not used,id =", act.Id)
+	}
+}
+
+type customIdGenerator struct {
+}
+
+func (it customIdGenerator) Generate() (interface{}, error) {
+	return "custom-id", nil
+}
+
+func TestSnowflakeIdGenerator_NextId(t *testing.T) {
+	if _, err := (SnowflakeIdGenerator{}).New(SnowflakeMaxWorkerId + 1); err == nil {
+		t.Fatal("workerId out of range must return error")
+	}
+	var generator, err = SnowflakeIdGenerator{}.New(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids = map[int64]bool{}
+	var last int64
+	for i := 0; i < 10000; i++ {
+		id, err := generator.NextId()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids[id] || id <= last {
+			t.Fatal("snowflake id must be unique and increasing")
+		}
+		if (id>>snowflakeSequenceBits)&SnowflakeMaxWorkerId != 7 {
+			t.Fatal("workerId not encoded in id")
+		}
+		ids[id] = true
+		last = id
+	}
+}
@@ -2,6 +2,7 @@ package GoMybatis
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -19,23 +20,34 @@ type LocalSession struct {
 	driver          string
 	url             string
 	db              *sql.DB
-	stmt            *sql.Stmt
+	stmtCache       StmtCache //绑定db的预编译语句缓存，session关闭时关闭
+	txStmtCache     StmtCache //绑定当前事务的预编译语句缓存，事务结束时关闭
+	txStmtCacheTx   *sql.Tx   //txStmtCache 所属的事务
 	txStack         tx.TxStack
 	savePointStack  *tx.SavePointStack
 	isClosed        bool
 	newLocalSession *LocalSession
 
+	conn       *sql.Conn //固定的连接（例如已切换schema），为nil时使用db连接池
+	connInit   string    //固定连接时执行的初始化sql，新建的同租户session同样执行
+	connReset  string    //归还固定连接前执行的sql（例如恢复schema），为空或执行失败时丢弃连接
+	tenant     string    //所属租户，见 TenantDataSourceRouter
+	dataSource string    //路由选择的数据源名称，见 GoMybatisDataSourceRouter
+	onClose    func()    //session关闭时回调
+
 	logSystem Log
 }
 
 func (it LocalSession) New(driver string, url string, db *sql.DB, logSystem Log) LocalSession {
 	return LocalSession{
-		SessionId: utils.CreateUUID(),
-		db:        db,
-		txStack:   tx.TxStack{}.New(),
-		driver:    driver,
-		url:       url,
-		logSystem: logSystem,
+		SessionId:   utils.CreateUUID(),
+		db:          db,
+		txStack:     tx.TxStack{}.New(),
+		stmtCache:   StmtCache{}.New(DefaultStmtCacheSize),
+		txStmtCache: StmtCache{}.New(DefaultStmtCacheSize),
+		driver:      driver,
+		url:         url,
+		logSystem:   logSystem,
 	}
 }
 
@@ -43,6 +55,41 @@ func (it *LocalSession) Id() string {
 	return it.SessionId
 }
 
+//所属租户，不属于租户返回""
+func (it *LocalSession) Tenant() string {
+	return it.tenant
+}
+
+//所属数据源名称，不是经 GoMybatisDataSourceRouter 创建返回""
+func (it *LocalSession) DataSource() string {
+	return it.dataSource
+}
+
+//数据库厂商，见 DatabaseIdOf
+func (it *LocalSession) DatabaseId() string {
+	return DatabaseIdOf(it.driver)
+}
+
+//设置预编译语句缓存容量（<=0 不缓存），已缓存的语句会被关闭
+func (it *LocalSession) SetStmtCacheSize(size int) {
+	it.stmtCache.Clear()
+	it.txStmtCache.Clear()
+	it.stmtCache = StmtCache{}.New(size)
+	it.txStmtCache = StmtCache{}.New(size)
+	it.txStmtCacheTx = nil
+}
+
+//预编译语句缓存统计（db与事务缓存之和）
+func (it *LocalSession) StmtCacheStats() StmtCacheStats {
+	var stats = it.stmtCache.Stats()
+	var txStats = it.txStmtCache.Stats()
+	stats.Hits += txStats.Hits
+	stats.Misses += txStats.Misses
+	stats.Evictions += txStats.Evictions
+	stats.Size += txStats.Size
+	return stats
+}
+
 func (it *LocalSession) Rollback() error {
 	if it.isClosed == true {
 		return utils.NewError("LocalSession", " can not Rollback() a Closed Session!")
@@ -81,6 +128,7 @@ func (it *LocalSession) Rollback() error {
 			if it.logSystem != nil {
 				it.logSystem.Println([]byte("[GoMybatis] [" + it.Id() + "] Rollback Session"))
 			}
+			it.closeTxStmtCache()
 			var err = t.Rollback()
 			if err != nil {
 				return err
@@ -127,6 +175,7 @@ func (it *LocalSession) Commit() error {
 			if it.logSystem != nil {
 				it.logSystem.Println([]byte("[GoMybatis] [" + it.Id() + "] Commit tx session:" + it.Id()))
 			}
+			it.closeTxStmtCache()
 			var err = t.Commit()
 			if err != nil {
 				return err
@@ -155,7 +204,7 @@ func (it *LocalSession) Begin(p *tx.Propagation) error {
 				it.txStack.Push(it.txStack.Last())
 				return nil
 			} else {
-				var t, err = it.db.Begin()
+				var t, err = it.begin()
 				err = it.dbErrorPack(err)
 				if err == nil {
 					it.txStack.Push(t, p)
@@ -165,7 +214,7 @@ func (it *LocalSession) Begin(p *tx.Propagation) error {
 			break
 		case tx.PROPAGATION_SUPPORTS:
 			if it.txStack.Len() > 0 {
-				var t, err = it.db.Begin()
+				var t, err = it.begin()
 				err = it.dbErrorPack(err)
 				if err == nil {
 					it.txStack.Push(t, p)
@@ -178,7 +227,7 @@ func (it *LocalSession) Begin(p *tx.Propagation) error {
 			break
 		case tx.PROPAGATION_MANDATORY:
 			if it.txStack.Len() > 0 {
-				var t, err = it.db.Begin()
+				var t, err = it.begin()
 				err = it.dbErrorPack(err)
 				if err == nil {
 					it.txStack.Push(t, p)
@@ -193,24 +242,22 @@ func (it *LocalSession) Begin(p *tx.Propagation) error {
 			//stop old tx
 			//}
 			//new session(tx)
-			var db, e = sql.Open(it.driver, it.url)
+			var sess, e = it.newSiblingSession() //same PROPAGATION_REQUIRES_NEW
 			if e != nil {
 				return e
 			}
-			var sess = LocalSession{}.New(it.driver, it.url, db, it.logSystem) //same PROPAGATION_REQUIRES_NEW
-			it.newLocalSession = &sess
+			it.newLocalSession = sess
 			break
 		case tx.PROPAGATION_NOT_SUPPORTED:
 			//if it.txStack.Len() > 0 {
 			//stop old tx
 			//}
 			//new session( no tx)
-			var db, e = sql.Open(it.driver, it.url)
+			var sess, e = it.newSiblingSession()
 			if e != nil {
 				return e
 			}
-			var sess = LocalSession{}.New(it.driver, it.url, db, it.logSystem)
-			it.newLocalSession = &sess
+			it.newLocalSession = sess
 			break
 		case tx.PROPAGATION_NEVER: //END
 			if it.txStack.Len() > 0 {
@@ -235,7 +282,7 @@ func (it *LocalSession) Begin(p *tx.Propagation) error {
 				return errors.New("[GoMybatis] PROPAGATION_NOT_REQUIRED Nested transaction exception! current Already have a transaction!")
 			} else {
 				//new tx
-				var tx, err = it.db.Begin()
+				var tx, err = it.begin()
 				err = it.dbErrorPack(err)
 				if err == nil {
 					it.txStack.Push(tx, p)
@@ -269,9 +316,8 @@ func (it *LocalSession) Close() {
 		it.newLocalSession = nil
 	}
 	if it.db != nil {
-		if it.stmt != nil {
-			it.stmt.Close()
-		}
+		it.closeTxStmtCache()
+		it.stmtCache.Clear()
 
 		for i := 0; i < it.txStack.Len(); i++ {
 			var tx, _ = it.txStack.Pop()
@@ -279,9 +325,15 @@ func (it *LocalSession) Close() {
 				tx.Rollback()
 			}
 		}
+		if it.conn != nil {
+			releaseConn(it.conn, it.connReset)
+			it.conn = nil
+		}
 		it.db = nil
-		it.stmt = nil
 		it.isClosed = true
+		if it.onClose != nil {
+			it.onClose()
+		}
 	}
 }
 
@@ -300,7 +352,7 @@ func (it *LocalSession) Query(sqlorArgs string) ([]map[string][]byte, error) {
 		rows, err = t.Query(sqlorArgs)
 		err = it.dbErrorPack(err)
 	} else {
-		rows, err = it.db.Query(sqlorArgs)
+		rows, err = it.query(sqlorArgs)
 		err = it.dbErrorPack(err)
 	}
 	if rows != nil {
@@ -329,7 +381,7 @@ func (it *LocalSession) QueryNew(sqlorArgs string) (*sql.Rows, error) {
 		rows, err = t.Query(sqlorArgs)
 		err = it.dbErrorPack(err)
 	} else {
-		rows, err = it.db.Query(sqlorArgs)
+		rows, err = it.query(sqlorArgs)
 		err = it.dbErrorPack(err)
 	}
 	if err != nil {
@@ -355,7 +407,7 @@ func (it *LocalSession) Exec(sqlorArgs string) (*Result, error) {
 		result, err = t.Exec(sqlorArgs)
 		err = it.dbErrorPack(err)
 	} else {
-		result, err = it.db.Exec(sqlorArgs)
+		result, err = it.exec(sqlorArgs)
 		err = it.dbErrorPack(err)
 	}
 	if err != nil {
@@ -380,30 +432,17 @@ func (it *LocalSession) QueryPrepare(sqlPrepare string, args ...interface{}) ([]
 
 	var rows *sql.Rows
 	var err error
-	var t, _ = it.txStack.Last()
-	if t != nil {
-		stmt, err := t.Prepare(sqlPrepare)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
-		rows, err = stmt.Query(args...)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		stmt, err := it.db.Prepare(sqlPrepare)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
-
-		rows, err = stmt.Query(args...)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
+	stmt, cached, err := it.prepare(sqlPrepare)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer stmt.Close()
+	}
+	rows, err = stmt.Query(args...)
+	err = it.dbErrorPack(err)
+	if err != nil {
+		return nil, err
 	}
 	if rows != nil {
 		defer rows.Close()
@@ -426,30 +465,17 @@ func (it *LocalSession) QueryPrepareNew(sqlPrepare string, args ...interface{})
 
 	var rows *sql.Rows
 	var err error
-	var t, _ = it.txStack.Last()
-	if t != nil {
-		stmt, err := t.Prepare(sqlPrepare)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
-		rows, err = stmt.Query(args...)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		stmt, err := it.db.Prepare(sqlPrepare)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
-
-		rows, err = stmt.Query(args...)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
+	stmt, cached, err := it.prepare(sqlPrepare)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer stmt.Close()
+	}
+	rows, err = stmt.Query(args...)
+	err = it.dbErrorPack(err)
+	if err != nil {
+		return nil, err
 	}
 	if err != nil {
 		return nil, err
@@ -488,29 +514,17 @@ func (it *LocalSession) ExecPrepare(sqlPrepare string, args ...interface{}) (*Re
 
 	var result sql.Result
 	var err error
-	var t, _ = it.txStack.Last()
-	if t != nil {
-		stmt, err := t.Prepare(sqlPrepare)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
-		result, err = stmt.Exec(args...)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		stmt, err := it.db.Prepare(sqlPrepare)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
-		result, err = stmt.Exec(args...)
-		err = it.dbErrorPack(err)
-		if err != nil {
-			return nil, err
-		}
+	stmt, cached, err := it.prepare(sqlPrepare)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer stmt.Close()
+	}
+	result, err = stmt.Exec(args...)
+	err = it.dbErrorPack(err)
+	if err != nil {
+		return nil, err
 	}
 	if err != nil {
 		return nil, err
@@ -524,6 +538,116 @@ func (it *LocalSession) ExecPrepare(sqlPrepare string, args ...interface{}) (*Re
 	}
 }
 
+//获取预编译语句，当前有事务则绑定事务，否则绑定db。cached为false时调用方需在使用后关闭stmt
+func (it *LocalSession) prepare(sqlPrepare string) (stmt *sql.Stmt, cached bool, err error) {
+	var t, _ = it.txStack.Last()
+	var cache = &it.stmtCache
+	if t != nil {
+		if it.txStmtCacheTx != t {
+			//事务已切换，旧事务的语句已不可用
+			it.txStmtCache.Clear()
+			it.txStmtCacheTx = t
+		}
+		cache = &it.txStmtCache
+	}
+	stmt = cache.Get(sqlPrepare)
+	if stmt != nil {
+		return stmt, true, nil
+	}
+	if t != nil {
+		stmt, err = t.Prepare(sqlPrepare)
+	} else {
+		stmt, err = it.dbPrepare(sqlPrepare)
+	}
+	err = it.dbErrorPack(err)
+	if err != nil {
+		return nil, false, err
+	}
+	return stmt, cache.Put(sqlPrepare, stmt), nil
+}
+
+//固定使用db中的一个连接并执行初始化sql（例如切换schema），session关闭时执行resetSql后归还连接，resetSql为空时丢弃连接
+func (it *LocalSession) pinConn(initSql string, resetSql string) error {
+	var conn, err = it.db.Conn(context.Background())
+	if err != nil {
+		return it.dbErrorPack(err)
+	}
+	if initSql != "" {
+		if _, err = conn.ExecContext(context.Background(), initSql); err != nil {
+			conn.Close()
+			return it.dbErrorPack(err)
+		}
+	}
+	it.conn = conn
+	it.connInit = initSql
+	it.connReset = resetSql
+	return nil
+}
+
+//归还固定的连接：执行resetSql恢复连接状态，resetSql为空或执行失败时丢弃连接，避免连接池中的连接保留租户schema
+func releaseConn(conn *sql.Conn, resetSql string) {
+	if resetSql != "" {
+		if _, err := conn.ExecContext(context.Background(), resetSql); err == nil {
+			conn.Close()
+			return
+		}
+	}
+	discardConn(conn)
+}
+
+//PROPAGATION_REQUIRES_NEW、PROPAGATION_NOT_SUPPORTED 使用的新session，与当前session属于同一租户
+func (it *LocalSession) newSiblingSession() (*LocalSession, error) {
+	var db, e = sql.Open(it.driver, it.url)
+	if e != nil {
+		return nil, e
+	}
+	var sess = LocalSession{}.New(it.driver, it.url, db, it.logSystem)
+	sess.SetStmtCacheSize(it.stmtCache.capacity)
+	sess.tenant = it.tenant
+	sess.dataSource = it.dataSource
+	if it.conn != nil {
+		if e = sess.pinConn(it.connInit, it.connReset); e != nil {
+			db.Close()
+			return nil, e
+		}
+	}
+	return &sess, nil
+}
+
+func (it *LocalSession) begin() (*sql.Tx, error) {
+	if it.conn != nil {
+		return it.conn.BeginTx(context.Background(), nil)
+	}
+	return it.db.Begin()
+}
+
+func (it *LocalSession) query(sqlorArgs string) (*sql.Rows, error) {
+	if it.conn != nil {
+		return it.conn.QueryContext(context.Background(), sqlorArgs)
+	}
+	return it.db.Query(sqlorArgs)
+}
+
+func (it *LocalSession) exec(sqlorArgs string) (sql.Result, error) {
+	if it.conn != nil {
+		return it.conn.ExecContext(context.Background(), sqlorArgs)
+	}
+	return it.db.Exec(sqlorArgs)
+}
+
+func (it *LocalSession) dbPrepare(sqlPrepare string) (*sql.Stmt, error) {
+	if it.conn != nil {
+		return it.conn.PrepareContext(context.Background(), sqlPrepare)
+	}
+	return it.db.Prepare(sqlPrepare)
+}
+
+//关闭绑定事务的预编译语句
+func (it *LocalSession) closeTxStmtCache() {
+	it.txStmtCache.Clear()
+	it.txStmtCacheTx = nil
+}
+
 func (it *LocalSession) dbErrorPack(e error) error {
 	if e != nil {
 		var sqlError = errors.New("[GoMybatis][LocalSession]" + e.Error())
@@ -0,0 +1,113 @@
+package GoMybatis
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//struct tag gm:"sensitive" 的字段在sql日志中脱敏，例如 Password string `json:"password" gm:"sensitive"`
+const SensitiveTag = "sensitive"
+
+//sql构建参数中方法参数结构体的脱敏属性名（map[string]bool），见 SensitiveTag
+const SensitiveArgsVar = "_sensitiveArgs"
+
+//#{} 参数选项，#{password,redact=true} 在sql日志中脱敏
+const RedactOption = "redact=true"
+
+//sql日志参数脱敏（实现 ast.ArgRedactor），每个引擎一个，见 GoMybatisEngine.LogRedactor()。
+//以下参数在所有sql日志（SqlLogger）中显示为 ***，执行时仍使用原值：
+//
+//	#{password,redact=true}
+//	方法参数结构体中 gm:"sensitive" 的字段，例如 Password string `json:"password" gm:"sensitive"`
+//	属性名（表达式最后一段，例如 arg.token 为 token）匹配 AddPattern 的参数，例如 engine.LogRedactor().AddPattern("(?i)password|token")
+type LogRedactor struct {
+	mutex    *sync.RWMutex
+	patterns []*regexp.Regexp
+}
+
+func (it LogRedactor) New() LogRedactor {
+	it.mutex = &sync.RWMutex{}
+	it.patterns = []*regexp.Regexp{}
+	return it
+}
+
+//添加属性名正则
+func (it *LogRedactor) AddPattern(pattern string) error {
+	var reg, err = regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.patterns = append(it.patterns, reg)
+	return nil
+}
+
+func (it *LogRedactor) Redact(express string, options string, env map[string]interface{}) bool {
+	if options != "" {
+		for _, option := range strings.Split(options, ",") {
+			if strings.Replace(option, " ", "", -1) == RedactOption {
+				return true
+			}
+		}
+	}
+	var name = strings.TrimSpace(express)
+	if index := strings.LastIndex(name, "."); index != -1 {
+		name = name[index+1:]
+	}
+	if sensitive, ok := env[SensitiveArgsVar].(map[string]bool); ok && sensitive[name] {
+		return true
+	}
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	for _, pattern := range it.patterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+//方法参数（包括嵌套结构体、切片元素）中 gm:"sensitive" 的字段名和json名，没有返回nil
+func sensitiveArgNames(funcType reflect.Type) map[string]bool {
+	var names = map[string]bool{}
+	var visited = map[reflect.Type]bool{}
+	var scan func(t reflect.Type)
+	scan = func(t reflect.Type) {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || visited[t] {
+			return
+		}
+		visited[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			var field = t.Field(i)
+			if isSensitiveField(field) {
+				names[field.Name] = true
+				if jsonKey := strings.Split(field.Tag.Get("json"), ",")[0]; jsonKey != "" && jsonKey != "-" {
+					names[jsonKey] = true
+				}
+			}
+			scan(field.Type)
+		}
+	}
+	for i := 0; i < funcType.NumIn(); i++ {
+		scan(funcType.In(i))
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return names
+}
+
+func isSensitiveField(field reflect.StructField) bool {
+	for _, value := range strings.Split(field.Tag.Get("gm"), ",") {
+		if strings.TrimSpace(value) == SensitiveTag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package GoMybatis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+)
+
+type RedactTestArg struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Secret   string `json:"secret" gm:"sensitive"`
+	Token    string `json:"token"`
+}
+
+type RedactTestMapper struct {
+	UpdateUser  func(arg RedactTestArg) (int64, error)    `mapperParams:"arg"`
+	UpdateUsers func(args []RedactTestArg) (int64, error) `mapperParams:"args"`
+}
+
+func TestLogRedactor(t *testing.T) {
+	resetTestDriverState("TestLogRedactor")
+	var engine = GoMybatisEngine{}.New()
+	engine.Open(TestDriverName, "TestLogRedactor")
+	var events = []SqlEvent{}
+	engine.SetSqlLogger(SqlLoggerFunc(func(event SqlEvent) {
+		events = append(events, event)
+	}))
+	if err := engine.LogRedactor().AddPattern("(?i)^token$"); err != nil {
+		t.Fatal(err)
+	}
+	var mapper RedactTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <update id="updateUser">update biz_user set password = #{password,redact=true}, secret = #{secret}, token = #{arg.Token}, name = #{name} where id = #{id}</update>
+    <update id="updateUsers"><foreach collection="args" item="item" separator=";">update biz_user set secret = #{item.Secret} where id = #{item.Id}</foreach></update>
+</mapper>`))
+
+	var arg = RedactTestArg{Id: "1", Name: "a", Password: "p", Secret: "s", Token: "t"}
+	if _, err := mapper.UpdateUser(arg); err != nil {
+		t.Fatal(err)
+	}
+	//日志脱敏，执行使用原值
+	if !reflect.DeepEqual(events[0].Args, []interface{}{ast.RedactMask, ast.RedactMask, ast.RedactMask, "a", "1"}) {
+		t.Fatal("redact log args fail:", events[0].Args)
+	}
+	var state = testDriverState("TestLogRedactor")
+	if compactSql(state.Execs[0]) != compactSql("update biz_user set password = ?, secret = ?, token = ?, name = ? where id = ?") ||
+		!reflect.DeepEqual(state.ExecArgs[0], []driver.Value{"p", "s", "t", "a", "1"}) {
+		t.Fatal("exec args fail:", state.Execs[0], state.ExecArgs[0])
+	}
+	mapper.UpdateUsers([]RedactTestArg{arg})
+	if !reflect.DeepEqual(events[1].Args, []interface{}{ast.RedactMask, "1"}) {
+		t.Fatal("redact foreach args fail:", events[1].Args)
+	}
+}
+
+func TestSplitRedactedArgs(t *testing.T) {
+	var args = []interface{}{1, ast.RedactedArg{Arg: "p"}}
+	var execArgs, logArgs = ast.SplitRedactedArgs(args)
+	if !reflect.DeepEqual(execArgs, []interface{}{1, "p"}) || !reflect.DeepEqual(logArgs, []interface{}{1, ast.RedactMask}) {
+		t.Fatal("split redacted args fail:", execArgs, logArgs)
+	}
+	//直接交给 database/sql 时按原值转换
+	if value, err := (ast.RedactedArg{Arg: 1}).Value(); err != nil || value != int64(1) {
+		t.Fatal("redacted arg value fail:", value, err)
+	}
+}
@@ -3,11 +3,14 @@ package GoMybatis
 import (
 	"github.com/zhuxiujia/GoMybatis/utils"
 	"bytes"
+	"sync"
 )
 
 type LogSystem struct {
+	mutex   *sync.RWMutex
 	log     Log
 	logChan chan []byte
+	done    chan struct{} //接受者退出时关闭
 	started bool
 }
 
@@ -19,7 +22,9 @@ func (it LogSystem) New(logImpl Log, queueLen int) (LogSystem, error) {
 	if logImpl == nil {
 		logImpl = &LogStandard{}
 	}
+	it.mutex = &sync.RWMutex{}
 	it.logChan = make(chan []byte, queueLen)
+	it.done = make(chan struct{})
 	it.log = logImpl
 	//启动接受者
 	go it.receiver()
@@ -27,15 +32,24 @@ func (it LogSystem) New(logImpl Log, queueLen int) (LogSystem, error) {
 	return it, nil
 }
 
-//关闭日志系统和队列
+//关闭日志系统和队列，等待队列中剩余的日志输出完成
 func (it *LogSystem) Close() error {
+	it.mutex.Lock()
+	if it.started == false {
+		it.mutex.Unlock()
+		return utils.NewError("LogSystem", "log system is closed!")
+	}
 	close(it.logChan)
 	it.started = false
+	it.mutex.Unlock()
+	<-it.done
 	return nil
 }
 
 //日志发送者
 func (it *LogSystem) SendLog(logs ...string) error {
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
 	if it.started == false {
 		return utils.NewError("LogSystem", "no log Receiver! you must call go GoMybatis.LogSystem{}.New()")
 	}
@@ -47,10 +61,10 @@ func (it *LogSystem) SendLog(logs ...string) error {
 	return nil
 }
 
-//日志接受者
+//日志接受者，队列关闭后输出剩余日志并退出
 func (it *LogSystem) receiver() error {
-	for {
-		logs := <-it.logChan
+	defer close(it.done)
+	for logs := range it.logChan {
 		it.log.Println(logs)
 	}
 	return nil
@@ -49,3 +49,28 @@ func TestLogSystem_Close(t *testing.T) {
 	}
 	fmt.Println(err)
 }
+
+func TestLogSystem_CloseFlush(t *testing.T) {
+	var count = 0
+	var stdLog = LogStandard{
+		PrintlnFunc: func(v []byte) {
+			count++
+		},
+	}
+	var system, err = LogSystem{}.New(&stdLog, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		system.SendLog("hello")
+	}
+	if err = system.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 100 {
+		t.Fatal("close must flush log queue:", count)
+	}
+	if err = system.Close(); err == nil {
+		t.Fatal("close twice must fail")
+	}
+}
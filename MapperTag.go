@@ -0,0 +1,108 @@
+package GoMybatis
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//方法tag定义sql，无需xml。例如
+//
+//	SelectById func(id string) (Activity, error) `mapperParams:"id" Select:"select * from biz_activity where id = #{id}" resultMap:"BaseResultMap"`
+//	DeleteById func(id string) (int64, error) `mapperParams:"id" sql:"delete from biz_activity where id = #{id}" kind:"delete"`
+//
+//sql以<script>开头时按xml解析，可使用<if>、<where>、<foreach>等元素，例如 `Select:"<script>select * from t <where><if test='name != nil'>name = #{name}</if></where></script>"`
+var mapperTagKinds = []string{"Select", "Insert", "Update", "Delete"}
+
+const mapperTagSql = "sql"
+const mapperTagKind = "kind"
+const mapperTagScript = "script"
+
+//读取mapper结构体方法tag中定义的sql，返回 map[方法名]元素
+func loadMapperTags(beanType reflect.Type) (map[string]*etree.Element, error) {
+	for beanType.Kind() == reflect.Ptr {
+		beanType = beanType.Elem()
+	}
+	var items = make(map[string]*etree.Element)
+	for i := 0; i < beanType.NumField(); i++ {
+		var field = beanType.Field(i)
+		if field.Type.Kind() != reflect.Func {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				var embedItems, err = loadMapperTags(field.Type)
+				if err != nil {
+					return nil, err
+				}
+				for k, v := range embedItems {
+					items[k] = v
+				}
+			}
+			continue
+		}
+		var element, err = makeMapperTagElement(beanType, field)
+		if err != nil {
+			return nil, err
+		}
+		if element != nil {
+			items[field.Name] = element
+		}
+	}
+	return items, nil
+}
+
+//方法tag转为xml元素，没有定义sql返回nil
+func makeMapperTagElement(beanType reflect.Type, field reflect.StructField) (*etree.Element, error) {
+	var method = beanType.String() + "." + field.Name + "()"
+	var kind, sql string
+	for _, tag := range mapperTagKinds {
+		if value, ok := field.Tag.Lookup(tag); ok {
+			if kind != "" {
+				return nil, utils.NewError("GoMybatis", "method ", method, " can only define one sql tag, find tag ", utils.UpperFieldFirstName(kind), " and ", tag, "!")
+			}
+			kind, sql = strings.ToLower(tag), value
+		}
+	}
+	if value, ok := field.Tag.Lookup(mapperTagSql); ok {
+		if kind != "" {
+			return nil, utils.NewError("GoMybatis", "method ", method, " can only define one sql tag, find tag ", utils.UpperFieldFirstName(kind), " and sql!")
+		}
+		kind, sql = field.Tag.Get(mapperTagKind), value
+		switch kind {
+		case Element_Select, Element_Insert, Element_Update, Element_Delete:
+		default:
+			return nil, utils.NewError("GoMybatis", "method ", method, ` tag kind:"`, kind, `" must be select, insert, update or delete!`)
+		}
+	}
+	if kind == "" {
+		return nil, nil
+	}
+	if strings.TrimSpace(sql) == "" {
+		return nil, utils.NewError("GoMybatis", "method ", method, " sql tag can not be empty!")
+	}
+	var element = etree.NewElement(kind)
+	element.CreateAttr(ID, field.Name)
+	if resultMap := field.Tag.Get(Element_ResultMap); resultMap != "" {
+		element.CreateAttr(Element_ResultMap, resultMap)
+	}
+	var script = strings.TrimSpace(sql)
+	if !strings.HasPrefix(script, "<"+mapperTagScript+">") {
+		element.CreateCharData(sql)
+		return element, nil
+	}
+	var bytes = []byte(script)
+	utils.FixTestExpressionSymbol(&bytes)
+	var doc = etree.NewDocument()
+	if err := doc.ReadFromBytes(bytes); err != nil {
+		return nil, utils.NewError("GoMybatis", "method ", method, " sql tag <script> parse fail,", err.Error())
+	}
+	var root = doc.SelectElement(mapperTagScript)
+	if root == nil {
+		return nil, utils.NewError("GoMybatis", "method ", method, " sql tag <script> parse fail!")
+	}
+	//AddChild会从root中移除child，先复制
+	for _, child := range append([]etree.Token{}, root.Child...) {
+		element.AddChild(child)
+	}
+	return element, nil
+}
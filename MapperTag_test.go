@@ -0,0 +1,62 @@
+package GoMybatis
+
+import (
+	"strings"
+	"testing"
+)
+
+type MapperTagTestMapper struct {
+	SelectByName func(session *Session, name string) ([]map[string]string, error) `mapperParams:"session,name" Select:"<script>select * from biz_activity <where><if test='name != nil'>name = #{name}</if></where></script>"`
+	DeleteById   func(session *Session, id string) (int64, error)                 `mapperParams:"session,id" sql:"delete from biz_activity where id = #{id}" kind:"delete"`
+	UpdateName   func(session *Session, id string, name string) (int64, error)    `mapperParams:"session,id,name"`
+}
+
+func TestMapperTag(t *testing.T) {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper MapperTagTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <update id="updateName">update biz_activity set name = #{name} where id = #{id}</update>
+</mapper>`))
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	if _, err := mapper.SelectByName(&session, "a"); err != errCaptureQuery {
+		t.Fatal(err)
+	}
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity where name = ?") {
+		t.Fatal("tag <script> sql fail:", capture.LastSql())
+	}
+	if _, err := mapper.DeleteById(&session, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if compactSql(capture.LastSql()) != compactSql("delete from biz_activity where id = ?") {
+		t.Fatal("tag sql fail:", capture.LastSql())
+	}
+	if _, err := mapper.UpdateName(&session, "1", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if args := capture.LastArgs(); len(args) != 2 || args[0] != "b" {
+		t.Fatal("xml sql fail:", capture.LastSql(), args)
+	}
+}
+
+func TestMapperTag_Conflict(t *testing.T) {
+	defer func() {
+		var e = recover()
+		if e == nil {
+			t.Fatal("sql defined both in tag and xml must panic")
+		}
+		if !strings.Contains(e.(string), "DeleteById() define sql both in struct tag and xml at line 3, column 5") {
+			t.Fatal("conflict message fail:", e)
+		}
+	}()
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper MapperTagTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <delete id="deleteById">delete from biz_activity where id = #{id}</delete>
+    <update id="updateName">update biz_activity set name = #{name} where id = #{id}</update>
+</mapper>`))
+}
@@ -0,0 +1,103 @@
+package GoMybatis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
+)
+
+type NodeRegistryTestMapper struct {
+	SelectByTenant func(session *Session, name string) ([]map[string]string, error) `mapperParams:"session,name"`
+}
+
+//测试用自定义节点，输出 tenant_id = ?
+type tenantTestNode struct {
+	column string
+}
+
+func (it *tenantTestNode) Type() ast.NodeType {
+	return ast.NCustom
+}
+
+func (it *tenantTestNode) Eval(env map[string]interface{}, arg_array *[]interface{}) ([]byte, error) {
+	*arg_array = append(*arg_array, "t1")
+	return []byte(" and " + it.column + " = " + ast.SQLPlaceholder), nil
+}
+
+func TestNodeRegistry_CustomElement(t *testing.T) {
+	ast.RegisterNode("tenant", func(element *etree.Element, childs []ast.Node, holder *ast.NodeConfigHolder) (ast.Node, error) {
+		return &tenantTestNode{column: element.SelectAttrValue("column", "tenant_id")}, nil
+	})
+	defer ast.UnregisterNode("tenant")
+
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper NodeRegistryTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectByTenant">select * from biz_activity <where>name = #{name}<tenant column="org_id"/></where></select>
+</mapper>`))
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	if _, err := mapper.SelectByTenant(&session, "a"); err != errCaptureQuery {
+		t.Fatal(err)
+	}
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity where name = ? and org_id = ?") {
+		t.Fatal("custom element fail:", capture.LastSql())
+	}
+	if args := capture.LastArgs(); len(args) != 2 || args[1] != "t1" {
+		t.Fatal("custom element args fail:", args)
+	}
+}
+
+func TestNodeRegistry_UnknownElement(t *testing.T) {
+	defer func() {
+		var e = recover()
+		if e == nil {
+			t.Fatal("unknown element must panic")
+		}
+		var msg = fmt.Sprint(e)
+		if !strings.Contains(msg, "<fi>") || !strings.Contains(msg, "NodeRegistryTestMapper.SelectByTenant") || !strings.Contains(msg, "line 4, column 48") {
+			t.Fatal("error must contains element, mapper and line:", msg)
+		}
+	}()
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper NodeRegistryTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectByTenant">
+        select * from biz_activity where 1 = 1 <fi test="name != ''">and name = #{name}</fi>
+    </select>
+</mapper>`))
+}
+
+func TestNodeRegistry_Builtin(t *testing.T) {
+	//<orderBy>、Criteria节点由GoMybatis注册，同样不允许覆盖、移除
+	for _, tag := range []string{"if", string(Element_OrderBy), string(Element_Criteria)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("override builtin element must panic:", tag)
+				}
+			}()
+			ast.RegisterNode(tag, func(element *etree.Element, childs []ast.Node, holder *ast.NodeConfigHolder) (ast.Node, error) {
+				return nil, nil
+			})
+		}()
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("remove builtin element must panic:", tag)
+				}
+			}()
+			ast.UnregisterNode(tag)
+		}()
+	}
+	if ast.GetNodeFactory(string(Element_OrderBy)) == nil || ast.GetNodeFactory(string(Element_Criteria)) == nil {
+		t.Fatal("builtin element factory must keep registered")
+	}
+}
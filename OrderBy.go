@@ -0,0 +1,236 @@
+package GoMybatis
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//排序项，Property为属性名（通过resultMap转为列名）或列名
+type Sort struct {
+	Property string
+	Desc     bool
+}
+
+func Asc(property string) Sort {
+	return Sort{Property: property}
+}
+
+func Desc(property string) Sort {
+	return Sort{Property: property, Desc: true}
+}
+
+var sortPropertyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+//解析排序字符串，例如 "name"、"name desc"、"-name"（降序）、"+name"，多个以逗号分隔："name desc,createTime"
+func ParseSort(value string) ([]Sort, error) {
+	var sorts = []Sort{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var sort = Sort{}
+		if strings.HasPrefix(item, "-") {
+			sort.Desc = true
+			item = item[1:]
+		} else if strings.HasPrefix(item, "+") {
+			item = item[1:]
+		}
+		var fields = strings.Fields(item)
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				sort.Desc = true
+			default:
+				return nil, utils.NewError("OrderBy", "illegal sort '"+item+"'!")
+			}
+		} else if len(fields) != 1 {
+			return nil, utils.NewError("OrderBy", "illegal sort '"+item+"'!")
+		}
+		sort.Property = fields[0]
+		if !sortPropertyRegex.MatchString(sort.Property) {
+			return nil, utils.NewError("OrderBy", "illegal sort property '"+sort.Property+"'!")
+		}
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
+}
+
+//参数转为排序项，支持 Sort、[]Sort、string、[]string 及其指针
+func toSorts(value interface{}) ([]Sort, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case Sort:
+		return []Sort{v}, nil
+	case *Sort:
+		if v == nil {
+			return nil, nil
+		}
+		return []Sort{*v}, nil
+	case []Sort:
+		for _, sort := range v {
+			if !sortPropertyRegex.MatchString(sort.Property) {
+				return nil, utils.NewError("OrderBy", "illegal sort property '"+sort.Property+"'!")
+			}
+		}
+		return v, nil
+	case *[]Sort:
+		if v == nil {
+			return nil, nil
+		}
+		return toSorts(*v)
+	case string:
+		return ParseSort(v)
+	case *string:
+		if v == nil {
+			return nil, nil
+		}
+		return ParseSort(*v)
+	case []string:
+		return ParseSort(strings.Join(v, ","))
+	case *[]string:
+		if v == nil {
+			return nil, nil
+		}
+		return ParseSort(strings.Join(*v, ","))
+	}
+	return nil, utils.NewError("OrderBy", "sort arg must be GoMybatis.Sort, []GoMybatis.Sort, string or []string, but got "+reflect.TypeOf(value).String()+"!")
+}
+
+//属性名、列名统一为小写并去除下划线后比较，例如 createTime 与 create_time 相同
+func sortKey(property string) string {
+	return strings.ToLower(strings.Replace(property, "_", "", -1))
+}
+
+//<orderBy param="sort" allowed="name,createTime" default="createTime desc" dialect="mysql"/>
+//执行期读取参数 param 的排序项（见 toSorts），属性名按所在语句的resultMap（或 resultMap 属性指定）转为列名，
+//只允许 allowed 中的属性或列（为空时只允许resultMap中的属性），其余返回错误；参数为空时使用 default，均为空不输出。
+//按 dialect 给列名加引号（见 QuoteIdentifier），未指定时使用session驱动的方言（sql构建参数 _databaseId），均未知时不加引号。模板中作为子元素使用，例如
+//
+//	<selectTemplete id="selectByName" wheres="name?name = #{name}"><orderBy param="sort" allowed="name,createTime"/></selectTemplete>
+type orderByNode struct {
+	param    string
+	allowed  map[string]bool   //sortKey -> true
+	columns  map[string]string //sortKey(属性或列) -> 列名
+	defaults []Sort
+	dialect  Dialect
+}
+
+const orderByColumnsAttr = "columns" //构建期写入的 属性:列,属性:列 映射，见 resolveOrderByColumns
+
+func init() {
+	ast.RegisterNode(Element_OrderBy, func(element *etree.Element, childs []ast.Node, holder *ast.NodeConfigHolder) (ast.Node, error) {
+		var param = element.SelectAttrValue("param", "")
+		if param == "" {
+			return nil, utils.NewError("OrderBy", "<orderBy> attribute 'param' can not be empty!")
+		}
+		var node = &orderByNode{
+			param:   param,
+			columns: map[string]string{},
+			dialect: Dialect(element.SelectAttrValue("dialect", "")),
+		}
+		for _, item := range strings.Split(element.SelectAttrValue(orderByColumnsAttr, ""), ",") {
+			var kv = strings.SplitN(item, ":", 2)
+			if len(kv) == 2 && kv[0] != "" && kv[1] != "" {
+				node.columns[sortKey(kv[0])] = kv[1]
+				node.columns[sortKey(kv[1])] = kv[1]
+			}
+		}
+		if allowed := element.SelectAttrValue("allowed", ""); allowed != "" {
+			node.allowed = map[string]bool{}
+			for _, item := range strings.Split(allowed, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					node.allowed[sortKey(item)] = true
+				}
+			}
+		}
+		var defaults, err = ParseSort(element.SelectAttrValue("default", ""))
+		if err != nil {
+			return nil, err
+		}
+		node.defaults = defaults
+		return node, nil
+	})
+}
+
+func (it *orderByNode) Type() ast.NodeType {
+	return ast.NCustom
+}
+
+func (it *orderByNode) Eval(env map[string]interface{}, arg_array *[]interface{}) ([]byte, error) {
+	var sorts, err = toSorts(env[it.param])
+	if err != nil {
+		return nil, err
+	}
+	if len(sorts) == 0 {
+		sorts = it.defaults
+	} else {
+		for _, sort := range sorts {
+			if !it.isAllowed(sort.Property) {
+				return nil, utils.NewError("OrderBy", "arg '"+it.param+"' sort property '"+sort.Property+"' not allowed!")
+			}
+		}
+	}
+	if len(sorts) == 0 {
+		return nil, nil
+	}
+	var dialect = it.dialect
+	if dialect == "" {
+		if databaseId, ok := env[ast.DatabaseIdVar].(string); ok {
+			dialect = DialectOf(databaseId)
+		}
+	}
+	var items = make([]string, 0, len(sorts))
+	for _, sort := range sorts {
+		var column = it.columns[sortKey(sort.Property)]
+		if column == "" {
+			column = sort.Property
+		}
+		var item = QuoteIdentifier(dialect, column)
+		if sort.Desc {
+			item += " desc"
+		}
+		items = append(items, item)
+	}
+	return []byte(" order by " + strings.Join(items, ",")), nil
+}
+
+func (it *orderByNode) isAllowed(property string) bool {
+	var key = sortKey(property)
+	if it.allowed != nil {
+		return it.allowed[key]
+	}
+	_, ok := it.columns[key]
+	return ok
+}
+
+//构建期将语句resultMap中的 属性:列 映射写入其中的<orderBy>元素（<orderBy resultMap="">可指定其他resultMap）
+func resolveOrderByColumns(mapperTree map[string]etree.Token, resultMaps map[string]map[string]*ResultProperty) {
+	for _, item := range mapperTree {
+		var statement, ok = item.(*etree.Element)
+		if !ok || !isMethodElement(statement.Tag) {
+			continue
+		}
+		for _, orderBy := range statement.FindElements(".//" + Element_OrderBy) {
+			var resultMapId = orderBy.SelectAttrValue(Element_ResultMap, statement.SelectAttrValue(Element_ResultMap, ""))
+			var resultMap = resultMaps[resultMapId]
+			if resultMap == nil || orderBy.SelectAttr(orderByColumnsAttr) != nil {
+				continue
+			}
+			var properties = make([]string, 0, len(resultMap))
+			for _, property := range resultMap {
+				if property.Property != "" && property.Column != "" {
+					properties = append(properties, property.Property+":"+property.Column)
+				}
+			}
+			orderBy.CreateAttr(orderByColumnsAttr, strings.Join(properties, ","))
+		}
+	}
+}
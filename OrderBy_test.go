@@ -0,0 +1,109 @@
+package GoMybatis
+
+import (
+	"testing"
+)
+
+type OrderByTestMapper struct {
+	SelectAll    func(session *Session, sort []Sort) ([]map[string]string, error)                `mapperParams:"session,sort"`
+	SelectQuoted func(session *Session, sort string) ([]map[string]string, error)                `mapperParams:"session,sort"`
+	SelectByName func(session *Session, name string, sort []string) ([]map[string]string, error) `mapperParams:"session,name,sort"`
+}
+
+func newOrderByTestMapper() OrderByTestMapper {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper OrderByTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <resultMap id="BaseResultMap" tables="biz_activity">
+        <id column="id" property="id"/>
+        <result column="name" property="name" langType="string"/>
+        <result column="create_time" property="createTime" langType="time.Time"/>
+    </resultMap>
+    <select id="selectAll" resultMap="BaseResultMap">select * from biz_activity<orderBy param="sort" default="createTime desc"/></select>
+    <select id="selectQuoted" resultMap="BaseResultMap">select * from biz_activity<orderBy param="sort" allowed="name,create_time" dialect="postgres"/></select>
+    <selectTemplete id="selectByName" wheres="name?name = #{name}"><orderBy param="sort" allowed="createTime"/></selectTemplete>
+</mapper>`))
+	return mapper
+}
+
+func TestOrderBy(t *testing.T) {
+	var mapper = newOrderByTestMapper()
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+
+	//属性名按resultMap转为列名，未指定allowed时只允许resultMap中的属性
+	mapper.SelectAll(&session, []Sort{Desc("createTime"), Asc("name")})
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity order by create_time desc,name") {
+		t.Fatal("order by fail:", capture.LastSql())
+	}
+	mapper.SelectAll(&session, nil)
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity order by create_time desc") {
+		t.Fatal("order by default fail:", capture.LastSql())
+	}
+	if _, err := mapper.SelectAll(&session, []Sort{Asc("remark")}); err == nil {
+		t.Fatal("property not in resultMap must fail")
+	}
+	if _, err := mapper.SelectAll(&session, []Sort{Asc("name;drop table biz_activity")}); err == nil {
+		t.Fatal("illegal property must fail")
+	}
+
+	//方言引号，allowed可为列名
+	mapper.SelectQuoted(&session, "-createTime, name asc")
+	if compactSql(capture.LastSql()) != compactSql(`select * from biz_activity order by "create_time" desc,"name"`) {
+		t.Fatal("order by quoted fail:", capture.LastSql())
+	}
+	mapper.SelectQuoted(&session, "")
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity") {
+		t.Fatal("order by empty fail:", capture.LastSql())
+	}
+	if _, err := mapper.SelectQuoted(&session, "id"); err == nil {
+		t.Fatal("property not allowed must fail")
+	}
+	if _, err := mapper.SelectQuoted(&session, "name desc desc"); err == nil {
+		t.Fatal("illegal sort must fail")
+	}
+}
+
+func TestOrderBy_SelectTemplete(t *testing.T) {
+	var mapper = newOrderByTestMapper()
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	mapper.SelectByName(&session, "a", []string{"create_time desc"})
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity where name = ? order by create_time desc") {
+		t.Fatal("templete order by fail:", capture.LastSql())
+	}
+	//selectTemplete未指定resultMap时使用BaseResultMap将属性名转为列名
+	mapper.SelectByName(&session, "a", []string{"-createTime"})
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity where name = ? order by create_time desc") {
+		t.Fatal("templete order by property fail:", capture.LastSql())
+	}
+	if _, err := mapper.SelectByName(&session, "a", []string{"name"}); err == nil {
+		t.Fatal("property not allowed must fail")
+	}
+}
+
+func TestOrderBy_SessionDialect(t *testing.T) {
+	var mapper = newOrderByTestMapper()
+	//未指定dialect时使用session驱动的方言
+	var capture = postgresCaptureSession{}
+	var session = Session(&capture)
+	mapper.SelectByName(&session, "a", []string{"createTime"})
+	if compactSql(capture.LastSql()) != compactSql(`select * from biz_activity where name = ? order by "create_time"`) {
+		t.Fatal("order by session dialect fail:", capture.LastSql())
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	for dialect, expect := range map[Dialect]string{
+		Dialect_MySQL:     "`a`.`name`",
+		Dialect_Postgres:  `"a"."name"`,
+		Dialect_SQLServer: "[a].[name]",
+		"":                "a.name",
+	} {
+		if QuoteIdentifier(dialect, "a.name") != expect {
+			t.Fatal("quote identifier fail:", dialect, QuoteIdentifier(dialect, "a.name"))
+		}
+	}
+}
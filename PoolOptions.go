@@ -0,0 +1,37 @@
+package GoMybatis
+
+import (
+	"database/sql"
+	"time"
+)
+
+//连接池配置，打开数据源时设置（见 GoMybatisEngine.OpenWithOptions）。零值表示不修改 database/sql 的默认值，
+//与 database/sql 相同，MaxOpenConns、MaxIdleConns、ConnMaxLifetime 为负数时分别表示不限制、不保留空闲连接、不限制
+type PoolOptions struct {
+	MaxOpenConns    int           //最大打开连接数
+	MaxIdleConns    int           //最大空闲连接数
+	ConnMaxLifetime time.Duration //连接最大存活时间
+}
+
+//应用到db
+func (it PoolOptions) Apply(db *sql.DB) {
+	if it.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(it.MaxOpenConns)
+	}
+	if it.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(it.MaxIdleConns)
+	}
+	if it.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(it.ConnMaxLifetime)
+	}
+}
+
+//按配置打开数据库
+func openPool(driverName, dataSourceName string, options PoolOptions) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	options.Apply(db)
+	return db, nil
+}
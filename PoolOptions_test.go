@@ -0,0 +1,54 @@
+package GoMybatis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolOptions(t *testing.T) {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var router = GoMybatisDataSourceRouter{}.New(nil)
+	engine.SetDataSourceRouter(&router)
+	var db, err = engine.OpenDataSourceWithOptions("ds0", TestDriverName, "TestPool_ds0", PoolOptions{MaxOpenConns: 5, MaxIdleConns: 2, ConnMaxLifetime: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Stats().MaxOpenConnections != 5 {
+		t.Fatal("pool options not applied:", db.Stats().MaxOpenConnections)
+	}
+	if _, err := engine.OpenDataSource("ds1", TestDriverName, "TestPool_ds1"); err != nil {
+		t.Fatal(err)
+	}
+	var stats = engine.DataSourceStats()
+	if len(stats) != 2 || stats["ds0"].MaxOpenConnections != 5 || stats["ds1"].MaxOpenConnections != 0 {
+		t.Fatal("data source stats fail:", stats)
+	}
+
+	//Open 注册的数据源不以url（可能含账号密码）为名称
+	var defaultEngine = GoMybatisEngine{}.New()
+	defaultEngine.SetLogEnable(false)
+	defaultEngine.Open(TestDriverName, "root:secret@TestPool_default")
+	defaultEngine.Open(TestDriverName, "root:secret@TestPool_default2")
+	defaultEngine.Open(TestDriverName, "root:secret@TestPool_default")
+	stats = defaultEngine.DataSourceStats()
+	if _, ok := stats["default"]; !ok || len(stats) != 2 {
+		t.Fatal("default data source name fail:", stats)
+	}
+	if _, ok := stats["default_1"]; !ok {
+		t.Fatal("second data source name fail:", stats)
+	}
+	if !defaultEngine.DataSourceRouter().(*GoMybatisDataSourceRouter).HasDataSource("root:secret@TestPool_default2") {
+		t.Fatal("url must resolve to data source name")
+	}
+
+	//读写分离
+	var rw = ReadWriteDataSourceRouter{}.New(ReplicaStrategy_RoundRobin)
+	engine.SetDataSourceRouter(&rw)
+	engine.OpenWithOptions(TestDriverName, "TestPool_master", PoolOptions{MaxOpenConns: 3})
+	rw.OpenReplicaWithOptions(TestDriverName, "TestPool_replica", 1, PoolOptions{MaxOpenConns: 4})
+	stats = engine.DataSourceStats()
+	if len(stats) != 2 || stats["master"].MaxOpenConnections != 3 || stats["replica0"].MaxOpenConnections != 4 {
+		t.Fatal("read write data source stats fail:", stats)
+	}
+}
@@ -0,0 +1,467 @@
+package GoMybatis
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//sql条件，由 Eq()、In()、Or() 等函数创建。参数值一律作为sql参数（#{}）传递，列名只允许合法的标识符
+type Condition struct {
+	op     string
+	column string
+	values []interface{}
+	childs []Condition
+}
+
+//column = value
+func Eq(column string, value interface{}) Condition {
+	return Condition{op: "=", column: column, values: []interface{}{value}}
+}
+
+//column <> value
+func Ne(column string, value interface{}) Condition {
+	return Condition{op: "<>", column: column, values: []interface{}{value}}
+}
+
+//column > value
+func Gt(column string, value interface{}) Condition {
+	return Condition{op: ">", column: column, values: []interface{}{value}}
+}
+
+//column >= value
+func Ge(column string, value interface{}) Condition {
+	return Condition{op: ">=", column: column, values: []interface{}{value}}
+}
+
+//column < value
+func Lt(column string, value interface{}) Condition {
+	return Condition{op: "<", column: column, values: []interface{}{value}}
+}
+
+//column <= value
+func Le(column string, value interface{}) Condition {
+	return Condition{op: "<=", column: column, values: []interface{}{value}}
+}
+
+//column like pattern，pattern需自行包含 %
+func Like(column string, pattern interface{}) Condition {
+	return Condition{op: "like", column: column, values: []interface{}{pattern}}
+}
+
+//column in (values...)，只传一个切片参数时展开切片。values为空时条件恒为假
+func In(column string, values ...interface{}) Condition {
+	return Condition{op: "in", column: column, values: expandConditionValues(values)}
+}
+
+//column not in (values...)，values为空时忽略该条件
+func NotIn(column string, values ...interface{}) Condition {
+	return Condition{op: "not in", column: column, values: expandConditionValues(values)}
+}
+
+//column between from and to
+func Between(column string, from interface{}, to interface{}) Condition {
+	return Condition{op: "between", column: column, values: []interface{}{from, to}}
+}
+
+//column is null
+func IsNull(column string) Condition {
+	return Condition{op: "is null", column: column}
+}
+
+//column is not null
+func IsNotNull(column string) Condition {
+	return Condition{op: "is not null", column: column}
+}
+
+//(c1 and c2 ...)
+func And(conditions ...Condition) Condition {
+	return Condition{op: "and", childs: conditions}
+}
+
+//(c1 or c2 ...)
+func Or(conditions ...Condition) Condition {
+	return Condition{op: "or", childs: conditions}
+}
+
+func expandConditionValues(values []interface{}) []interface{} {
+	if len(values) != 1 || values[0] == nil {
+		return values
+	}
+	var v = reflect.ValueOf(values[0])
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return values
+	}
+	var expanded = make([]interface{}, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		expanded[i] = v.Index(i).Interface()
+	}
+	return expanded
+}
+
+//构建条件sql，参数写入writer。条件为空返回""
+func (it Condition) build(writer *sqlWriter) (string, error) {
+	switch it.op {
+	case "and", "or":
+		var items = []string{}
+		for _, child := range it.childs {
+			var item, err = child.build(writer)
+			if err != nil {
+				return "", err
+			}
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+		if len(items) <= 1 {
+			return strings.Join(items, ""), nil
+		}
+		return "(" + strings.Join(items, " "+it.op+" ") + ")", nil
+	}
+	if err := checkIdentifier(it.column); err != nil {
+		return "", err
+	}
+	switch it.op {
+	case "is null", "is not null":
+		return it.column + " " + it.op, nil
+	case "between":
+		return it.column + " between " + writer.arg(it.values[0]) + " and " + writer.arg(it.values[1]), nil
+	case "in", "not in":
+		if len(it.values) == 0 {
+			if it.op == "in" {
+				return "1 = 0", nil
+			}
+			return "", nil
+		}
+		var args = make([]string, len(it.values))
+		for i, value := range it.values {
+			args[i] = writer.arg(value)
+		}
+		return it.column + " " + it.op + " (" + strings.Join(args, ",") + ")", nil
+	default:
+		return it.column + " " + it.op + " " + writer.arg(it.values[0]), nil
+	}
+}
+
+//收集参数。params不为nil时参数写入params（参数名为 p0,p1...）返回 #{pN}，否则追加到args返回sql占位符
+type sqlWriter struct {
+	params map[string]interface{}
+	args   *[]interface{}
+}
+
+func (it *sqlWriter) arg(value interface{}) string {
+	if it.params == nil {
+		*it.args = append(*it.args, value)
+		return ast.SQLPlaceholder
+	}
+	var name = "p" + strconv.Itoa(len(it.params))
+	it.params[name] = value
+	return "#{" + name + "}"
+}
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+//select列：col、t.col、*、t.*、count(*)、count(distinct col)，可带别名
+var selectColumnRegex = regexp.MustCompile(`(?i)^(\*|[A-Za-z_][A-Za-z0-9_]*\.\*|[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?|[A-Za-z_][A-Za-z0-9_]*\((\*|(distinct\s+)?[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?)\))(\s+(as\s+)?[A-Za-z_][A-Za-z0-9_]*)?$`)
+var orderByRegex = regexp.MustCompile(`(?i)^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?(\s+(asc|desc))?$`)
+
+//构建 order by，columns为空返回""
+func buildOrderBy(columns []string) (string, error) {
+	if len(columns) == 0 {
+		return "", nil
+	}
+	for _, column := range columns {
+		if !orderByRegex.MatchString(strings.TrimSpace(column)) {
+			return "", utils.NewError("QueryBuilder", "illegal order by '", column, "'!")
+		}
+	}
+	return " order by " + strings.Join(columns, ","), nil
+}
+
+func checkIdentifier(name string) error {
+	if !identifierRegex.MatchString(name) {
+		return utils.NewError("QueryBuilder", "illegal identifier '", name, "'!")
+	}
+	return nil
+}
+
+//流式sql构建器，构建为 ast.Node 后通过 SqlBuilder.BuildSql 和 Session 执行。例如
+//
+//	var result []Activity
+//	err := GoMybatis.Select("id", "name").From("biz_activity").Where(GoMybatis.Eq("name", name), GoMybatis.In("id", ids)).OrderBy("create_time desc").Limit(10).Query(&engine, nil, &result)
+type QueryBuilder struct {
+	kind    ElementType
+	table   string
+	columns []string
+	values  [][]interface{}
+	sets    []querySet
+	wheres  []Condition
+	orders  []string
+	limit   int
+	offset  int
+	dialect Dialect
+
+	allowFullTable bool
+}
+
+type querySet struct {
+	column string
+	value  interface{}
+}
+
+//select columns...，columns为空时查询 *
+func Select(columns ...string) *QueryBuilder {
+	return &QueryBuilder{kind: Element_Select, columns: columns, limit: -1}
+}
+
+//update table set ...
+func Update(table string) *QueryBuilder {
+	return &QueryBuilder{kind: Element_Update, table: table, limit: -1}
+}
+
+//delete from table
+func DeleteFrom(table string) *QueryBuilder {
+	return &QueryBuilder{kind: Element_Delete, table: table, limit: -1}
+}
+
+//insert into table (columns...) values (...)
+func InsertInto(table string, columns ...string) *QueryBuilder {
+	return &QueryBuilder{kind: Element_Insert, table: table, columns: columns, limit: -1}
+}
+
+func (it *QueryBuilder) From(table string) *QueryBuilder {
+	it.table = table
+	return it
+}
+
+//追加条件，多次调用及多个条件之间为 and
+func (it *QueryBuilder) Where(conditions ...Condition) *QueryBuilder {
+	it.wheres = append(it.wheres, conditions...)
+	return it
+}
+
+//排序，例如 OrderBy("create_time desc", "id")
+func (it *QueryBuilder) OrderBy(columns ...string) *QueryBuilder {
+	it.orders = append(it.orders, columns...)
+	return it
+}
+
+func (it *QueryBuilder) Limit(limit int) *QueryBuilder {
+	it.limit = limit
+	return it
+}
+
+func (it *QueryBuilder) Offset(offset int) *QueryBuilder {
+	it.offset = offset
+	return it
+}
+
+//分页语法使用的数据库方言，默认 limit ? offset ?
+func (it *QueryBuilder) Dialect(dialect Dialect) *QueryBuilder {
+	it.dialect = dialect
+	return it
+}
+
+//允许没有where条件的update、delete（修改、删除全表），默认返回错误
+func (it *QueryBuilder) AllowFullTable() *QueryBuilder {
+	it.allowFullTable = true
+	return it
+}
+
+//update 设置列值
+func (it *QueryBuilder) Set(column string, value interface{}) *QueryBuilder {
+	it.sets = append(it.sets, querySet{column: column, value: value})
+	return it
+}
+
+//insert 一行数据，与 InsertInto() 的列一一对应，多次调用插入多行
+func (it *QueryBuilder) Values(values ...interface{}) *QueryBuilder {
+	it.values = append(it.values, values)
+	return it
+}
+
+//构建为 ast.Node，返回节点和参数表
+func (it *QueryBuilder) Nodes(parser ast.NodeParser) ([]ast.Node, map[string]interface{}, error) {
+	var element, params, err = it.element()
+	if err != nil {
+		return nil, nil, err
+	}
+	parser.Mapper = "QueryBuilder." + it.kind
+	return parser.Parser(element.Child), params, nil
+}
+
+//构建sql和参数，sql中的参数占位符与 SqlBuilder.BuildSql 相同，由 Session.ProcessSQL 转换
+func (it *QueryBuilder) Build(sqlBuilder SqlBuilder) (string, []interface{}, error) {
+	var sql, array_arg, err = it.build(sqlBuilder, sqlBuilder.NodeParser())
+	if err != nil {
+		return "", nil, err
+	}
+	array_arg, _ = ast.SplitRedactedArgs(array_arg)
+	return sql, array_arg, nil
+}
+
+//构建sql和参数，参数中保留日志脱敏标记（ast.RedactedArg），由 exeSql 还原
+func (it *QueryBuilder) build(sqlBuilder SqlBuilder, parser ast.NodeParser) (string, []interface{}, error) {
+	var nodes, params, err = it.Nodes(parser)
+	if err != nil {
+		return "", nil, err
+	}
+	var array_arg = []interface{}{}
+	sql, err := sqlBuilder.BuildSql(params, nodes, &array_arg)
+	if err != nil {
+		return "", nil, err
+	}
+	return sql, array_arg, nil
+}
+
+//执行查询，结果按json tag解码到result（结构体、切片、map等的指针）。session为nil时使用协程绑定的session或新建session
+func (it *QueryBuilder) Query(engine SessionEngine, session Session, result interface{}) error {
+	if it.kind != Element_Select {
+		return utils.NewError("QueryBuilder", "Query() only support select, use Exec() instead!")
+	}
+	var returnValue = reflect.ValueOf(result)
+	if returnValue.Kind() != reflect.Ptr || returnValue.IsNil() {
+		return utils.NewError("QueryBuilder", "Query() result must be a not nil pointer!")
+	}
+	var sql, array_arg, err = it.build(engine.SqlBuilder(), engineNodeParser(engine))
+	if err != nil {
+		return err
+	}
+	var resultMap map[string]*ResultProperty
+	if resultType := validateResultStructType(returnValue.Type()); resultType != nil {
+		resultMap = makeStructResultMap(*resultType)
+	}
+	return exeSql(Element_Select, RouterRequest{}, engine, session, sql, array_arg, resultMap, &returnValue)
+}
+
+//按结构体字段生成resultMap，列名为json tag，没有json tag时为字段名的蛇形命名
+func makeStructResultMap(structType reflect.Type) map[string]*ResultProperty {
+	var resultMap = make(map[string]*ResultProperty)
+	for i := 0; i < structType.NumField(); i++ {
+		var field = structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		var column = strings.Split(field.Tag.Get("json"), ",")[0]
+		if column == "-" {
+			continue
+		}
+		if column == "" {
+			column = SnakeString(field.Name)
+		}
+		resultMap[column] = &ResultProperty{XMLName: "result", Column: column, Property: field.Name}
+	}
+	return resultMap
+}
+
+//执行insert、update、delete，返回影响行数。session为nil时使用协程绑定的session或新建session
+func (it *QueryBuilder) Exec(engine SessionEngine, session Session) (int64, error) {
+	if it.kind == Element_Select {
+		return 0, utils.NewError("QueryBuilder", "Exec() not support select, use Query() instead!")
+	}
+	var sql, array_arg, err = it.build(engine.SqlBuilder(), engineNodeParser(engine))
+	if err != nil {
+		return 0, err
+	}
+	var rowsAffected int64
+	var returnValue = reflect.ValueOf(&rowsAffected)
+	err = exeSql(it.kind, RouterRequest{}, engine, session, sql, array_arg, nil, &returnValue)
+	return rowsAffected, err
+}
+
+//构建为与mapper xml相同结构的元素
+func (it *QueryBuilder) element() (*etree.Element, map[string]interface{}, error) {
+	if err := checkIdentifier(it.table); err != nil {
+		return nil, nil, err
+	}
+	var writer = &sqlWriter{params: map[string]interface{}{}}
+	var element = etree.NewElement(it.kind)
+	switch it.kind {
+	case Element_Select:
+		var columns = it.columns
+		if len(columns) == 0 {
+			columns = []string{"*"}
+		}
+		for _, column := range columns {
+			if !selectColumnRegex.MatchString(strings.TrimSpace(column)) {
+				return nil, nil, utils.NewError("QueryBuilder", "illegal select column '", column, "'!")
+			}
+		}
+		element.CreateCharData("select " + strings.Join(columns, ",") + " from " + it.table)
+	case Element_Update:
+		if len(it.sets) == 0 {
+			return nil, nil, utils.NewError("QueryBuilder", "update ", it.table, " need call Set()!")
+		}
+		element.CreateCharData("update " + it.table)
+		var sets = make([]string, len(it.sets))
+		for i, set := range it.sets {
+			if err := checkIdentifier(set.column); err != nil {
+				return nil, nil, err
+			}
+			sets[i] = set.column + " = " + writer.arg(set.value)
+		}
+		element.CreateElement(Element_Set).CreateCharData(strings.Join(sets, ","))
+	case Element_Delete:
+		element.CreateCharData("delete from " + it.table)
+	case Element_Insert:
+		if len(it.columns) == 0 || len(it.values) == 0 {
+			return nil, nil, utils.NewError("QueryBuilder", "insert into ", it.table, " need columns and Values()!")
+		}
+		for _, column := range it.columns {
+			if err := checkIdentifier(column); err != nil {
+				return nil, nil, err
+			}
+		}
+		var rows = make([]string, len(it.values))
+		for i, values := range it.values {
+			if len(values) != len(it.columns) {
+				return nil, nil, utils.NewError("QueryBuilder", "insert into ", it.table, " values length ", len(values), " != columns length ", len(it.columns), "!")
+			}
+			var args = make([]string, len(values))
+			for j, value := range values {
+				args[j] = writer.arg(value)
+			}
+			rows[i] = "(" + strings.Join(args, ",") + ")"
+		}
+		element.CreateCharData("insert into " + it.table + " (" + strings.Join(it.columns, ",") + ") values " + strings.Join(rows, ","))
+		return element, writer.params, nil
+	}
+	var wheres = []string{}
+	for _, condition := range it.wheres {
+		var where, err = condition.build(writer)
+		if err != nil {
+			return nil, nil, err
+		}
+		if where != "" {
+			wheres = append(wheres, where)
+		}
+	}
+	if len(wheres) != 0 {
+		element.CreateElement(Element_where).CreateCharData(strings.Join(wheres, " and "))
+	} else if (it.kind == Element_Update || it.kind == Element_Delete) && !it.allowFullTable {
+		return nil, nil, utils.NewError("QueryBuilder", string(it.kind)+" "+it.table+" without where condition, call AllowFullTable() to "+string(it.kind)+" the full table!")
+	}
+	if it.kind != Element_Select {
+		return element, writer.params, nil
+	}
+	if len(it.orders) != 0 {
+		var orderBy, err = buildOrderBy(it.orders)
+		if err != nil {
+			return nil, nil, err
+		}
+		element.CreateCharData(orderBy)
+	}
+	if it.limit >= 0 {
+		if it.dialect == Dialect_SQLServer {
+			element.CreateCharData(" offset " + writer.arg(it.offset) + " rows fetch next " + writer.arg(it.limit) + " rows only")
+		} else {
+			element.CreateCharData(" limit " + writer.arg(it.limit) + " offset " + writer.arg(it.offset))
+		}
+	}
+	return element, writer.params, nil
+}
@@ -0,0 +1,106 @@
+package GoMybatis
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+)
+
+func TestQueryBuilder_Build(t *testing.T) {
+	var engine = GoMybatisEngine{}.New()
+	var sql, args, err = Select("id", "name", "count(*) as total").From("biz_activity").
+		Where(Eq("name", "a"), Or(In("id", []string{"1", "2"}), IsNull("remark")), Between("version", 1, 3)).
+		OrderBy("create_time desc", "id").Limit(10).Offset(20).Build(engine.SqlBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expect = "select id,name,count(*) as total from biz_activity where name = ? and (id in (?,?) or remark is null) and version between ? and ? order by create_time desc,id limit ? offset ?"
+	if compactSql(strings.Replace(sql, ast.SQLPlaceholder, "?", -1)) != compactSql(expect) {
+		t.Fatal("build select fail:", sql)
+	}
+	if len(args) != 7 || args[0] != "a" || args[1] != "1" || args[5] != 10 || args[6] != 20 {
+		t.Fatal("build select args fail:", args)
+	}
+
+	sql, _, err = Select().From("biz_activity").Where(In("id")).Build(engine.SqlBuilder())
+	if err != nil || compactSql(sql) != compactSql("select * from biz_activity where 1 = 0") {
+		t.Fatal("empty in must be false:", sql, err)
+	}
+
+	//列名不允许拼接sql
+	for _, builder := range []*QueryBuilder{
+		Select("id").From("biz_activity where 1=1"),
+		Select("id;drop table t").From("biz_activity"),
+		Select("id").From("biz_activity").Where(Eq("name = 'a' or 1", 1)),
+		Select("id").From("biz_activity").OrderBy("id; drop table t"),
+	} {
+		if _, _, err = builder.Build(engine.SqlBuilder()); err == nil {
+			t.Fatal("illegal identifier must fail")
+		}
+	}
+}
+
+func TestQueryBuilder_Exec(t *testing.T) {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var capture = CaptureSession{}
+	if _, err := Update("biz_activity").Set("name", "b").Set("remark", nil).Where(Eq("id", "1")).Exec(&engine, &capture); err != nil {
+		t.Fatal(err)
+	}
+	if compactSql(capture.LastSql()) != compactSql("update biz_activity set name = ?,remark = ? where id = ?") {
+		t.Fatal("update fail:", capture.LastSql())
+	}
+	if _, err := InsertInto("biz_activity", "id", "name").Values("1", "a").Values("2", "b").Exec(&engine, &capture); err != nil {
+		t.Fatal(err)
+	}
+	if compactSql(capture.LastSql()) != compactSql("insert into biz_activity (id,name) values (?,?),(?,?)") || len(capture.LastArgs()) != 4 {
+		t.Fatal("insert fail:", capture.LastSql(), capture.LastArgs())
+	}
+	if _, err := DeleteFrom("biz_activity").Where(Ne("delete_flag", 1)).Exec(&engine, &capture); err != nil {
+		t.Fatal(err)
+	}
+	if compactSql(capture.LastSql()) != compactSql("delete from biz_activity where delete_flag <> ?") {
+		t.Fatal("delete fail:", capture.LastSql())
+	}
+
+	//没有where条件的update、delete需要 AllowFullTable()
+	if _, err := Update("biz_activity").Set("name", "b").Exec(&engine, &capture); err == nil || !strings.Contains(err.Error(), "AllowFullTable") {
+		t.Fatal("update without where must fail:", err)
+	}
+	if _, err := DeleteFrom("biz_activity").Where(NotIn("id")).Exec(&engine, &capture); err == nil || !strings.Contains(err.Error(), "AllowFullTable") {
+		t.Fatal("delete with empty where must fail:", err)
+	}
+	if _, err := DeleteFrom("biz_activity").AllowFullTable().Exec(&engine, &capture); err != nil {
+		t.Fatal(err)
+	}
+	if compactSql(capture.LastSql()) != compactSql("delete from biz_activity") {
+		t.Fatal("delete full table fail:", capture.LastSql())
+	}
+}
+
+func TestQueryBuilder_Query(t *testing.T) {
+	resetTestDriverState("TestQueryBuilder_Query")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	if _, err := engine.Open(TestDriverName, "TestQueryBuilder_Query"); err != nil {
+		t.Fatal(err)
+	}
+	var state = testDriverState("TestQueryBuilder_Query")
+	state.Columns = []string{"id", "name"}
+	state.Rows = [][]driver.Value{{"1", "a"}, {"2", "b"}}
+	var result []struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := Select("id", "name").From("biz_activity").Where(Like("name", "%a%")).Query(&engine, nil, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result[1].Name != "b" {
+		t.Fatal("query result fail:", result)
+	}
+	if len(state.Queries) != 1 || compactSql(state.Queries[0]) != compactSql("select id,name from biz_activity where name like ?") {
+		t.Fatal("query sql fail:", state.Queries)
+	}
+}
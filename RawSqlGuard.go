@@ -0,0 +1,176 @@
+package GoMybatis
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//${} 检查模式
+type RawGuardMode = int
+
+const (
+	RawGuardMode_Off    RawGuardMode = iota //不检查，原样替换（默认，兼容旧版本）
+	RawGuardMode_Reject                     //只允许数字、布尔、标识符或注册的值，其余返回错误
+	RawGuardMode_Escape                     //只允许数字、布尔、标识符或注册的值，其余按方言转义为字符串字面量
+)
+
+//默认标识符白名单：列名、表名，例如 create_time、a.name
+var DefaultRawIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+//${} 原样替换的sql注入检查（实现 ast.RawArgGuard），每个引擎一个，见 GoMybatisEngine.RawSqlGuard()。例如
+//
+//	engine.RawSqlGuard().SetMode(GoMybatis.RawGuardMode_Reject)
+//	engine.RawSqlGuard().Allow("orderBy", "create_time desc", "id") //order by ${orderBy} 只允许这些值
+//
+//被拒绝或转义的值写入审计日志
+type RawSqlGuard struct {
+	mutex      *sync.RWMutex
+	mode       RawGuardMode
+	dialect    Dialect
+	identifier *regexp.Regexp
+	allowed    map[string]map[string]bool //表达式 -> 允许的值
+	log        Log                        //审计日志，为nil时不输出
+}
+
+//支持${}检查、日志脱敏的引擎（GoMybatisEngine），SessionEngine 的可选扩展
+type rawSqlGuardEngine interface {
+	RawSqlGuard() *RawSqlGuard
+}
+
+type logRedactorEngine interface {
+	LogRedactor() *LogRedactor
+}
+
+//引擎sql构建器的节点解析器，未设置${}检查、日志脱敏时使用引擎的 RawSqlGuard、LogRedactor（包括 SetSqlBuilder 设置的构建器）
+func engineNodeParser(sessionEngine SessionEngine) ast.NodeParser {
+	var parser = sessionEngine.SqlBuilder().NodeParser()
+	if engine, ok := sessionEngine.(rawSqlGuardEngine); ok && parser.Holder.RawGuard == nil && engine.RawSqlGuard() != nil {
+		parser.Holder.RawGuard = engine.RawSqlGuard()
+	}
+	if engine, ok := sessionEngine.(logRedactorEngine); ok && parser.Holder.Redactor == nil && engine.LogRedactor() != nil {
+		parser.Holder.Redactor = engine.LogRedactor()
+	}
+	return parser
+}
+
+func (it RawSqlGuard) New(log Log) RawSqlGuard {
+	it.mutex = &sync.RWMutex{}
+	it.mode = RawGuardMode_Off
+	it.dialect = Dialect_MySQL
+	it.identifier = DefaultRawIdentifierPattern
+	it.allowed = map[string]map[string]bool{}
+	it.log = log
+	return it
+}
+
+func (it *RawSqlGuard) SetMode(mode RawGuardMode) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.mode = mode
+}
+
+func (it *RawSqlGuard) Mode() RawGuardMode {
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	return it.mode
+}
+
+//RawGuardMode_Escape 转义字符串使用的方言，默认mysql。执行时优先使用session驱动的方言（sql构建参数 _databaseId），未知时使用该方言
+func (it *RawSqlGuard) SetDialect(dialect Dialect) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.dialect = dialect
+}
+
+//设置标识符白名单，nil则只允许数字、布尔和注册的值
+func (it *RawSqlGuard) SetIdentifierPattern(pattern *regexp.Regexp) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.identifier = pattern
+}
+
+//为 ${expression} 注册允许的值，注册后该表达式只允许这些值（不再按标识符白名单检查）
+func (it *RawSqlGuard) Allow(expression string, values ...string) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	var allowed = it.allowed[expression]
+	if allowed == nil {
+		allowed = map[string]bool{}
+		it.allowed[expression] = allowed
+	}
+	for _, v := range values {
+		allowed[v] = true
+	}
+}
+
+//设置审计日志
+func (it *RawSqlGuard) SetLog(log Log) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.log = log
+}
+
+func (it *RawSqlGuard) Guard(expression string, value interface{}, env map[string]interface{}) (string, error) {
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	if it.mode == RawGuardMode_Off {
+		return fmt.Sprint(value), nil
+	}
+	var v = reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), nil
+	}
+	if value == nil || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return it.deny(expression, "nil")
+	}
+	var str = fmt.Sprint(v.Interface())
+	if allowed, ok := it.allowed[expression]; ok {
+		if allowed[str] {
+			return str, nil
+		}
+	} else if it.identifier != nil && it.identifier.MatchString(str) {
+		return str, nil
+	}
+	if it.mode == RawGuardMode_Escape {
+		it.audit("escape", expression, str)
+		var dialect = it.dialect
+		if databaseId, ok := env[ast.DatabaseIdVar].(string); ok && DialectOf(databaseId) != "" {
+			dialect = DialectOf(databaseId)
+		}
+		return EscapeSqlString(dialect, str), nil
+	}
+	return it.deny(expression, str)
+}
+
+func (it *RawSqlGuard) deny(expression string, value string) (string, error) {
+	it.audit("reject", expression, value)
+	return "", utils.NewError("RawSqlGuard", "${"+expression+"} value "+strconv.Quote(value)+" not allowed!")
+}
+
+func (it *RawSqlGuard) audit(action string, expression string, value string) {
+	if it.log != nil {
+		it.log.Println([]byte("[GoMybatis] [RawSqlGuard] " + action + " ${" + expression + "} value = " + strconv.Quote(value)))
+	}
+}
+
+//转义为方言的字符串字面量，例如 O'Brien 转为 'O”Brien'（mysql同时转义反斜杠，去除NUL字符）
+func EscapeSqlString(dialect Dialect, value string) string {
+	value = strings.Replace(value, "\x00", "", -1)
+	value = strings.Replace(value, "'", "''", -1)
+	if dialect == Dialect_MySQL {
+		value = strings.Replace(value, `\`, `\\`, -1)
+	}
+	return "'" + value + "'"
+}
@@ -0,0 +1,140 @@
+package GoMybatis
+
+import (
+	"strings"
+	"testing"
+)
+
+type RawSqlGuardTestMapper struct {
+	SelectOrderBy func(session *Session, orderBy string) ([]map[string]string, error)            `mapperParams:"session,orderBy"`
+	SelectByName  func(session *Session, table string, name string) ([]map[string]string, error) `mapperParams:"session,table,name"`
+}
+
+//记录审计日志
+type rawSqlGuardTestLog struct {
+	messages *[]string
+}
+
+func (it rawSqlGuardTestLog) QueueLen() int {
+	return 0
+}
+
+func (it rawSqlGuardTestLog) Println(messages []byte) {
+	*it.messages = append(*it.messages, string(messages))
+}
+
+func newRawSqlGuardTestMapper(mode RawGuardMode) (RawSqlGuardTestMapper, *[]string) {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var audits = &[]string{}
+	engine.SetLog(rawSqlGuardTestLog{messages: audits})
+	engine.RawSqlGuard().SetMode(mode)
+	engine.RawSqlGuard().Allow("orderBy", "id", "create_time desc")
+	var mapper RawSqlGuardTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectOrderBy">select * from biz_activity order by ${orderBy}</select>
+    <select id="selectByName">select * from ${table} where name = ${name}</select>
+</mapper>`))
+	return mapper, audits
+}
+
+func TestRawSqlGuard_Reject(t *testing.T) {
+	var mapper, audits = newRawSqlGuardTestMapper(RawGuardMode_Reject)
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	if _, err := mapper.SelectOrderBy(&session, "create_time desc"); err != errCaptureQuery {
+		t.Fatal("allowed value must pass,", err)
+	}
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity order by create_time desc") {
+		t.Fatal("allowed value fail:", capture.LastSql())
+	}
+	//注册了允许值的表达式不再按标识符检查
+	if _, err := mapper.SelectOrderBy(&session, "name"); err == nil || err == errCaptureQuery {
+		t.Fatal("value not in allowed set must be rejected")
+	}
+	if _, err := mapper.SelectByName(&session, "biz_activity", "O'Brien"); err == nil || !strings.Contains(err.Error(), "${name}") {
+		t.Fatal("not identifier value must be rejected,", err)
+	}
+	if len(*audits) != 2 || !strings.Contains((*audits)[1], `reject ${name} value = "O'Brien"`) {
+		t.Fatal("reject must write audit log,", *audits)
+	}
+}
+
+func TestRawSqlGuard_Escape(t *testing.T) {
+	var mapper, audits = newRawSqlGuardTestMapper(RawGuardMode_Escape)
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	if _, err := mapper.SelectByName(&session, "biz_activity", `O'Brien\`); err != errCaptureQuery {
+		t.Fatal(err)
+	}
+	if compactSql(capture.LastSql()) != compactSql(`select * from biz_activity where name = 'O''Brien\\'`) {
+		t.Fatal("escape fail:", capture.LastSql())
+	}
+	if len(*audits) != 1 || !strings.Contains((*audits)[0], "escape ${name}") {
+		t.Fatal("escape must write audit log,", *audits)
+	}
+	//只有mysql转义反斜杠，其余方言反斜杠不是转义字符
+	for dialect, expect := range map[Dialect]string{
+		Dialect_MySQL:     `'a\\''b\\'`,
+		Dialect_Postgres:  `'a\''b\'`,
+		Dialect_SQLite:    `'a\''b\'`,
+		Dialect_SQLServer: `'a\''b\'`,
+		"":                `'a\''b\'`,
+	} {
+		if EscapeSqlString(dialect, "a\\'b\\\x00") != expect {
+			t.Fatal("escape fail:", dialect, EscapeSqlString(dialect, "a\\'b\\\x00"))
+		}
+	}
+}
+
+func TestRawSqlGuard_Off(t *testing.T) {
+	var mapper, audits = newRawSqlGuardTestMapper(RawGuardMode_Off)
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	mapper.SelectByName(&session, "biz_activity", "1 or 1=1")
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity where name = 1 or 1=1") || len(*audits) != 0 {
+		t.Fatal("guard off must keep raw value:", capture.LastSql())
+	}
+}
+
+//驱动为postgres的session
+type postgresCaptureSession struct {
+	CaptureSession
+}
+
+func (it *postgresCaptureSession) DatabaseId() string {
+	return "postgres"
+}
+
+func TestRawSqlGuard_SqlBuilderDialect(t *testing.T) {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	//替换sql构建器后仍使用引擎的${}检查
+	var builder = GoMybatisSqlBuilder{}.New(engine.SqlArgTypeConvert(), *engine.SqlBuilder().ExpressionEngineProxy(), engine.Log(), false)
+	engine.SetSqlBuilder(&builder)
+	engine.RawSqlGuard().SetMode(RawGuardMode_Escape)
+	var mapper RawSqlGuardTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectOrderBy">select * from biz_activity order by ${orderBy}</select>
+    <select id="selectByName">select * from ${table} where name = ${name}</select>
+</mapper>`))
+	var capture = postgresCaptureSession{}
+	var session = Session(&capture)
+	mapper.SelectByName(&session, "biz_activity", `O'Brien\`)
+	if compactSql(capture.LastSql()) != compactSql(`select * from biz_activity where name = 'O''Brien\'`) {
+		t.Fatal("escape must use the session dialect:", capture.LastSql())
+	}
+	for databaseId, expect := range map[string]string{
+		"sqlite3": `select * from biz_activity where name = 'O''Brien\'`,
+		"mysql":   `select * from biz_activity where name = 'O''Brien\\'`,
+	} {
+		var capture = databaseIdCaptureSession{databaseId: databaseId}
+		var session = Session(&capture)
+		mapper.SelectByName(&session, "biz_activity", `O'Brien\`)
+		if compactSql(capture.LastSql()) != compactSql(expect) {
+			t.Fatal("escape must use the session dialect '"+databaseId+"':", capture.LastSql())
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package GoMybatis
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//从库选择策略
+type ReplicaStrategy = int
+
+const (
+	ReplicaStrategy_RoundRobin ReplicaStrategy = iota //轮询
+	ReplicaStrategy_Weighted                          //按权重平滑轮询
+	ReplicaStrategy_LeastConn                         //使用中的连接数最少（sql.DBStats.InUse），相同时轮询
+)
+
+type routerDataSource struct {
+	driver        string
+	url           string
+	db            *sql.DB
+	weight        int
+	currentWeight int //平滑加权轮询的当前权重
+}
+
+//读写分离数据源路由：事务外的<select>、<selectTemplete>语句使用从库，其余语句和事务使用主库。例如
+//
+//	var router = GoMybatis.ReadWriteDataSourceRouter{}.New(GoMybatis.ReplicaStrategy_RoundRobin)
+//	engine.SetDataSourceRouter(&router)
+//	engine.Open("mysql", masterUri)             //主库
+//	router.OpenReplica("mysql", replicaUri, 1)  //从库
+//
+//单次调用强制使用主库：ctx参数使用 GoMybatis.WithForceMaster(ctx)，或mapper方法tag forceMaster:"true"。
+//设置 SetHealthChecker 后读请求不使用不健康的从库，从库都不健康时使用主库
+type ReadWriteDataSourceRouter struct {
+	mutex         *sync.Mutex
+	master        *routerDataSource
+	replicas      []*routerDataSource
+	strategy      ReplicaStrategy
+	next          int //轮询位置
+	healthChecker *HealthChecker
+}
+
+func (it ReadWriteDataSourceRouter) New(strategy ReplicaStrategy) ReadWriteDataSourceRouter {
+	it.mutex = &sync.Mutex{}
+	it.strategy = strategy
+	it.replicas = []*routerDataSource{}
+	return it
+}
+
+//设置主库，该方法会被 GoMybatisEngine.Open 调用
+func (it *ReadWriteDataSourceRouter) SetDB(driver string, url string, db *sql.DB) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.master = &routerDataSource{driver: driver, url: url, db: db}
+}
+
+//添加从库，weight为 ReplicaStrategy_Weighted 的权重（<=0 按1）
+func (it *ReadWriteDataSourceRouter) AddReplica(driver string, url string, db *sql.DB, weight int) {
+	if weight <= 0 {
+		weight = 1
+	}
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.replicas = append(it.replicas, &routerDataSource{driver: driver, url: url, db: db, weight: weight})
+}
+
+//打开并添加从库
+func (it *ReadWriteDataSourceRouter) OpenReplica(driverName, dataSourceName string, weight int) (*sql.DB, error) {
+	return it.OpenReplicaWithOptions(driverName, dataSourceName, weight, PoolOptions{})
+}
+
+//打开并添加从库，设置连接池
+func (it *ReadWriteDataSourceRouter) OpenReplicaWithOptions(driverName, dataSourceName string, weight int, options PoolOptions) (*sql.DB, error) {
+	db, err := openPool(driverName, dataSourceName, options)
+	if err != nil {
+		return nil, err
+	}
+	it.AddReplica(driverName, dataSourceName, db, weight)
+	return db, nil
+}
+
+//设置健康检查，见 HealthChecker
+func (it *ReadWriteDataSourceRouter) SetHealthChecker(checker *HealthChecker) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.healthChecker = checker
+}
+
+//停止设置的健康检查，见 StoppableRouter
+func (it *ReadWriteDataSourceRouter) Stop() {
+	it.mutex.Lock()
+	var checker = it.healthChecker
+	it.mutex.Unlock()
+	if checker != nil {
+		checker.Stop()
+	}
+}
+
+//数据源，主库为 master，从库按添加顺序为 replica0、replica1...
+func (it *ReadWriteDataSourceRouter) DataSources() map[string]*sql.DB {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	var dataSources = map[string]*sql.DB{}
+	if it.master != nil {
+		dataSources["master"] = it.master.db
+	}
+	for i, replica := range it.replicas {
+		dataSources["replica"+strconv.Itoa(i)] = replica.db
+	}
+	return dataSources
+}
+
+//使用主库
+func (it *ReadWriteDataSourceRouter) Router(mapperName string, engine SessionEngine) (Session, error) {
+	it.mutex.Lock()
+	var master = it.master
+	it.mutex.Unlock()
+	if master == nil {
+		return nil, utils.NewError("ReadWriteDataSourceRouter", "router not find master datasource opened ! do you forget invoke GoMybatis.GoMybatisEngine{}.New().Open(\"driverName\", Uri)?")
+	}
+	return newRouterSession(master.driver, master.url, master.db, engine), nil
+}
+
+func (it *ReadWriteDataSourceRouter) RouterStatement(request RouterRequest, engine SessionEngine) (Session, error) {
+	if request.DataSource != "" {
+		return nil, utils.NewError("ReadWriteDataSourceRouter", "datasource '"+request.DataSource+"' not registered! ReadWriteDataSourceRouter not support named datasource")
+	}
+	if !request.ReadOnly || request.ForceMaster {
+		return it.Router(request.MapperName, engine)
+	}
+	var replica = it.pickReplica()
+	if replica == nil {
+		return it.Router(request.MapperName, engine)
+	}
+	return newRouterSession(replica.driver, replica.url, replica.db, engine), nil
+}
+
+//按策略选择健康的从库，没有从库返回nil
+func (it *ReadWriteDataSourceRouter) pickReplica() *routerDataSource {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	var replicas = it.replicas
+	if it.healthChecker != nil {
+		replicas = make([]*routerDataSource, 0, len(it.replicas))
+		for i, replica := range it.replicas {
+			if it.healthChecker.IsHealthy("replica" + strconv.Itoa(i)) {
+				replicas = append(replicas, replica)
+			}
+		}
+	}
+	var size = len(replicas)
+	if size == 0 {
+		return nil
+	}
+	switch it.strategy {
+	case ReplicaStrategy_Weighted:
+		var total = 0
+		var best *routerDataSource
+		for _, replica := range replicas {
+			replica.currentWeight += replica.weight
+			total += replica.weight
+			if best == nil || replica.currentWeight > best.currentWeight {
+				best = replica
+			}
+		}
+		best.currentWeight -= total
+		return best
+	case ReplicaStrategy_LeastConn:
+		var best *routerDataSource
+		var bestInUse = 0
+		for i := 0; i < size; i++ {
+			var replica = replicas[(it.next+i)%size]
+			var inUse = replica.db.Stats().InUse
+			if best == nil || inUse < bestInUse {
+				best, bestInUse = replica, inUse
+			}
+		}
+		it.next = (it.next + 1) % size
+		return best
+	default:
+		var replica = replicas[it.next%size]
+		it.next = (it.next + 1) % size
+		return replica
+	}
+}
+
+func (it *ReadWriteDataSourceRouter) Name() string {
+	return "ReadWriteDataSourceRouter"
+}
+
+type forceMasterKey struct{}
+
+//返回强制使用主库的ctx，作为mapper方法的context.Context参数传入
+func WithForceMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceMasterKey{}, true)
+}
+
+//ctx是否强制使用主库
+func IsForceMaster(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	var force, _ = ctx.Value(forceMasterKey{}).(bool)
+	return force
+}
@@ -0,0 +1,127 @@
+package GoMybatis
+
+import (
+	"context"
+	"testing"
+)
+
+type ReadWriteTestMapper struct {
+	SelectAll       func(ctx context.Context) ([]map[string]string, error)           `mapperParams:"ctx"`
+	SelectByName    func(session *Session, name string) ([]map[string]string, error) `mapperParams:"session,name"`
+	SelectFromTempl func() ([]map[string]string, error)
+	SelectMaster    func() ([]map[string]string, error)                   `forceMaster:"true"`
+	UpdateName      func(ctx context.Context, name string) (int64, error) `mapperParams:"ctx,name"`
+}
+
+func newReadWriteTestMapper(t *testing.T, name string, strategy ReplicaStrategy, weights ...int) (ReadWriteTestMapper, *ReadWriteDataSourceRouter) {
+	resetTestDriverState(name + "_master")
+	for i := range weights {
+		resetTestDriverState(name + "_replica" + string(rune('0'+i)))
+	}
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var router = ReadWriteDataSourceRouter{}.New(strategy)
+	engine.SetDataSourceRouter(&router)
+	if _, err := engine.Open(TestDriverName, name+"_master"); err != nil {
+		t.Fatal(err)
+	}
+	for i, weight := range weights {
+		if _, err := router.OpenReplica(TestDriverName, name+"_replica"+string(rune('0'+i)), weight); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var mapper ReadWriteTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <resultMap id="BaseResultMap" tables="biz_activity">
+        <id column="id" property="id"/>
+    </resultMap>
+    <select id="selectAll">select * from biz_activity</select>
+    <select id="selectByName">select * from biz_activity where name = #{name}</select>
+    <selectTemplete id="selectFromTempl"/>
+    <select id="selectMaster">select * from biz_activity</select>
+    <update id="updateName">update biz_activity set name = #{name}</update>
+</mapper>`))
+	return mapper, &router
+}
+
+func TestReadWriteDataSourceRouter_RoundRobin(t *testing.T) {
+	var mapper, router = newReadWriteTestMapper(t, "TestReadWrite_RoundRobin", ReplicaStrategy_RoundRobin, 1, 1)
+	for i := 0; i < 3; i++ {
+		if _, err := mapper.SelectAll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mapper.SelectFromTempl()
+	var master = testDriverState("TestReadWrite_RoundRobin_master")
+	var replica0 = testDriverState("TestReadWrite_RoundRobin_replica0")
+	var replica1 = testDriverState("TestReadWrite_RoundRobin_replica1")
+	if len(master.Queries) != 0 || len(replica0.Queries) != 2 || len(replica1.Queries) != 2 {
+		t.Fatal("select must round robin replicas:", master.Queries, replica0.Queries, replica1.Queries)
+	}
+
+	//写、强制主库使用主库
+	if _, err := mapper.UpdateName(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	mapper.SelectAll(WithForceMaster(context.Background()))
+	mapper.SelectMaster()
+	if len(master.Execs) != 1 || len(master.Queries) != 2 || len(replica0.Execs)+len(replica1.Execs) != 0 {
+		t.Fatal("write and force master must use master:", master.Execs, master.Queries)
+	}
+
+	//事务session由Router创建，使用主库
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var session, err = router.Router("", &engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	mapper.SelectByName(&session, "a")
+	if len(master.Queries) != 3 {
+		t.Fatal("tx session must use master:", master.Queries)
+	}
+}
+
+func TestReadWriteDataSourceRouter_Weighted(t *testing.T) {
+	var _, router = newReadWriteTestMapper(t, "TestReadWrite_Weighted", ReplicaStrategy_Weighted, 3, 1)
+	var picks = ""
+	for i := 0; i < 8; i++ {
+		picks += router.pickReplica().url[len("TestReadWrite_Weighted_replica"):]
+	}
+	if picks != "00100010" {
+		t.Fatal("weighted pick fail:", picks)
+	}
+}
+
+func TestReadWriteDataSourceRouter_LeastConn(t *testing.T) {
+	var mapper, router = newReadWriteTestMapper(t, "TestReadWrite_LeastConn", ReplicaStrategy_LeastConn, 1, 1)
+	//占用replica0的连接
+	var conn, err = router.replicas[0].db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for i := 0; i < 3; i++ {
+		mapper.SelectAll(nil)
+	}
+	if n := len(testDriverState("TestReadWrite_LeastConn_replica1").Queries); n != 3 {
+		t.Fatal("least conn must use idle replica, got queries:", n)
+	}
+}
+
+func TestReadWriteDataSourceRouter_NoReplica(t *testing.T) {
+	var mapper, _ = newReadWriteTestMapper(t, "TestReadWrite_NoReplica", ReplicaStrategy_RoundRobin)
+	if _, err := mapper.SelectAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(testDriverState("TestReadWrite_NoReplica_master").Queries) != 1 {
+		t.Fatal("no replica must use master")
+	}
+	var engine = GoMybatisEngine{}.New()
+	var router = ReadWriteDataSourceRouter{}.New(ReplicaStrategy_RoundRobin)
+	if _, err := router.RouterStatement(RouterRequest{ReadOnly: true}, &engine); err == nil {
+		t.Fatal("no master must fail")
+	}
+}
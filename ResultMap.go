@@ -6,4 +6,5 @@ type ResultProperty struct {
     Property  string
     LangType  string
     IsPrimary bool
+    Generator string // 主键生成器名称，例如 <id generator="snowflake"/>
 }
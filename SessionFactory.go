@@ -1,7 +1,10 @@
 package GoMybatis
 
 import (
+	"reflect"
 	"sync"
+
+	"github.com/zhuxiujia/GoMybatis/utils"
 )
 
 type SessionFactory struct {
@@ -14,18 +17,23 @@ func (it SessionFactory) New(Engine SessionEngine) SessionFactory {
 	return it
 }
 
-func (it *SessionFactory) NewSession(mapperName string, sessionType SessionType) Session {
+//经数据源路由新建session，路由失败或路由返回的session不支持sessionType时返回错误
+func (it *SessionFactory) NewSession(mapperName string, sessionType SessionType) (Session, error) {
 	if it.Engine == nil {
 		panic("[GoMybatis] SessionFactory not init! you must call method SessionFactory.New(*)")
 	}
+	var session, err = it.Engine.NewSession(mapperName)
+	if err != nil {
+		return nil, err
+	}
+	return it.wrapSession(session, sessionType)
+}
+
+//按sessionType包装路由创建的session，并加入工厂管理，不支持时关闭session并返回错误
+func (it *SessionFactory) wrapSession(session Session, sessionType SessionType) (Session, error) {
 	var newSession Session
-	var err error
 	switch sessionType {
 	case SessionType_Default:
-		var session, err = it.Engine.NewSession(mapperName)
-		if err != nil {
-			panic(err)
-		}
 		var factorySession = SessionFactorySession{
 			Session: session,
 			Factory: it,
@@ -33,16 +41,23 @@ func (it *SessionFactory) NewSession(mapperName string, sessionType SessionType)
 		newSession = Session(&factorySession)
 		break
 	case SessionType_Local:
-		newSession, err = it.Engine.NewSession(mapperName)
-		if err != nil {
-			panic(err)
+		newSession = session
+		break
+	case SessionType_Batch:
+		var local, ok = session.(*LocalSession)
+		if !ok {
+			session.Close()
+			return nil, utils.NewError("SessionFactory", "SessionType_Batch only support *LocalSession, but router return "+reflect.TypeOf(session).String())
 		}
+		var batchSession = BatchSession{}.New(local)
+		newSession = Session(&batchSession)
 		break
 	default:
-		panic("[GoMybatis] newSession() must have a SessionType!")
+		session.Close()
+		return nil, utils.NewError("SessionFactory", "newSession() must have a SessionType!")
 	}
 	it.SessionMap.Store(newSession.Id(), newSession)
-	return newSession
+	return newSession, nil
 }
 
 func (it *SessionFactory) GetSession(id string) Session {
@@ -18,6 +18,18 @@ func (it *SessionFactorySession) Id() string {
 	}
 	return it.Session.Id()
 }
+func (it *SessionFactorySession) DatabaseId() string {
+	if it.Session == nil {
+		return ""
+	}
+	return sessionDatabaseId(it.Session)
+}
+func (it *SessionFactorySession) DataSource() string {
+	if it.Session == nil {
+		return ""
+	}
+	return sessionDataSource(it.Session)
+}
 func (it *SessionFactorySession) Query(sqlorArgs string) ([]map[string][]byte, error) {
 	if it.Session == nil {
 		return nil, utils.NewError("SessionFactorySession", " can not run Id(),it.Session == nil")
@@ -5,4 +5,5 @@ type SessionType = int
 const (
 	SessionType_Default      SessionType = iota //默认session类型
 	SessionType_Local                           //本地session
+	SessionType_Batch                           //批量执行session，见 BatchSession
 )
@@ -0,0 +1,518 @@
+package GoMybatis
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//分片算法，按分片键的值计算分片序号 [0, count)
+type ShardingAlgorithm interface {
+	Shard(value interface{}, count int) (int, error)
+}
+
+//自定义分片算法
+type ShardingFunc func(value interface{}, count int) (int, error)
+
+func (it ShardingFunc) Shard(value interface{}, count int) (int, error) {
+	return it(value, count)
+}
+
+//取模分片，值需为整数（或整数字符串）
+type ModSharding struct {
+}
+
+func (it ModSharding) Shard(value interface{}, count int) (int, error) {
+	var n, err = shardingInt(value)
+	if err != nil {
+		return 0, err
+	}
+	var index = int(n % int64(count))
+	if index < 0 {
+		index += count
+	}
+	return index, nil
+}
+
+//哈希分片，值按 fmt.Sprint 的 fnv32a 哈希取模
+type HashSharding struct {
+}
+
+func (it HashSharding) Shard(value interface{}, count int) (int, error) {
+	var hash = fnv.New32a()
+	hash.Write([]byte(fmt.Sprint(value)))
+	return int(hash.Sum32() % uint32(count)), nil
+}
+
+//范围分片，Bounds为升序的分片上界（不含），例如 Bounds: []int64{1000, 2000} 时 <1000 为0，[1000,2000)为1，>=2000为2
+type RangeSharding struct {
+	Bounds []int64
+}
+
+func (it RangeSharding) Shard(value interface{}, count int) (int, error) {
+	var n, err = shardingInt(value)
+	if err != nil {
+		return 0, err
+	}
+	var index = sort.Search(len(it.Bounds), func(i int) bool {
+		return n < it.Bounds[i]
+	})
+	if index >= count {
+		return 0, utils.NewError("RangeSharding", "value "+strconv.FormatInt(n, 10)+" out of range!")
+	}
+	return index, nil
+}
+
+func shardingInt(value interface{}) (int64, error) {
+	var v = reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	case reflect.String:
+		var n, err = strconv.ParseInt(v.String(), 10, 64)
+		if err == nil {
+			return n, nil
+		}
+	}
+	return 0, utils.NewError("Sharding", "shard value "+fmt.Sprint(value)+" must be a integer!")
+}
+
+//逻辑表的分片规则。表序号 = Algorithm(分片键, TableCount)，
+//表按序号连续均分到各数据源，例如 4个数据源 × 16张表时，表 0~3 在 DataSources[0]，4~7 在 DataSources[1]
+type ShardingRule struct {
+	Table       string            //逻辑表名，例如 orders
+	ShardKey    string            //分片键的参数名，例如 userId
+	DataSources []string          //数据源名称，见 GoMybatisDataSourceRouter.RegisterDataSource
+	TableCount  int               //物理表总数，需为数据源数的整数倍
+	TableFormat string            //物理表名格式，参数为逻辑表名和表序号，默认 "%s_%d"
+	Algorithm   ShardingAlgorithm //默认 ModSharding
+}
+
+//一个分片：数据源和 逻辑表 -> 物理表
+type Shard struct {
+	DataSource string
+	Index      int
+	Tables     map[string]string
+
+	rules []*ShardingRule
+}
+
+//改写sql中引用逻辑表的表名为物理表名，列名、别名、字符串中的同名文本不改写，见 sqlTableRefs
+func (it Shard) Rewrite(sql string) string {
+	var tables = make(map[string]string, len(it.rules))
+	for _, rule := range it.rules {
+		tables[strings.ToLower(rule.Table)] = it.Tables[rule.Table]
+	}
+	var builder strings.Builder
+	var last = 0
+	for _, ref := range sqlTableRefs(sql) {
+		var table, ok = tables[strings.ToLower(sql[ref[0]:ref[1]])]
+		if !ok {
+			continue
+		}
+		builder.WriteString(sql[last:ref[0]])
+		builder.WriteString(table)
+		last = ref[1]
+	}
+	builder.WriteString(sql[last:])
+	return builder.String()
+}
+
+//表名之前的关键字
+var sqlTableKeywords = map[string]bool{"from": true, "join": true, "update": true, "into": true}
+
+//结束from列表（逗号分隔的多个表）的关键字
+var sqlFromListEndKeywords = map[string]bool{
+	"where": true, "on": true, "using": true, "set": true, "group": true, "order": true, "having": true,
+	"limit": true, "union": true, "intersect": true, "except": true, "values": true, "select": true, "window": true,
+}
+
+//sql中表名的位置 [start, end)：from、join、update、into之后，以及from列表中逗号之后的第一个标识符（引用的标识符为引号内的部分），
+//跳过字符串和注释。schema限定的表名（db.orders）只返回schema部分
+func sqlTableRefs(sql string) [][2]int {
+	var refs = [][2]int{}
+	var expectTable = false
+	var fromList = []bool{false} //各层括号是否在from列表中
+	for i := 0; i < len(sql); i++ {
+		var c = sql[i]
+		switch {
+		case c == '\'':
+			for i++; i < len(sql) && sql[i] != c; i++ {
+				if sql[i] == '\\' {
+					i++
+				}
+			}
+			expectTable = false
+		case c == '"' || c == '`':
+			var start = i + 1
+			for i++; i < len(sql) && sql[i] != c; i++ {
+			}
+			if expectTable && i < len(sql) {
+				refs = append(refs, [2]int{start, i})
+			}
+			expectTable = false
+		case strings.HasPrefix(sql[i:], "--"):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			var end = strings.Index(sql[i+2:], "*/")
+			if end == -1 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+		case c == '(':
+			fromList = append(fromList, false)
+			expectTable = false
+		case c == ')':
+			if len(fromList) > 1 {
+				fromList = fromList[:len(fromList)-1]
+			}
+			expectTable = false
+		case c == ',':
+			expectTable = fromList[len(fromList)-1]
+		case c == ';':
+			fromList[len(fromList)-1] = false
+			expectTable = false
+		case isSqlWordChar(c):
+			var start = i
+			for i+1 < len(sql) && isSqlWordChar(sql[i+1]) {
+				i++
+			}
+			if expectTable {
+				refs = append(refs, [2]int{start, i + 1})
+				expectTable = false
+				continue
+			}
+			var word = strings.ToLower(sql[start : i+1])
+			if sqlTableKeywords[word] {
+				expectTable = true
+				if word == "from" {
+					fromList[len(fromList)-1] = true
+				}
+			} else if sqlFromListEndKeywords[word] {
+				fromList[len(fromList)-1] = false
+			}
+		}
+	}
+	return refs
+}
+
+//分片数据源路由：语句中出现分片规则的逻辑表时，按分片键计算分片，改写表名并路由到分片的数据源；
+//参数中没有分片键时广播到全部分片，查询结果按分片顺序合并（切片追加、数值相加，排序和分页只在各分片内有效）。
+//一个语句中的多个分片表需使用相同的数据源和表数量（绑定表）。例如
+//
+//	var router = GoMybatis.ShardingDataSourceRouter{}.New()
+//	engine.SetDataSourceRouter(&router)
+//	engine.OpenDataSource("ds0", "mysql", uri0) //... ds3
+//	router.AddRule(GoMybatis.ShardingRule{Table: "orders", ShardKey: "userId", DataSources: []string{"ds0", "ds1", "ds2", "ds3"}, TableCount: 16})
+//
+//事务或指定session执行时，语句只能落在一个分片上（session需属于该分片的数据源）
+type ShardingDataSourceRouter struct {
+	GoMybatisDataSourceRouter
+
+	ruleMutex *sync.RWMutex
+	rules     map[string]*ShardingRule //小写逻辑表名 -> 规则
+}
+
+func (it ShardingDataSourceRouter) New() ShardingDataSourceRouter {
+	it.GoMybatisDataSourceRouter = GoMybatisDataSourceRouter{}.New(nil)
+	it.ruleMutex = &sync.RWMutex{}
+	it.rules = map[string]*ShardingRule{}
+	return it
+}
+
+//添加分片规则，同表覆盖
+func (it *ShardingDataSourceRouter) AddRule(rule ShardingRule) error {
+	if rule.Table == "" || rule.ShardKey == "" {
+		return utils.NewError("ShardingDataSourceRouter", "sharding rule Table and ShardKey can not be empty!")
+	}
+	if len(rule.DataSources) == 0 || rule.TableCount <= 0 || rule.TableCount%len(rule.DataSources) != 0 {
+		return utils.NewError("ShardingDataSourceRouter", "sharding rule '"+rule.Table+"' TableCount must be a multiple of DataSources num!")
+	}
+	if rule.TableFormat == "" {
+		rule.TableFormat = "%s_%d"
+	}
+	if rule.Algorithm == nil {
+		rule.Algorithm = ModSharding{}
+	}
+	it.ruleMutex.Lock()
+	defer it.ruleMutex.Unlock()
+	it.rules[strings.ToLower(rule.Table)] = &rule
+	return nil
+}
+
+func (it *ShardingDataSourceRouter) Name() string {
+	return "ShardingDataSourceRouter"
+}
+
+//构建sql使用的databaseId，各分片数据源需为同一种数据库，使用默认数据源的驱动
+func (it *ShardingDataSourceRouter) DatabaseId() string {
+	var name = it.DefaultDataSource()
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	return DatabaseIdOf(it.driverMap[name])
+}
+
+//计算sql涉及的分片，sql中没有分片表返回nil
+func (it *ShardingDataSourceRouter) Shards(sql string, paramMap map[string]interface{}) ([]Shard, error) {
+	var rules = it.matchRules(sql)
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	var first = rules[0]
+	for _, rule := range rules[1:] {
+		if rule.TableCount != first.TableCount || strings.Join(rule.DataSources, ",") != strings.Join(first.DataSources, ",") {
+			return nil, utils.NewError("ShardingDataSourceRouter", "sharding table '"+first.Table+"' and '"+rule.Table+"' in one statement must have same DataSources and TableCount!")
+		}
+	}
+	//各表分片键计算的序号取交集，都没有分片键时为全部分片
+	var indexes map[int]bool
+	for _, rule := range rules {
+		var values, ok = findShardValues(paramMap, rule.ShardKey)
+		if !ok {
+			continue
+		}
+		var ruleIndexes = map[int]bool{}
+		for _, value := range values {
+			var index, err = rule.Algorithm.Shard(value, rule.TableCount)
+			if err != nil {
+				return nil, err
+			}
+			if index < 0 || index >= rule.TableCount {
+				return nil, utils.NewError("ShardingDataSourceRouter", "sharding table '"+rule.Table+"' index "+strconv.Itoa(index)+" out of range!")
+			}
+			ruleIndexes[index] = true
+		}
+		if indexes == nil {
+			indexes = ruleIndexes
+			continue
+		}
+		for index := range indexes {
+			if !ruleIndexes[index] {
+				delete(indexes, index)
+			}
+		}
+	}
+	if indexes != nil && len(indexes) == 0 {
+		return nil, utils.NewError("ShardingDataSourceRouter", "sharding tables in one statement have no same shard!")
+	}
+	if indexes == nil {
+		if isInsertSql(sql) {
+			return nil, utils.NewError("ShardingDataSourceRouter", "insert into sharding table '"+first.Table+"' must have shard key '"+first.ShardKey+"'!")
+		}
+		indexes = map[int]bool{}
+		for i := 0; i < first.TableCount; i++ {
+			indexes[i] = true
+		}
+	}
+	var sorted = make([]int, 0, len(indexes))
+	for index := range indexes {
+		sorted = append(sorted, index)
+	}
+	sort.Ints(sorted)
+	var shards = make([]Shard, 0, len(sorted))
+	for _, index := range sorted {
+		var shard = Shard{
+			DataSource: first.DataSources[index*len(first.DataSources)/first.TableCount],
+			Index:      index,
+			Tables:     map[string]string{},
+			rules:      rules,
+		}
+		for _, rule := range rules {
+			shard.Tables[rule.Table] = fmt.Sprintf(rule.TableFormat, rule.Table, index)
+		}
+		shards = append(shards, shard)
+	}
+	return shards, nil
+}
+
+//分片的session
+func (it *ShardingDataSourceRouter) RouterShard(shard Shard, engine SessionEngine) (Session, error) {
+	return it.routerDataSource(shard.DataSource, engine)
+}
+
+//sql中出现的分片表规则，按表名排序
+func (it *ShardingDataSourceRouter) matchRules(sql string) []*ShardingRule {
+	it.ruleMutex.RLock()
+	defer it.ruleMutex.RUnlock()
+	var rules = []*ShardingRule{}
+	for _, ref := range sqlTableRefs(sql) {
+		var rule = it.rules[strings.ToLower(sql[ref[0]:ref[1]])]
+		if rule != nil && !containsRule(rules, rule) {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Table < rules[j].Table
+	})
+	return rules
+}
+
+func containsRule(rules []*ShardingRule, rule *ShardingRule) bool {
+	for _, item := range rules {
+		if item == rule {
+			return true
+		}
+	}
+	return false
+}
+
+//查找分片键的值，切片、数组（in 查询）返回全部元素
+func findShardValues(paramMap map[string]interface{}, key string) ([]interface{}, bool) {
+	var value interface{}
+	var ok bool
+	for _, name := range []string{key, utils.LowerFieldFirstName(key), utils.UpperFieldFirstName(key)} {
+		if value, ok = paramMap[name]; ok && value != nil {
+			break
+		}
+	}
+	if !ok || value == nil {
+		return nil, false
+	}
+	var v = reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		if v.Len() == 0 {
+			return nil, false
+		}
+		var values = make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = v.Index(i).Interface()
+		}
+		return values, true
+	}
+	return []interface{}{v.Interface()}, true
+}
+
+func isInsertSql(sql string) bool {
+	var fields = strings.Fields(sql)
+	return len(fields) > 0 && (strings.EqualFold(fields[0], "insert") || strings.EqualFold(fields[0], "replace"))
+}
+
+//合并分片的返回值：切片追加、map合并、数值相加，其余取第一个非零值
+func mergeShardResult(dst reflect.Value, src reflect.Value) {
+	var d, s = dst.Elem(), src.Elem()
+	switch d.Kind() {
+	case reflect.Slice:
+		d.Set(reflect.AppendSlice(d, s))
+	case reflect.Map:
+		if d.IsNil() {
+			d.Set(reflect.MakeMap(d.Type()))
+		}
+		for _, key := range s.MapKeys() {
+			d.SetMapIndex(key, s.MapIndex(key))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		d.SetInt(d.Int() + s.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		d.SetUint(d.Uint() + s.Uint())
+	case reflect.Float32, reflect.Float64:
+		d.SetFloat(d.Float() + s.Float())
+	default:
+		if isZeroValue(d) {
+			d.Set(s)
+		}
+	}
+}
+
+func isZeroValue(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+//分片路由时执行mapper方法：构建sql后按分片改写表名，单个分片直接执行，多个分片并发执行后合并返回值
+func exeShardingMethod(router ShardingRouter, elementType ElementType, sessionEngine SessionEngine, session Session, paramMap map[string]interface{}, nodes []ast.Node, resultMap map[string]*ResultProperty, returnValue *reflect.Value, request RouterRequest, tenant TenantMode) error {
+	if session == nil {
+		session = goroutineSession(sessionEngine)
+	}
+	if session != nil {
+		paramMap[ast.DatabaseIdVar] = sessionDatabaseId(session)
+	} else {
+		paramMap[ast.DatabaseIdVar] = router.DatabaseId()
+	}
+	sql, array_arg, err := buildStatementSql(sessionEngine, paramMap, nodes, tenant)
+	if err != nil {
+		return err
+	}
+	shards, err := router.Shards(sql, paramMap)
+	if err != nil {
+		return err
+	}
+	if len(shards) == 0 {
+		return exeSql(elementType, request, sessionEngine, session, sql, array_arg, resultMap, returnValue)
+	}
+	if session != nil {
+		if len(shards) != 1 {
+			return utils.NewError("ShardingDataSourceRouter", "statement in session or transaction must route to one shard, but got "+strconv.Itoa(len(shards))+" shards!")
+		}
+		if name := sessionDataSource(session); name != shards[0].DataSource {
+			return utils.NewError("ShardingDataSourceRouter", "session of datasource '"+name+"' can not execute statement of shard datasource '"+shards[0].DataSource+"'!")
+		}
+		return exeSql(elementType, request, sessionEngine, session, shards[0].Rewrite(sql), array_arg, resultMap, returnValue)
+	}
+	var exeShard = func(shard Shard, shardReturnValue *reflect.Value) error {
+		if err := checkAcceptSession(sessionEngine); err != nil {
+			return err
+		}
+		var shardSession, err = router.RouterShard(shard, sessionEngine)
+		if err != nil {
+			return err
+		}
+		defer shardSession.Close()
+		return exeSql(elementType, request, sessionEngine, shardSession, shard.Rewrite(sql), array_arg, resultMap, shardReturnValue)
+	}
+	if len(shards) == 1 {
+		return exeShard(shards[0], returnValue)
+	}
+	var haveReturnValue = returnValue != nil && !(*returnValue).IsNil()
+	var results = make([]*reflect.Value, len(shards))
+	var errs = make([]error, len(shards))
+	var wait = sync.WaitGroup{}
+	for i, shard := range shards {
+		if haveReturnValue {
+			var result = reflect.New(returnValue.Elem().Type())
+			switch result.Elem().Kind() {
+			case reflect.Map:
+				result.Elem().Set(reflect.MakeMap(result.Elem().Type()))
+			case reflect.Slice:
+				result.Elem().Set(reflect.MakeSlice(result.Elem().Type(), 0, 0))
+			}
+			results[i] = &result
+		}
+		wait.Add(1)
+		go func(i int, shard Shard) {
+			defer wait.Done()
+			errs[i] = exeShard(shard, results[i])
+		}(i, shard)
+	}
+	wait.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	if haveReturnValue {
+		for _, result := range results {
+			mergeShardResult(*returnValue, *result)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package GoMybatis
+
+import (
+	"database/sql/driver"
+	"sort"
+	"testing"
+)
+
+type ShardingTestMapper struct {
+	SelectByUser    func(userId int64) ([]map[string]string, error)   `mapperParams:"userId"`
+	SelectByUsers   func(userId []int64) ([]map[string]string, error) `mapperParams:"userId"`
+	SelectAll       func() ([]map[string]string, error)
+	UpdateAll       func(status int) (int64, error) `mapperParams:"status"`
+	InsertOrder     func(id string) (int64, error)  `mapperParams:"id"`
+	SelectConfig    func() ([]map[string]string, error)
+	SelectInSession func(session *Session, userId int64) ([]map[string]string, error) `mapperParams:"session,userId"`
+	NewSession      func() (Session, error)
+	SelectLogConfig func() ([]map[string]string, error) `datasource:"ds1"`
+}
+
+func newShardingTestMapper(t *testing.T) ShardingTestMapper {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var router = ShardingDataSourceRouter{}.New()
+	engine.SetDataSourceRouter(&router)
+	for _, name := range []string{"ds0", "ds1"} {
+		if _, err := engine.OpenDataSource(name, TestDriverName, "TestSharding_"+name); err != nil {
+			t.Fatal(err)
+		}
+		resetTestDriverState("TestSharding_" + name)
+		var state = testDriverState("TestSharding_" + name)
+		state.lock(func() {
+			state.Columns = []string{"id"}
+			state.Rows = [][]driver.Value{{name}}
+		})
+	}
+	if err := router.AddRule(ShardingRule{Table: "orders", ShardKey: "userId", DataSources: []string{"ds0", "ds1"}, TableCount: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.AddRule(ShardingRule{Table: "orders", ShardKey: "userId", DataSources: []string{"ds0", "ds1"}, TableCount: 3}); err == nil {
+		t.Fatal("TableCount not multiple of DataSources must fail")
+	}
+	var mapper ShardingTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectByUser">select * from orders where user_id = #{userId}</select>
+    <select id="selectByUsers">select * from orders where user_id in <foreach collection="userId" item="id" open="(" close=")" separator=",">#{id}</foreach></select>
+    <select id="selectAll">select * from orders o where o.status = 1</select>
+    <update id="updateAll">update orders set status = #{status}</update>
+    <insert id="insertOrder">insert into orders (id) values (#{id})</insert>
+    <select id="selectConfig">select * from orders_config</select>
+    <select id="selectLogConfig">select * from orders_config</select>
+    <select id="selectInSession">select * from orders where user_id = #{userId}</select>
+</mapper>`))
+	return mapper
+}
+
+func resetShardingTestState() (*TestDriverState, *TestDriverState) {
+	var ds0, ds1 = testDriverState("TestSharding_ds0"), testDriverState("TestSharding_ds1")
+	ds0.lock(func() { ds0.Queries, ds0.Execs = nil, nil })
+	ds1.lock(func() { ds1.Queries, ds1.Execs = nil, nil })
+	return ds0, ds1
+}
+
+func TestShardingDataSourceRouter(t *testing.T) {
+	var mapper = newShardingTestMapper(t)
+
+	//分片键路由到单个分片
+	var ds0, ds1 = resetShardingTestState()
+	var result, err = mapper.SelectByUser(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds0.Queries) != 0 || len(ds1.Queries) != 1 || compactSql(ds1.Queries[0]) != compactSql("select * from orders_2 where user_id = ?") || len(result) != 1 {
+		t.Fatal("shard key route fail:", ds0.Queries, ds1.Queries, result)
+	}
+
+	//in 查询路由到多个分片
+	ds0, ds1 = resetShardingTestState()
+	result, err = mapper.SelectByUsers([]int64{1, 5, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds0.Queries) != 1 || len(ds1.Queries) != 1 || len(result) != 2 {
+		t.Fatal("shard key in route fail:", ds0.Queries, ds1.Queries, result)
+	}
+
+	//没有分片键时广播并按分片顺序合并
+	ds0, ds1 = resetShardingTestState()
+	result, err = mapper.SelectAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds0.Queries) != 2 || len(ds1.Queries) != 2 || len(result) != 4 || result[0]["id"] != "ds0" || result[3]["id"] != "ds1" {
+		t.Fatal("broadcast select fail:", ds0.Queries, ds1.Queries, result)
+	}
+	var rows, _ = mapper.UpdateAll(1)
+	//各分片并发执行，执行顺序不固定
+	var execs = []string{}
+	for _, exec := range ds0.Execs {
+		execs = append(execs, compactSql(exec))
+	}
+	sort.Strings(execs)
+	if rows != 4 || len(execs) != 2 || execs[0] != compactSql("update orders_0 set status = ?") || execs[1] != compactSql("update orders_1 set status = ?") {
+		t.Fatal("broadcast update fail:", rows, ds0.Execs)
+	}
+
+	//插入需要分片键
+	if _, err := mapper.InsertOrder("1"); err == nil {
+		t.Fatal("insert without shard key must fail")
+	}
+
+	//非分片表使用默认数据源
+	ds0, ds1 = resetShardingTestState()
+	if _, err := mapper.SelectConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ds0.Queries) != 1 || compactSql(ds0.Queries[0]) != compactSql("select * from orders_config") {
+		t.Fatal("not sharding table fail:", ds0.Queries)
+	}
+
+	//非分片表使用tag指定的数据源
+	ds0, ds1 = resetShardingTestState()
+	if _, err := mapper.SelectLogConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ds0.Queries) != 0 || len(ds1.Queries) != 1 {
+		t.Fatal("not sharding table datasource tag fail:", ds0.Queries, ds1.Queries)
+	}
+
+	//session（默认数据源ds0）只能执行该数据源上的分片
+	session, err := mapper.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	ds0, ds1 = resetShardingTestState()
+	if _, err := mapper.SelectInSession(&session, 4); err != nil {
+		t.Fatal(err)
+	}
+	if len(ds0.Queries) != 1 || compactSql(ds0.Queries[0]) != compactSql("select * from orders_0 where user_id = ?") {
+		t.Fatal("session shard fail:", ds0.Queries)
+	}
+	if _, err := mapper.SelectInSession(&session, 6); err == nil {
+		t.Fatal("shard of other datasource in session must fail")
+	}
+	if len(ds1.Queries) != 0 {
+		t.Fatal("session must not execute on other datasource:", ds1.Queries)
+	}
+}
+
+func TestShardingAlgorithm(t *testing.T) {
+	var rangeSharding = RangeSharding{Bounds: []int64{1000, 2000}}
+	for value, expect := range map[int64]int{0: 0, 999: 0, 1000: 1, 2500: 2} {
+		if index, err := rangeSharding.Shard(value, 3); err != nil || index != expect {
+			t.Fatal("range sharding fail:", value, index, err)
+		}
+	}
+	if _, err := rangeSharding.Shard(2500, 2); err == nil {
+		t.Fatal("range out of count must fail")
+	}
+	if index, _ := (ModSharding{}).Shard("-5", 4); index != 3 {
+		t.Fatal("mod sharding fail:", index)
+	}
+	if _, err := (ModSharding{}).Shard("a", 4); err == nil {
+		t.Fatal("mod sharding not integer must fail")
+	}
+	var a, _ = HashSharding{}.Shard("user-1", 16)
+	var b, _ = HashSharding{}.Shard("user-1", 16)
+	if a != b || a < 0 || a >= 16 {
+		t.Fatal("hash sharding fail:", a, b)
+	}
+	var custom = ShardingFunc(func(value interface{}, count int) (int, error) {
+		return len(value.(string)) % count, nil
+	})
+	if index, _ := custom.Shard("abc", 2); index != 1 {
+		t.Fatal("custom sharding fail:", index)
+	}
+}
+
+func TestShardRewrite(t *testing.T) {
+	var shard = Shard{Tables: map[string]string{"order": "order_3"}, rules: []*ShardingRule{{Table: "order"}}}
+	var cases = map[string]string{
+		"select `order`.id, o.order from `order` o where o.name = 'from order' order by o.id": "select `order`.id, o.order from `order_3` o where o.name = 'from order' order by o.id",
+		"select * from orders o, ORDER x /* from order */ where x.id = o.id":                  "select * from orders o, order_3 x /* from order */ where x.id = o.id",
+		"select * from (select id from order where id = ?) t, order_items i order by t.id":    "select * from (select id from order_3 where id = ?) t, order_items i order by t.id",
+		"insert into order (id, order_no) values (?, 'order')":                                "insert into order_3 (id, order_no) values (?, 'order')",
+		"update order o join items i on i.id = o.id set o.order = 1":                          "update order_3 o join items i on i.id = o.id set o.order = 1",
+	}
+	for sql, expect := range cases {
+		if rewrite := shard.Rewrite(sql); rewrite != expect {
+			t.Fatal("rewrite fail:", sql, "=>", rewrite)
+		}
+	}
+
+	//只在列名、排序中出现的同名文本不是分片表
+	var router = ShardingDataSourceRouter{}.New()
+	if err := router.AddRule(ShardingRule{Table: "order", ShardKey: "userId", DataSources: []string{"ds0"}, TableCount: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if shards, err := router.Shards("select o.order from orders o order by o.id", map[string]interface{}{}); err != nil || shards != nil {
+		t.Fatal("not sharding table must return nil:", shards, err)
+	}
+	if shards, err := router.Shards("select * from `order` where user_id = ?", map[string]interface{}{"userId": 6}); err != nil || len(shards) != 1 || shards[0].Index != 2 {
+		t.Fatal("quoted sharding table fail:", shards, err)
+	}
+}
@@ -0,0 +1,159 @@
+package GoMybatis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//一条sql的执行记录
+type SqlEvent struct {
+	Time        time.Time     //开始执行时间
+	StatementId string        //mapper xml中语句的id，QueryBuilder等非mapper语句为""
+	Mapper      string        //mapper包名+名称
+	SessionId   string        //执行的session
+	TxId        string        //在事务中执行时为事务所在session的id，否则为""
+	Sql         string        //最终执行的sql
+	Args        []interface{} //sql参数
+	Query       bool          //查询语句，Rows为返回行数，否则为影响行数
+	Rows        int64         //返回行数或影响行数
+	Elapsed     time.Duration //执行耗时（查询包含结果解码）
+	Err         error         //执行错误
+	Slow        bool          //耗时达到慢sql阈值，见 GoMybatisEngine.SetSlowSqlThreshold
+}
+
+//结构化sql日志，每条语句执行后调用一次（引擎 LogEnable() 为true时；慢sql总是调用），见 GoMybatisEngine.SetSqlLogger
+type SqlLogger interface {
+	LogSql(event SqlEvent)
+}
+
+//支持结构化sql日志的引擎（GoMybatisEngine），SessionEngine 的可选扩展
+type sqlLoggerEngine interface {
+	SqlLogger() SqlLogger
+	SlowSqlThreshold() time.Duration
+}
+
+//引擎的sql日志，引擎不支持时通过引擎的 LogSystem 输出文本日志
+func engineSqlLogger(sessionEngine SessionEngine) SqlLogger {
+	if engine, ok := sessionEngine.(sqlLoggerEngine); ok {
+		return engine.SqlLogger()
+	}
+	return logSystemSqlLogger{logSystem: sessionEngine.LogSystem()}
+}
+
+//引擎的慢sql阈值，引擎不支持时为0（不标记）
+func engineSlowSqlThreshold(sessionEngine SessionEngine) time.Duration {
+	if engine, ok := sessionEngine.(sqlLoggerEngine); ok {
+		return engine.SlowSqlThreshold()
+	}
+	return 0
+}
+
+//函数适配 SqlLogger
+type SqlLoggerFunc func(event SqlEvent)
+
+func (it SqlLoggerFunc) LogSql(event SqlEvent) {
+	it(event)
+}
+
+//未设置 SqlLogger 时的默认日志，通过 LogSystem 异步输出文本日志
+type logSystemSqlLogger struct {
+	logSystem *LogSystem
+}
+
+func (it logSystemSqlLogger) LogSql(event SqlEvent) {
+	if it.logSystem == nil {
+		return
+	}
+	var prefix = "[GoMybatis] [" + event.SessionId + "] "
+	if event.Query {
+		it.logSystem.SendLog(prefix, "Query ==> "+event.Sql)
+		it.logSystem.SendLog(prefix, "Args  ==> "+utils.SprintArray(event.Args))
+		it.logSystem.SendLog(prefix, "ReturnRows <== "+strconv.FormatInt(event.Rows, 10))
+	} else {
+		it.logSystem.SendLog(prefix, "Exec ==> "+event.Sql)
+		it.logSystem.SendLog(prefix, "Args ==> "+utils.SprintArray(event.Args))
+		it.logSystem.SendLog(prefix, "RowsAffected <== "+strconv.FormatInt(event.Rows, 10))
+	}
+	if event.Err != nil {
+		it.logSystem.SendLog(prefix, "error == "+event.Err.Error())
+	}
+	if event.Slow {
+		it.logSystem.SendLog(prefix, "Slow sql, elapsed "+event.Elapsed.String())
+	}
+}
+
+//按行输出json的sql日志（JSON Lines），每个事件一行，例如
+//
+//	{"time":"2006-01-02T15:04:05Z","statementId":"selectById","mapper":"example.ActivityMapper","sessionId":"...","sql":"select * from biz_activity where id = ?","args":["1"],"query":true,"rows":1,"elapsedMs":0.52}
+type JsonLinesSqlLogger struct {
+	mutex  *sync.Mutex
+	writer io.Writer
+}
+
+func (it JsonLinesSqlLogger) New(writer io.Writer) JsonLinesSqlLogger {
+	if writer == nil {
+		panic("[GoMybatis] JsonLinesSqlLogger writer can not be nil!")
+	}
+	it.mutex = &sync.Mutex{}
+	it.writer = writer
+	return it
+}
+
+type jsonSqlEvent struct {
+	Time        time.Time     `json:"time"`
+	StatementId string        `json:"statementId,omitempty"`
+	Mapper      string        `json:"mapper,omitempty"`
+	SessionId   string        `json:"sessionId,omitempty"`
+	TxId        string        `json:"txId,omitempty"`
+	Sql         string        `json:"sql"`
+	Args        []interface{} `json:"args"`
+	Query       bool          `json:"query"`
+	Rows        int64         `json:"rows"`
+	ElapsedMs   float64       `json:"elapsedMs"`
+	Error       string        `json:"error,omitempty"`
+	Slow        bool          `json:"slow,omitempty"`
+}
+
+func (it *JsonLinesSqlLogger) LogSql(event SqlEvent) {
+	var item = jsonSqlEvent{
+		Time:        event.Time,
+		StatementId: event.StatementId,
+		Mapper:      event.Mapper,
+		SessionId:   event.SessionId,
+		TxId:        event.TxId,
+		Sql:         event.Sql,
+		Args:        event.Args,
+		Query:       event.Query,
+		Rows:        event.Rows,
+		ElapsedMs:   float64(event.Elapsed) / float64(time.Millisecond),
+		Slow:        event.Slow,
+	}
+	if item.Args == nil {
+		item.Args = []interface{}{}
+	}
+	if event.Err != nil {
+		item.Error = event.Err.Error()
+	}
+	var data, err = json.Marshal(item)
+	if err != nil {
+		//参数无法序列化时按文本输出
+		var args = make([]interface{}, len(event.Args))
+		for i, arg := range event.Args {
+			args[i] = fmt.Sprint(arg)
+		}
+		item.Args = args
+		data, err = json.Marshal(item)
+		if err != nil {
+			return
+		}
+	}
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.writer.Write(append(data, '\n'))
+}
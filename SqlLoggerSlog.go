@@ -0,0 +1,53 @@
+//go:build go1.21
+// +build go1.21
+
+package GoMybatis
+
+import (
+	"context"
+	"log/slog"
+)
+
+//log/slog 适配的sql日志（需要go1.21），执行错误为Error级别，慢sql为Warn级别，其余为Info级别
+type SlogSqlLogger struct {
+	logger *slog.Logger
+}
+
+//logger为nil时使用 slog.Default()
+func (it SlogSqlLogger) New(logger *slog.Logger) SlogSqlLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	it.logger = logger
+	return it
+}
+
+func (it *SlogSqlLogger) LogSql(event SqlEvent) {
+	var level = slog.LevelInfo
+	switch {
+	case event.Err != nil:
+		level = slog.LevelError
+	case event.Slow:
+		level = slog.LevelWarn
+	}
+	var ctx = context.Background()
+	if !it.logger.Enabled(ctx, level) {
+		return
+	}
+	var attrs = []slog.Attr{
+		slog.String("statementId", event.StatementId),
+		slog.String("mapper", event.Mapper),
+		slog.String("sessionId", event.SessionId),
+		slog.String("txId", event.TxId),
+		slog.String("sql", event.Sql),
+		slog.Any("args", event.Args),
+		slog.Bool("query", event.Query),
+		slog.Int64("rows", event.Rows),
+		slog.Duration("elapsed", event.Elapsed),
+		slog.Bool("slow", event.Slow),
+	}
+	if event.Err != nil {
+		attrs = append(attrs, slog.String("error", event.Err.Error()))
+	}
+	it.logger.LogAttrs(ctx, level, "[GoMybatis] sql", attrs...)
+}
@@ -0,0 +1,25 @@
+//go:build go1.21
+// +build go1.21
+
+package GoMybatis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogSqlLogger(t *testing.T) {
+	var buf bytes.Buffer
+	var logger = SlogSqlLogger{}.New(slog.New(slog.NewJSONHandler(&buf, nil)))
+	logger.LogSql(SqlEvent{StatementId: "selectById", Sql: "select 1", Rows: 2, Err: errors.New("fail")})
+	var item map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &item); err != nil {
+		t.Fatal(err)
+	}
+	if item["level"] != "ERROR" || item["statementId"] != "selectById" || item["rows"] != 2.0 || item["error"] != "fail" {
+		t.Fatal("slog sql logger fail:", buf.String())
+	}
+}
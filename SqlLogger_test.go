@@ -0,0 +1,98 @@
+package GoMybatis
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhuxiujia/GoMybatis/tx"
+)
+
+type SqlLoggerTestMapper struct {
+	SelectById func(id int) ([]map[string]string, error)          `mapperParams:"id"`
+	UpdateName func(session *Session, name string) (int64, error) `mapperParams:"session,name"`
+}
+
+func TestSqlLogger_Event(t *testing.T) {
+	resetTestDriverState("TestSqlLogger")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	engine.Open(TestDriverName, "TestSqlLogger")
+	var state = testDriverState("TestSqlLogger")
+	state.Columns = []string{"id"}
+	state.Rows = [][]driver.Value{{"1"}}
+	var events = []SqlEvent{}
+	engine.SetSqlLogger(SqlLoggerFunc(func(event SqlEvent) {
+		events = append(events, event)
+	}))
+	var mapper SqlLoggerTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectById">select * from biz_activity where id = #{id}</select>
+    <update id="updateName">update biz_activity set name = #{name}</update>
+</mapper>`))
+
+	//关闭日志时不输出
+	mapper.SelectById(1)
+	if len(events) != 0 {
+		t.Fatal("log disable must not log")
+	}
+	//关闭日志时慢sql仍然输出
+	engine.SetSlowSqlThreshold(time.Nanosecond)
+	mapper.SelectById(1)
+	if len(events) != 1 || !events[0].Slow {
+		t.Fatal("slow sql must log when log disable:", events)
+	}
+	events = events[:0]
+	engine.SetLogEnable(true)
+	mapper.SelectById(1)
+	var event = events[0]
+	if event.StatementId != "selectById" || !strings.HasSuffix(event.Mapper, "SqlLoggerTestMapper") || event.SessionId == "" || event.TxId != "" ||
+		compactSql(event.Sql) != compactSql("select * from biz_activity where id = ?") || len(event.Args) != 1 || event.Args[0] != 1 ||
+		!event.Query || event.Rows != 1 || event.Elapsed <= 0 || !event.Slow || event.Err != nil {
+		t.Fatal("select event fail:", event)
+	}
+
+	//事务中的语句、执行错误
+	var session, err = engine.SessionFactory().NewSession("", SessionType_Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	var propagation = tx.PROPAGATION_REQUIRED
+	session.Begin(&propagation)
+	engine.SetSlowSqlThreshold(0)
+	state.lock(func() { state.ExecErr = errors.New("exec fail") })
+	defer state.lock(func() { state.ExecErr = nil })
+	mapper.UpdateName(&session, "a")
+	event = events[1]
+	if event.StatementId != "updateName" || event.TxId != session.Id() || event.Query || event.Err == nil || event.Slow {
+		t.Fatal("exec event fail:", event)
+	}
+}
+
+func TestJsonLinesSqlLogger(t *testing.T) {
+	var buf bytes.Buffer
+	var logger = JsonLinesSqlLogger{}.New(&buf)
+	logger.LogSql(SqlEvent{StatementId: "selectById", Sql: "select 1", Args: []interface{}{1, "a"}, Query: true, Rows: 1, Elapsed: 1500 * time.Microsecond, Err: errors.New("fail")})
+	logger.LogSql(SqlEvent{Sql: "update t set a = ?", Args: []interface{}{make(chan int)}})
+	var lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatal("json lines fail:", buf.String())
+	}
+	var item map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &item); err != nil {
+		t.Fatal(err)
+	}
+	if item["statementId"] != "selectById" || item["elapsedMs"] != 1.5 || item["error"] != "fail" || len(item["args"].([]interface{})) != 2 {
+		t.Fatal("json line fail:", lines[0])
+	}
+	//参数无法序列化时按文本输出
+	if err := json.Unmarshal([]byte(lines[1]), &item); err != nil || !strings.HasPrefix(item["args"].([]interface{})[0].(string), "0x") {
+		t.Fatal("json line args fallback fail:", lines[1])
+	}
+}
@@ -0,0 +1,109 @@
+package GoMybatis
+
+import (
+	"container/list"
+	"database/sql"
+)
+
+//默认预编译语句缓存容量
+const DefaultStmtCacheSize = 64
+
+//可设置预编译语句缓存容量的引擎（GoMybatisEngine），SessionEngine 的可选扩展
+type stmtCacheEngine interface {
+	StmtCacheSize() int
+}
+
+//引擎的预编译语句缓存容量，引擎不支持时为 DefaultStmtCacheSize
+func engineStmtCacheSize(sessionEngine SessionEngine) int {
+	if engine, ok := sessionEngine.(stmtCacheEngine); ok {
+		return engine.StmtCacheSize()
+	}
+	return DefaultStmtCacheSize
+}
+
+//预编译语句缓存统计
+type StmtCacheStats struct {
+	Hits      int64 //命中次数
+	Misses    int64 //未命中次数
+	Evictions int64 //淘汰（关闭）次数
+	Size      int   //当前缓存的语句数量
+}
+
+//预编译语句LRU缓存，按sql文本缓存，淘汰或清空时关闭语句。非并发安全，与session一样只在单个协程中使用
+type StmtCache struct {
+	capacity int
+	list     *list.List               //最近使用的在前
+	items    map[string]*list.Element //sql文本 -> *stmtCacheItem
+	stats    StmtCacheStats
+}
+
+type stmtCacheItem struct {
+	sql  string
+	stmt *sql.Stmt
+}
+
+//capacity:缓存容量，<=0 则不缓存
+func (it StmtCache) New(capacity int) StmtCache {
+	it.capacity = capacity
+	it.list = list.New()
+	it.items = make(map[string]*list.Element)
+	return it
+}
+
+//获取缓存的预编译语句，不存在返回nil
+func (it *StmtCache) Get(sqlPrepare string) *sql.Stmt {
+	var element = it.items[sqlPrepare]
+	if element == nil {
+		it.stats.Misses++
+		return nil
+	}
+	it.stats.Hits++
+	it.list.MoveToFront(element)
+	return element.Value.(*stmtCacheItem).stmt
+}
+
+//加入缓存，超过容量时关闭最久未使用的语句。返回false表示未缓存（容量<=0），调用方需自行关闭stmt
+func (it *StmtCache) Put(sqlPrepare string, stmt *sql.Stmt) bool {
+	if it.capacity <= 0 {
+		return false
+	}
+	var element = it.items[sqlPrepare]
+	if element != nil {
+		var item = element.Value.(*stmtCacheItem)
+		if item.stmt != stmt {
+			item.stmt.Close()
+			item.stmt = stmt
+		}
+		it.list.MoveToFront(element)
+		return true
+	}
+	it.items[sqlPrepare] = it.list.PushFront(&stmtCacheItem{sql: sqlPrepare, stmt: stmt})
+	for it.list.Len() > it.capacity {
+		var last = it.list.Back()
+		var item = last.Value.(*stmtCacheItem)
+		it.list.Remove(last)
+		delete(it.items, item.sql)
+		item.stmt.Close()
+		it.stats.Evictions++
+	}
+	return true
+}
+
+//关闭并清空全部语句（统计数据保留）
+func (it *StmtCache) Clear() {
+	for element := it.list.Front(); element != nil; element = element.Next() {
+		element.Value.(*stmtCacheItem).stmt.Close()
+	}
+	it.list.Init()
+	it.items = make(map[string]*list.Element)
+}
+
+func (it *StmtCache) Len() int {
+	return it.list.Len()
+}
+
+func (it *StmtCache) Stats() StmtCacheStats {
+	var stats = it.stats
+	stats.Size = it.list.Len()
+	return stats
+}
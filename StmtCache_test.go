@@ -0,0 +1,83 @@
+package GoMybatis
+
+import (
+	"testing"
+
+	"github.com/zhuxiujia/GoMybatis/tx"
+)
+
+func TestLocalSession_StmtCache(t *testing.T) {
+	resetTestDriverState("TestLocalSession_StmtCache")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	engine.SetStmtCacheSize(2)
+	if _, err := engine.Open(TestDriverName, "TestLocalSession_StmtCache"); err != nil {
+		t.Fatal(err)
+	}
+	var state = testDriverState("TestLocalSession_StmtCache")
+	var session, err = engine.NewSession("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var local = session.(*LocalSession)
+	for i := 0; i < 3; i++ {
+		if _, err = session.ExecPrepare("update biz_activity set name = ? where id = ?", "name", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stats = local.StmtCacheStats()
+	if state.Prepares != 1 || stats.Hits != 2 || stats.Misses != 1 {
+		t.Fatal("stmt must be cached,prepares =", state.Prepares, "stats =", stats)
+	}
+
+	//超过容量淘汰最久未使用的语句
+	session.ExecPrepare("delete from biz_activity where id = ?", 1)
+	session.QueryPrepare("select * from biz_activity where id = ?", 1)
+	stats = local.StmtCacheStats()
+	if stats.Evictions != 1 || stats.Size != 2 || state.ClosedStmts != 1 {
+		t.Fatal("lru eviction fail,stats =", stats, "closed =", state.ClosedStmts)
+	}
+
+	//事务中的语句绑定事务，事务结束时关闭
+	var p = tx.PROPAGATION_REQUIRED
+	if err = session.Begin(&p); err != nil {
+		t.Fatal(err)
+	}
+	session.ExecPrepare("delete from biz_activity where id = ?", 2)
+	session.ExecPrepare("delete from biz_activity where id = ?", 3)
+	if local.txStmtCache.Len() != 1 {
+		t.Fatal("tx stmt must be cached,len =", local.txStmtCache.Len())
+	}
+	if err = session.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if local.txStmtCache.Len() != 0 || state.ClosedStmts != 2 {
+		t.Fatal("tx stmt must be closed after commit,closed =", state.ClosedStmts)
+	}
+
+	session.Close()
+	if state.ClosedStmts != state.Prepares {
+		t.Fatal("stmt must be closed with session,prepares =", state.Prepares, "closed =", state.ClosedStmts)
+	}
+}
+
+func TestLocalSession_StmtCacheDisable(t *testing.T) {
+	resetTestDriverState("TestLocalSession_StmtCacheDisable")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	engine.SetStmtCacheSize(0)
+	if _, err := engine.Open(TestDriverName, "TestLocalSession_StmtCacheDisable"); err != nil {
+		t.Fatal(err)
+	}
+	var state = testDriverState("TestLocalSession_StmtCacheDisable")
+	var session, _ = engine.NewSession("")
+	defer session.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := session.ExecPrepare("delete from biz_activity where id = ?", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if state.Prepares != 2 || state.ClosedStmts != 2 {
+		t.Fatal("uncached stmt must be closed after use,prepares =", state.Prepares, "closed =", state.ClosedStmts)
+	}
+}
@@ -0,0 +1,272 @@
+package GoMybatis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//租户值在sql构建参数中的名称，手写语句可直接使用 #{_tenantId}
+const TenantVar = "_tenantId"
+
+//语句属性：ignoreTenant="true" 不过滤租户；tenant="true" 表示语句已包含租户条件（手写 #{_tenantId}），只绑定租户值
+const (
+	TenantIgnoreAttr = "ignoreTenant"
+	TenantAttr       = "tenant"
+)
+
+//语句的租户处理方式
+type TenantMode = int
+
+const (
+	TenantMode_None    TenantMode = iota //不处理
+	TenantMode_Bind                      //语句已包含租户条件（模板生成或手写），只绑定租户值
+	TenantMode_Rewrite                   //改写手写的select，追加租户条件
+)
+
+//租户值提供者，ctx为方法参数中的context.Context（没有时为context.Background()）
+type TenantProvider func(ctx context.Context) (interface{}, error)
+
+//多租户行过滤，每个引擎一个，见 GoMybatisEngine.TenantFilter()，需在 WriteMapperPtr 之前启用。例如
+//
+//	engine.TenantFilter().Enable("tenant_id", func(ctx context.Context) (interface{}, error) {
+//		return ctx.Value("tenant"), nil
+//	})
+//
+//启用后 selectTemplete、updateTemplete、deleteTemplete 追加条件 tenant_id = #{_tenantId}，
+//insertTemplete、upsertTemplete 写入租户列；SetRewriteSelect(true) 时手写的 <select> 在执行期改写追加租户条件
+//（多表连接时列名可能有歧义，应使用 ignoreTenant="true" 或 tenant="true" 手写条件）。
+//租户值为nil或获取失败时语句返回错误，不会执行不带租户条件的sql
+type TenantFilter struct {
+	mutex         *sync.RWMutex
+	column        string
+	provider      TenantProvider
+	rewriteSelect bool
+}
+
+//支持多租户行过滤的引擎（GoMybatisEngine），SessionEngine 的可选扩展
+type tenantFilterEngine interface {
+	TenantFilter() *TenantFilter
+}
+
+//引擎的多租户行过滤，引擎不支持时返回nil（不过滤）
+func engineTenantFilter(sessionEngine SessionEngine) *TenantFilter {
+	if engine, ok := sessionEngine.(tenantFilterEngine); ok {
+		return engine.TenantFilter()
+	}
+	return nil
+}
+
+func (it TenantFilter) New() TenantFilter {
+	it.mutex = &sync.RWMutex{}
+	return it
+}
+
+//启用租户过滤，column为租户列名，provider提供当前租户值
+func (it *TenantFilter) Enable(column string, provider TenantProvider) {
+	if column == "" || provider == nil {
+		panic("[GoMybatis] TenantFilter.Enable() column and provider can not be empty!")
+	}
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.column = column
+	it.provider = provider
+}
+
+//租户列名，未启用返回""
+func (it *TenantFilter) Column() string {
+	if it == nil {
+		return ""
+	}
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	return it.column
+}
+
+//是否改写手写的 <select>（默认不改写）
+func (it *TenantFilter) SetRewriteSelect(enable bool) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.rewriteSelect = enable
+}
+
+func (it *TenantFilter) RewriteSelect() bool {
+	it.mutex.RLock()
+	defer it.mutex.RUnlock()
+	return it.rewriteSelect
+}
+
+//当前租户值
+func (it *TenantFilter) TenantId(ctx context.Context) (interface{}, error) {
+	if it == nil {
+		return nil, utils.NewError("TenantFilter", "tenant filter not enable!")
+	}
+	it.mutex.RLock()
+	var provider = it.provider
+	it.mutex.RUnlock()
+	if provider == nil {
+		return nil, utils.NewError("TenantFilter", "tenant filter not enable!")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var tenantId, err = provider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenantId == nil {
+		return nil, utils.NewError("TenantFilter", "tenant id can not be nil!")
+	}
+	return tenantId, nil
+}
+
+//语句的租户列，未启用或 ignoreTenant="true" 返回""
+func (it *TenantFilter) statementColumn(element *etree.Element) string {
+	if it == nil || element.SelectAttrValue(TenantIgnoreAttr, "") == "true" {
+		return ""
+	}
+	return it.Column()
+}
+
+//构建期确定语句的租户处理方式
+func (it *TenantFilter) statementMode(element *etree.Element) TenantMode {
+	if it.statementColumn(element) == "" {
+		return TenantMode_None
+	}
+	if element.SelectAttrValue(TenantAttr, "") == "true" {
+		return TenantMode_Bind
+	}
+	if element.Tag == Element_Select && it.RewriteSelect() {
+		return TenantMode_Rewrite
+	}
+	return TenantMode_None
+}
+
+//为模板生成的每个 <where> 追加租户条件，没有 <where> 时追加一个
+func appendTenantWheres(mapper *etree.Element, column string) {
+	if len(mapper.Child) == 0 {
+		return
+	}
+	var condition = " and " + column + " = #{" + TenantVar + "}"
+	var find = false
+	var walk func(element *etree.Element)
+	walk = func(element *etree.Element) {
+		for _, child := range element.ChildElements() {
+			if child.Tag == Element_where {
+				child.Child = append(child.Child, &etree.CharData{Data: condition})
+				find = true
+				continue
+			}
+			walk(child)
+		}
+	}
+	walk(mapper)
+	if !find {
+		mapper.Child = append(mapper.Child, &etree.Element{
+			Tag:   Element_where,
+			Attr:  []etree.Attr{},
+			Child: []etree.Token{&etree.CharData{Data: condition}},
+		})
+	}
+}
+
+//select顶层子句关键字，where条件到这些关键字为止
+var tenantClauseKeywords = map[string]bool{
+	"group": true, "order": true, "limit": true, "having": true, "offset": true,
+	"fetch": true, "for": true, "window": true, "lock": true,
+}
+
+//改写已构建的select，在顶层where追加租户条件（原条件加括号），没有where时添加，args中对应位置插入租户值
+func rewriteTenantSelect(sql string, column string, args []interface{}, tenantId interface{}) (string, []interface{}, error) {
+	var depth = 0
+	var placeholders = 0
+	var first = ""
+	var seenFrom = false
+	var wherePos, whereArgs = -1, 0
+	var endPos, endArgs = len(sql), -1
+	for i := 0; i < len(sql); i++ {
+		var c = sql[i]
+		switch {
+		case strings.HasPrefix(sql[i:], ast.SQLPlaceholder):
+			placeholders++
+			i += len(ast.SQLPlaceholder) - 1
+		case c == '\'' || c == '"' || c == '`':
+			//跳过字符串、引用的标识符
+			for i++; i < len(sql) && sql[i] != c; i++ {
+				if sql[i] == '\\' {
+					i++
+				}
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case isSqlWordChar(c) && (i == 0 || !isSqlWordChar(sql[i-1])):
+			var start = i
+			for i+1 < len(sql) && isSqlWordChar(sql[i+1]) {
+				i++
+			}
+			if depth != 0 {
+				continue
+			}
+			var word = strings.ToLower(sql[start : i+1])
+			if first == "" {
+				first = word
+			}
+			switch {
+			case word == "union" || word == "intersect" || word == "except":
+				return "", nil, utils.NewError("TenantFilter", "rewrite select not support "+word+", use ignoreTenant=\"true\" and write tenant condition!")
+			case word == "from":
+				seenFrom = true
+			case !seenFrom:
+			case word == "where" && wherePos == -1 && endArgs == -1:
+				wherePos, whereArgs = i+1, placeholders
+			case tenantClauseKeywords[word] && endArgs == -1:
+				endPos, endArgs = start, placeholders
+			}
+		}
+	}
+	if first != "select" {
+		return "", nil, utils.NewError("TenantFilter", "rewrite select not support sql: "+sql)
+	}
+	if endArgs == -1 {
+		endArgs = placeholders
+		//去掉结尾的分号
+		endPos = len(strings.TrimRight(sql, " \t\r\n;"))
+	}
+	if placeholders != len(args) {
+		return "", nil, utils.NewError("TenantFilter", "rewrite select args not match, need "+strconv.Itoa(placeholders)+" args but got "+strconv.Itoa(len(args))+"!")
+	}
+	var condition = column + " = " + ast.SQLPlaceholder
+	var newArgs = make([]interface{}, 0, len(args)+1)
+	if wherePos == -1 {
+		newArgs = append(append(append(newArgs, args[:endArgs]...), tenantId), args[endArgs:]...)
+		return sql[:endPos] + " where " + condition + " " + sql[endPos:], newArgs, nil
+	}
+	newArgs = append(append(append(newArgs, args[:whereArgs]...), tenantId), args[whereArgs:]...)
+	return sql[:wherePos] + " " + condition + " and (" + sql[wherePos:endPos] + ") " + sql[endPos:], newArgs, nil
+}
+
+func isSqlWordChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+//resultMap中除租户列以外的列，租户列由租户值写入
+func tenantInsertElements(resultMapData *etree.Element, tenantColumn string) []*etree.Element {
+	var elements = resultMapData.ChildElements()
+	if tenantColumn == "" {
+		return elements
+	}
+	var result = make([]*etree.Element, 0, len(elements))
+	for _, v := range elements {
+		if !strings.EqualFold(v.SelectAttrValue("column", ""), tenantColumn) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
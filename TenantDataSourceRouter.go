@@ -0,0 +1,336 @@
+package GoMybatis
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//租户隔离方式
+type TenantIsolation = int
+
+const (
+	TenantIsolation_Database TenantIsolation = iota //每个租户一个数据库，按DSN模板创建 *sql.DB
+	TenantIsolation_Schema                          //共享数据库，session开始时切换到租户的schema
+)
+
+//DSN模板、schema模板中的租户占位符
+const TenantPlaceholder = "{tenant}"
+
+//默认租户数据库空闲关闭时间
+const DefaultTenantIdleTimeout = 10 * time.Minute
+
+//租户只允许字母、数字、下划线，避免拼接到DSN、schema中
+var tenantKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+//从ctx解析当前租户
+type TenantResolver func(ctx context.Context) (string, error)
+
+type tenantDataSource struct {
+	dsn      string
+	db       *sql.DB
+	sessions int       //未关闭的session数量
+	lastUsed time.Time //最后一个session关闭（或创建）的时间
+}
+
+//多租户数据源路由，按mapper方法参数中的context.Context解析租户：
+//
+//	//每个租户一个数据库，首次使用时按DSN模板打开，空闲超过 SetIdleTimeout 后关闭
+//	var router = GoMybatis.TenantDataSourceRouter{}.New(resolver)
+//	router.SetDatabaseTemplate("mysql", "root:123456@tcp(localhost:3306)/tenant_{tenant}")
+//	engine.SetDataSourceRouter(&router)
+//
+//	//共享数据库，每个session固定一个连接并切换schema（mysql: use，postgres: set search_path），
+//	//session关闭时执行 SetSchemaResetStatement 的语句后归还连接（postgres默认 reset search_path），没有恢复语句时丢弃连接
+//	var router = GoMybatis.TenantDataSourceRouter{}.New(resolver)
+//	router.SetSchemaTemplate("tenant_{tenant}")
+//	engine.SetDataSourceRouter(&router)
+//	engine.Open("postgres", uri)
+//
+//session只属于一个租户，事务需使用 RouterTenant 创建的session；session与ctx的租户不一致时语句返回错误。
+//没有ctx时（AOP事务、mapper的NewSession、没有ctx参数的mapper方法）使用 BindTenant 绑定到当前协程的租户：
+//
+//	var unbind = router.BindTenant("t1")
+//	defer unbind()
+//	service.Transfer() //AopProxyService 代理的事务
+type TenantDataSourceRouter struct {
+	mutex           *sync.Mutex
+	resolver        TenantResolver
+	isolation       TenantIsolation
+	driver          string
+	url             string
+	db              *sql.DB //schema隔离共享的数据库
+	template        string  //DSN模板或schema模板
+	schemaStatement string  //切换schema的语句，%s为加引号的schema名
+	schemaReset     string  //归还连接前恢复schema的语句
+	idleTimeout     time.Duration
+	poolOptions     PoolOptions //租户数据库的连接池配置
+	tenants         map[string]*tenantDataSource
+	bound           map[int64]string //协程id -> BindTenant 绑定的租户
+}
+
+func (it TenantDataSourceRouter) New(resolver TenantResolver) TenantDataSourceRouter {
+	if resolver == nil {
+		panic("[GoMybatis] TenantDataSourceRouter resolver can not be nil!")
+	}
+	it.mutex = &sync.Mutex{}
+	it.resolver = resolver
+	it.idleTimeout = DefaultTenantIdleTimeout
+	it.tenants = map[string]*tenantDataSource{}
+	it.bound = map[int64]string{}
+	return it
+}
+
+//每个租户一个数据库，dsnTemplate中的 {tenant} 替换为租户
+func (it *TenantDataSourceRouter) SetDatabaseTemplate(driver string, dsnTemplate string) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.isolation = TenantIsolation_Database
+	it.driver = driver
+	it.template = dsnTemplate
+}
+
+//共享数据库（GoMybatisEngine.Open 打开），schemaTemplate中的 {tenant} 替换为租户
+func (it *TenantDataSourceRouter) SetSchemaTemplate(schemaTemplate string) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.isolation = TenantIsolation_Schema
+	it.template = schemaTemplate
+}
+
+//自定义切换schema的语句，例如 "set schema %s"，默认mysql为 "use %s"，postgres为 "set search_path to %s"
+func (it *TenantDataSourceRouter) SetSchemaStatement(format string) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.schemaStatement = format
+}
+
+//自定义归还连接前恢复schema的语句，例如 "use main"，默认postgres为 "reset search_path"，其余数据库丢弃连接
+func (it *TenantDataSourceRouter) SetSchemaResetStatement(statement string) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.schemaReset = statement
+}
+
+//租户数据库没有session且空闲超过timeout后关闭，<=0 不关闭
+func (it *TenantDataSourceRouter) SetIdleTimeout(timeout time.Duration) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.idleTimeout = timeout
+}
+
+//租户数据库的连接池配置，只对之后打开的租户数据库生效
+func (it *TenantDataSourceRouter) SetPoolOptions(options PoolOptions) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	it.poolOptions = options
+}
+
+//设置schema隔离共享的数据库，该方法会被 GoMybatisEngine.Open 调用
+func (it *TenantDataSourceRouter) SetDB(driver string, url string, db *sql.DB) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	if it.driver == "" || it.isolation == TenantIsolation_Schema {
+		it.driver = driver
+	}
+	it.url = url
+	it.db = db
+}
+
+//绑定当前协程的租户，没有ctx时 Router 使用该租户，返回解除绑定的函数
+func (it *TenantDataSourceRouter) BindTenant(tenant string) func() {
+	var goroutineID = utils.GoroutineID()
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	var old, haveOld = it.bound[goroutineID]
+	it.bound[goroutineID] = tenant
+	return func() {
+		it.mutex.Lock()
+		defer it.mutex.Unlock()
+		if haveOld {
+			it.bound[goroutineID] = old
+		} else {
+			delete(it.bound, goroutineID)
+		}
+	}
+}
+
+//当前协程 BindTenant 绑定的租户，没有返回""
+func (it *TenantDataSourceRouter) BoundTenant() string {
+	var goroutineID = utils.GoroutineID()
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	return it.bound[goroutineID]
+}
+
+//使用当前协程 BindTenant 绑定的租户创建session，没有绑定租户返回错误
+func (it *TenantDataSourceRouter) Router(mapperName string, engine SessionEngine) (Session, error) {
+	var tenant = it.BoundTenant()
+	if tenant == "" {
+		return nil, utils.NewError("TenantDataSourceRouter", "can not router without tenant, use BindTenant(), RouterTenant() or mapper method with context.Context arg!")
+	}
+	return it.RouterTenant(tenant, engine)
+}
+
+//按请求ctx中的租户路由，没有ctx时同 Router
+func (it *TenantDataSourceRouter) RouterStatement(request RouterRequest, engine SessionEngine) (Session, error) {
+	if request.Context == nil {
+		return it.Router(request.MapperName, engine)
+	}
+	var tenant, err = it.ResolveTenant(request.Context)
+	if err != nil {
+		return nil, err
+	}
+	return it.RouterTenant(tenant, engine)
+}
+
+//解析ctx中的租户
+func (it *TenantDataSourceRouter) ResolveTenant(ctx context.Context) (string, error) {
+	if ctx == nil {
+		return "", utils.NewError("TenantDataSourceRouter", "can not router without tenant, mapper method need a context.Context arg!")
+	}
+	var tenant, err = it.resolver(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !tenantKeyPattern.MatchString(tenant) {
+		return "", utils.NewError("TenantDataSourceRouter", "tenant '"+tenant+"' is not valid, only allow letters, digits and '_'!")
+	}
+	return tenant, nil
+}
+
+//创建租户的session，可用于事务
+func (it *TenantDataSourceRouter) RouterTenant(tenant string, engine SessionEngine) (Session, error) {
+	if !tenantKeyPattern.MatchString(tenant) {
+		return nil, utils.NewError("TenantDataSourceRouter", "tenant '"+tenant+"' is not valid, only allow letters, digits and '_'!")
+	}
+	it.EvictIdle()
+	it.mutex.Lock()
+	if it.template == "" {
+		it.mutex.Unlock()
+		return nil, utils.NewError("TenantDataSourceRouter", "need SetDatabaseTemplate() or SetSchemaTemplate()!")
+	}
+	var name = strings.Replace(it.template, TenantPlaceholder, tenant, -1)
+	if it.isolation == TenantIsolation_Schema {
+		var driver, url, db, format, reset = it.driver, it.url, it.db, it.schemaStatement, it.schemaReset
+		it.mutex.Unlock()
+		if db == nil {
+			return nil, utils.NewError("TenantDataSourceRouter", "schema isolation need a database, do you forget invoke GoMybatisEngine.Open()?")
+		}
+		var dialect = DialectOf(driver)
+		if format == "" {
+			format = defaultSchemaStatement(dialect)
+			if format == "" {
+				return nil, utils.NewError("TenantDataSourceRouter", "driver "+driver+" not support switch schema, use SetSchemaStatement()!")
+			}
+		}
+		if reset == "" {
+			reset = defaultSchemaResetStatement(dialect)
+		}
+		var local = newRouterSession(driver, url, db, engine).(*LocalSession)
+		local.tenant = tenant
+		if err := local.pinConn(fmt.Sprintf(format, QuoteIdentifier(dialect, name)), reset); err != nil {
+			local.Close()
+			return nil, err
+		}
+		return local, nil
+	}
+	var source = it.tenants[tenant]
+	if source == nil {
+		var db, err = openPool(it.driver, name, it.poolOptions)
+		if err != nil {
+			it.mutex.Unlock()
+			return nil, err
+		}
+		source = &tenantDataSource{dsn: name, db: db}
+		it.tenants[tenant] = source
+	}
+	source.sessions++
+	source.lastUsed = time.Now()
+	var driver = it.driver
+	it.mutex.Unlock()
+
+	var local = newRouterSession(driver, source.dsn, source.db, engine).(*LocalSession)
+	local.tenant = tenant
+	local.onClose = func() {
+		it.mutex.Lock()
+		defer it.mutex.Unlock()
+		source.sessions--
+		source.lastUsed = time.Now()
+	}
+	return local, nil
+}
+
+//关闭空闲超时的租户数据库，返回关闭的数量
+func (it *TenantDataSourceRouter) EvictIdle() int {
+	var closeDBs = []*sql.DB{}
+	it.mutex.Lock()
+	if it.idleTimeout > 0 {
+		var now = time.Now()
+		for tenant, source := range it.tenants {
+			if source.sessions <= 0 && now.Sub(source.lastUsed) >= it.idleTimeout {
+				delete(it.tenants, tenant)
+				closeDBs = append(closeDBs, source.db)
+			}
+		}
+	}
+	it.mutex.Unlock()
+	for _, db := range closeDBs {
+		db.Close()
+	}
+	return len(closeDBs)
+}
+
+//已打开的租户数据库
+func (it *TenantDataSourceRouter) TenantDB(tenant string) *sql.DB {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	if source := it.tenants[tenant]; source != nil {
+		return source.db
+	}
+	return nil
+}
+
+//已打开的数据源，租户数据库的名称为租户，schema隔离共享的数据库为 shared
+func (it *TenantDataSourceRouter) DataSources() map[string]*sql.DB {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	var dataSources = map[string]*sql.DB{}
+	if it.db != nil {
+		dataSources["shared"] = it.db
+	}
+	for tenant, source := range it.tenants {
+		dataSources[tenant] = source.db
+	}
+	return dataSources
+}
+
+func (it *TenantDataSourceRouter) Name() string {
+	return "TenantDataSourceRouter"
+}
+
+//方言默认的切换schema语句
+func defaultSchemaStatement(dialect Dialect) string {
+	switch dialect {
+	case Dialect_MySQL:
+		return "use %s"
+	case Dialect_Postgres:
+		return "set search_path to %s"
+	}
+	return ""
+}
+
+//方言默认的恢复schema语句，没有返回""（丢弃连接）
+func defaultSchemaResetStatement(dialect Dialect) string {
+	switch dialect {
+	case Dialect_Postgres:
+		return "reset search_path"
+	}
+	return ""
+}
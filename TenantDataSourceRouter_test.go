@@ -0,0 +1,199 @@
+package GoMybatis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zhuxiujia/GoMybatis/tx"
+)
+
+type tenantRouterTestKey struct{}
+
+type TenantRouterTestMapper struct {
+	SelectAll   func(ctx context.Context) ([]map[string]string, error)                   `mapperParams:"ctx"`
+	SelectTx    func(ctx context.Context, session *Session) ([]map[string]string, error) `mapperParams:"ctx,session"`
+	SelectNoCtx func() ([]map[string]string, error)
+}
+
+func newTenantRouterTestMapper(router *TenantDataSourceRouter) TenantRouterTestMapper {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	engine.SetDataSourceRouter(router)
+	var mapper TenantRouterTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectAll">select * from biz_activity</select>
+    <select id="selectTx">select * from biz_activity</select>
+    <select id="selectNoCtx">select * from biz_activity</select>
+</mapper>`))
+	return mapper
+}
+
+func tenantRouterContext(tenant string) context.Context {
+	return context.WithValue(context.Background(), tenantRouterTestKey{}, tenant)
+}
+
+func resolveTenantRouterTest(ctx context.Context) (string, error) {
+	var tenant, _ = ctx.Value(tenantRouterTestKey{}).(string)
+	if tenant == "" {
+		return "", errors.New("no tenant")
+	}
+	return tenant, nil
+}
+
+func TestTenantDataSourceRouter_Database(t *testing.T) {
+	resetTestDriverState("TestTenantRouter_a", "TestTenantRouter_b")
+	var router = TenantDataSourceRouter{}.New(resolveTenantRouterTest)
+	router.SetDatabaseTemplate(TestDriverName, "TestTenantRouter_{tenant}")
+	router.SetIdleTimeout(time.Minute)
+	var mapper = newTenantRouterTestMapper(&router)
+
+	for _, tenant := range []string{"a", "b", "a"} {
+		if _, err := mapper.SelectAll(tenantRouterContext(tenant)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(testDriverState("TestTenantRouter_a").Queries) != 2 || len(testDriverState("TestTenantRouter_b").Queries) != 1 {
+		t.Fatal("tenant database route fail")
+	}
+	if _, err := mapper.SelectAll(tenantRouterContext("a;drop")); err == nil {
+		t.Fatal("invalid tenant must fail")
+	}
+	if _, err := mapper.SelectNoCtx(); err == nil {
+		t.Fatal("no tenant must fail")
+	}
+
+	//事务session属于一个租户
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var session, err = router.RouterTenant("a", &engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mapper.SelectTx(tenantRouterContext("a"), &session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mapper.SelectTx(tenantRouterContext("b"), &session); err == nil {
+		t.Fatal("cross tenant session must fail")
+	}
+
+	//空闲淘汰：有未关闭session的租户不淘汰
+	router.tenants["a"].lastUsed = time.Now().Add(-time.Hour)
+	router.tenants["b"].lastUsed = time.Now().Add(-time.Hour)
+	if n := router.EvictIdle(); n != 1 || router.TenantDB("b") != nil || router.TenantDB("a") == nil {
+		t.Fatal("evict idle fail:", n)
+	}
+	session.Close()
+	router.tenants["a"].lastUsed = time.Now().Add(-time.Hour)
+	if n := router.EvictIdle(); n != 1 || router.TenantDB("a") != nil {
+		t.Fatal("evict closed session tenant fail:", n)
+	}
+	//淘汰后重新打开
+	if _, err := mapper.SelectAll(tenantRouterContext("b")); err != nil || len(testDriverState("TestTenantRouter_b").Queries) != 2 {
+		t.Fatal("reopen tenant fail:", err)
+	}
+}
+
+func TestTenantDataSourceRouter_Schema(t *testing.T) {
+	resetTestDriverState("TestTenantSchema")
+	var router = TenantDataSourceRouter{}.New(resolveTenantRouterTest)
+	router.SetSchemaTemplate("tenant_{tenant}")
+	var mapper = newTenantRouterTestMapper(&router)
+	if _, err := mapper.SelectAll(tenantRouterContext("a")); err == nil {
+		t.Fatal("schema isolation without database must fail")
+	}
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	engine.SetDataSourceRouter(&router)
+	engine.Open(TestDriverName, "TestTenantSchema")
+	if _, err := mapper.SelectAll(tenantRouterContext("a")); err == nil {
+		t.Fatal("unknown driver without schema statement must fail")
+	}
+	router.SetSchemaStatement("use %s")
+	if _, err := mapper.SelectAll(tenantRouterContext("a")); err != nil {
+		t.Fatal(err)
+	}
+	var state = testDriverState("TestTenantSchema")
+	if len(state.Execs) != 1 || state.Execs[0] != "use tenant_a" || len(state.Queries) != 1 {
+		t.Fatal("switch schema fail:", state.Execs, state.Queries)
+	}
+
+	//REQUIRES_NEW 新建的session同样切换到租户schema
+	var session, err = router.RouterTenant("b", &engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	var propagation = tx.PROPAGATION_REQUIRES_NEW
+	if err := session.Begin(&propagation); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Execs) != 3 || state.Execs[1] != "use tenant_b" || state.Execs[2] != "use tenant_b" {
+		t.Fatal("requires new session switch schema fail:", state.Execs)
+	}
+
+	//没有恢复语句时丢弃连接，不归还连接池
+	resetTestDriverState("TestTenantSchema")
+	if _, err := mapper.SelectAll(tenantRouterContext("a")); err != nil {
+		t.Fatal(err)
+	}
+	if state.ClosedConns != 1 || len(state.Execs) != 1 {
+		t.Fatal("discard schema conn fail:", state.ClosedConns, state.Execs)
+	}
+	//有恢复语句时恢复schema后归还连接池
+	router.SetSchemaResetStatement("use main")
+	resetTestDriverState("TestTenantSchema")
+	if _, err := mapper.SelectAll(tenantRouterContext("a")); err != nil {
+		t.Fatal(err)
+	}
+	if state.ClosedConns != 0 || len(state.Execs) != 2 || state.Execs[1] != "use main" {
+		t.Fatal("reset schema conn fail:", state.ClosedConns, state.Execs)
+	}
+}
+
+type TenantRouterTestService struct {
+	Transfer func() error `tx:""`
+}
+
+func TestTenantDataSourceRouter_BindTenant(t *testing.T) {
+	resetTestDriverState("TestTenantRouter_a", "TestTenantRouter_b")
+	var router = TenantDataSourceRouter{}.New(resolveTenantRouterTest)
+	router.SetDatabaseTemplate(TestDriverName, "TestTenantRouter_{tenant}")
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	engine.SetDataSourceRouter(&router)
+	if _, err := engine.NewSession(""); err == nil {
+		t.Fatal("router without tenant must fail")
+	}
+
+	//绑定协程租户后，AOP事务和没有ctx的mapper方法使用该租户
+	var mapper = newTenantRouterTestMapper(&router)
+	var service = TenantRouterTestService{}
+	service.Transfer = func() error { return nil }
+	AopProxyService(&service, &engine)
+	var unbind = router.BindTenant("a")
+	var unbindB = router.BindTenant("b")
+	if err := service.Transfer(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mapper.SelectNoCtx(); err != nil {
+		t.Fatal(err)
+	}
+	unbindB()
+	if _, err := mapper.SelectNoCtx(); err != nil {
+		t.Fatal(err)
+	}
+	unbind()
+	if router.BoundTenant() != "" {
+		t.Fatal("unbind tenant fail")
+	}
+	if _, err := mapper.SelectNoCtx(); err == nil {
+		t.Fatal("no tenant after unbind must fail")
+	}
+	var a, b = testDriverState("TestTenantRouter_a"), testDriverState("TestTenantRouter_b")
+	if b.Begins != 1 || len(b.Queries) != 1 || a.Begins != 0 || len(a.Queries) != 1 {
+		t.Fatal("bind tenant route fail:", a.Begins, len(a.Queries), b.Begins, len(b.Queries))
+	}
+}
@@ -0,0 +1,138 @@
+package GoMybatis
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+)
+
+type tenantTestKey struct{}
+
+type TenantTestArg struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type TenantTestMapper struct {
+	SelectTempl  func(ctx context.Context, session *Session, name string) ([]TenantTestArg, error)                      `mapperParams:"ctx,session,name"`
+	InsertTempl  func(ctx context.Context, session *Session, arg TenantTestArg) (int64, error)                          `mapperParams:"ctx,session,arg"`
+	InsertBatch  func(ctx context.Context, session *Session, args []TenantTestArg) (int64, error)                       `mapperParams:"ctx,session,args"`
+	UpdateTempl  func(ctx context.Context, session *Session, arg TenantTestArg) (int64, error)                          `mapperParams:"ctx,session,arg"`
+	DeleteTempl  func(ctx context.Context, session *Session, id string) (int64, error)                                  `mapperParams:"ctx,session,id"`
+	SelectIgnore func(ctx context.Context, session *Session) ([]TenantTestArg, error)                                   `mapperParams:"ctx,session"`
+	SelectWrite  func(ctx context.Context, session *Session, name string, id string, size int) ([]TenantTestArg, error) `mapperParams:"ctx,session,name,id,size"`
+	SelectCount  func(ctx context.Context, session *Session) ([]TenantTestArg, error)                                   `mapperParams:"ctx,session"`
+	SelectBind   func(ctx context.Context, session *Session) ([]TenantTestArg, error)                                   `mapperParams:"ctx,session"`
+	SelectUnion  func(ctx context.Context, session *Session) ([]TenantTestArg, error)                                   `mapperParams:"ctx,session"`
+}
+
+func newTenantTestMapper() TenantTestMapper {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	engine.TenantFilter().Enable("tenant_id", func(ctx context.Context) (interface{}, error) {
+		return ctx.Value(tenantTestKey{}), nil
+	})
+	engine.TenantFilter().SetRewriteSelect(true)
+	var mapper TenantTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <resultMap id="BaseResultMap" tables="biz_activity">
+        <id column="id" property="id"/>
+        <result column="name" property="name" langType="string"/>
+        <result column="tenant_id" property="tenant_id" langType="string"/>
+    </resultMap>
+    <selectTemplete id="selectTempl" wheres="name?name = #{name}"/>
+    <insertTemplete id="insertTempl"/>
+    <insertTemplete id="insertBatch"/>
+    <updateTemplete id="updateTempl" sets="name?name = #{name}" wheres="id = #{id}"/>
+    <deleteTemplete id="deleteTempl" wheres="id = #{id}"/>
+    <selectTemplete id="selectIgnore" ignoreTenant="true" wheres="id = 1"/>
+    <select id="selectWrite">select * from biz_activity where name = #{name} or id = #{id} order by id limit #{size}</select>
+    <select id="selectCount">select count(*) from (select id from biz_activity where name = 'a?') t;</select>
+    <select id="selectBind" tenant="true">select * from biz_activity a join biz_user u on a.user_id = u.id where a.tenant_id = #{_tenantId}</select>
+    <select id="selectUnion">select id from biz_activity union select id from biz_user</select>
+</mapper>`))
+	return mapper
+}
+
+func tenantContext(tenantId string) context.Context {
+	return context.WithValue(context.Background(), tenantTestKey{}, tenantId)
+}
+
+func TestTenantFilter_Templete(t *testing.T) {
+	var mapper = newTenantTestMapper()
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	var ctx = tenantContext("t1")
+	var cases = []struct {
+		exe  func()
+		sql  string
+		args []interface{}
+	}{
+		{func() { mapper.SelectTempl(ctx, &session, "a") },
+			"select * from biz_activity where name = ? and tenant_id = ?", []interface{}{"a", "t1"}},
+		{func() { mapper.InsertTempl(ctx, &session, TenantTestArg{Id: "1", Name: "a"}) },
+			"insert into biz_activity (id,name,tenant_id) values (?,?,?)", []interface{}{"1", "a", "t1"}},
+		{func() { mapper.InsertBatch(ctx, &session, []TenantTestArg{{Id: "1"}, {Id: "2"}}) },
+			"insert into biz_activity (id,name,tenant_id) values (?,?,?),(?,?,?)", []interface{}{"1", "", "t1", "2", "", "t1"}},
+		{func() { mapper.UpdateTempl(ctx, &session, TenantTestArg{Id: "1", Name: "a"}) },
+			"update biz_activity set name = ? where id = ? and tenant_id = ?", []interface{}{"a", "1", "t1"}},
+		{func() { mapper.DeleteTempl(ctx, &session, "1") },
+			"delete from biz_activity where id = ? and tenant_id = ?", []interface{}{"1", "t1"}},
+		{func() { mapper.SelectIgnore(context.Background(), &session) },
+			"select * from biz_activity where id = 1", []interface{}{}},
+	}
+	for _, c := range cases {
+		c.exe()
+		if compactSql(capture.LastSql()) != compactSql(c.sql) || !reflect.DeepEqual(capture.LastArgs(), c.args) {
+			t.Fatal("tenant templete fail:", capture.LastSql(), capture.LastArgs())
+		}
+	}
+	//没有租户值时不执行
+	var count = len(capture.Sqls)
+	if _, err := mapper.SelectTempl(context.Background(), &session, "a"); err == nil || len(capture.Sqls) != count {
+		t.Fatal("tenant id nil must fail")
+	}
+}
+
+func TestTenantFilter_RewriteSelect(t *testing.T) {
+	var mapper = newTenantTestMapper()
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	var ctx = tenantContext("t1")
+
+	mapper.SelectWrite(ctx, &session, "a", "1", 10)
+	if compactSql(capture.LastSql()) != compactSql("select * from biz_activity where tenant_id = ? and ( name = ? or id = ? ) order by id limit ?") ||
+		!reflect.DeepEqual(capture.LastArgs(), []interface{}{"t1", "a", "1", 10}) {
+		t.Fatal("rewrite where fail:", capture.LastSql(), capture.LastArgs())
+	}
+	mapper.SelectCount(ctx, &session)
+	if compactSql(capture.LastSql()) != compactSql("select count(*) from (select id from biz_activity where name = 'a?') t where tenant_id = ? ;") {
+		t.Fatal("rewrite no where fail:", capture.LastSql())
+	}
+	mapper.SelectBind(ctx, &session)
+	if !strings.HasSuffix(compactSql(capture.LastSql()), "wherea.tenant_id=?") || !reflect.DeepEqual(capture.LastArgs(), []interface{}{"t1"}) {
+		t.Fatal("bind tenant fail:", capture.LastSql(), capture.LastArgs())
+	}
+	if _, err := mapper.SelectUnion(ctx, &session); err == nil || !strings.Contains(err.Error(), "union") {
+		t.Fatal("rewrite union must fail:", err)
+	}
+}
+
+func TestRewriteTenantSelect_Args(t *testing.T) {
+	var p = ast.SQLPlaceholder
+	var sql, args, err = rewriteTenantSelect("select * from t where a in (select b from c where d = "+p+") group by a having count(*) > "+p, "tenant_id", []interface{}{1, 2}, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expect = "select * from t where tenant_id = " + p + " and ( a in (select b from c where d = " + p + ") ) group by a having count(*) > " + p
+	if compactSql(sql) != compactSql(expect) || !reflect.DeepEqual(args, []interface{}{"t1", 1, 2}) {
+		t.Fatal("rewrite fail:", sql, args)
+	}
+	if _, _, err = rewriteTenantSelect("update t set a = 1", "tenant_id", nil, "t1"); err == nil {
+		t.Fatal("rewrite not select must fail")
+	}
+}
@@ -0,0 +1,179 @@
+package GoMybatis
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+)
+
+//测试用的sql驱动，不连接数据库，按dsn记录执行情况
+const TestDriverName = "gomybatis_test"
+
+func init() {
+	sql.Register(TestDriverName, &testDriver{})
+}
+
+var testDriverStates sync.Map //map[dsn]*TestDriverState
+
+type TestDriverState struct {
+	mutex sync.Mutex
+
+	Opens       int //打开的连接数
+	ClosedConns int
+	Prepares    int
+	ClosedStmts int
+	Begins      int
+	Commits     int
+	Rollbacks   int
+	Execs       []string
+	Queries     []string
+	ExecArgs    [][]driver.Value //Exec 的参数，与Execs对应
+
+	PingErr error    //不为nil时Ping返回该错误
+	ExecErr error    //不为nil时Exec返回该错误
+	Columns []string //Query 返回的列
+	Rows    [][]driver.Value
+}
+
+//获取dsn对应的驱动状态
+func testDriverState(dsn string) *TestDriverState {
+	var v, _ = testDriverStates.LoadOrStore(dsn, &TestDriverState{})
+	return v.(*TestDriverState)
+}
+
+//清空dsn对应的驱动状态（已打开的连接同样生效），测试开始时调用，使 -count、-shuffle 下的断言不受之前测试影响
+func resetTestDriverState(dsns ...string) {
+	for _, dsn := range dsns {
+		var state = testDriverState(dsn)
+		state.lock(func() {
+			state.Opens, state.ClosedConns = 0, 0
+			state.Prepares, state.ClosedStmts = 0, 0
+			state.Begins, state.Commits, state.Rollbacks = 0, 0, 0
+			state.Execs, state.Queries, state.ExecArgs = nil, nil, nil
+			state.PingErr, state.ExecErr = nil, nil
+			state.Columns, state.Rows = nil, nil
+		})
+	}
+}
+
+func (it *TestDriverState) lock(f func()) {
+	it.mutex.Lock()
+	defer it.mutex.Unlock()
+	f()
+}
+
+type testDriver struct {
+}
+
+func (it *testDriver) Open(dsn string) (driver.Conn, error) {
+	var state = testDriverState(dsn)
+	state.lock(func() { state.Opens++ })
+	return &testConn{state: state}, nil
+}
+
+type testConn struct {
+	state *TestDriverState
+}
+
+func (it *testConn) Prepare(query string) (driver.Stmt, error) {
+	it.state.lock(func() { it.state.Prepares++ })
+	return &testStmt{state: it.state, query: query}, nil
+}
+
+func (it *testConn) Close() error {
+	it.state.lock(func() { it.state.ClosedConns++ })
+	return nil
+}
+
+func (it *testConn) Begin() (driver.Tx, error) {
+	it.state.lock(func() { it.state.Begins++ })
+	return &testTx{state: it.state}, nil
+}
+
+func (it *testConn) Ping(ctx context.Context) error {
+	var err error
+	it.state.lock(func() { err = it.state.PingErr })
+	return err
+}
+
+type testTx struct {
+	state *TestDriverState
+}
+
+func (it *testTx) Commit() error {
+	it.state.lock(func() { it.state.Commits++ })
+	return nil
+}
+
+func (it *testTx) Rollback() error {
+	it.state.lock(func() { it.state.Rollbacks++ })
+	return nil
+}
+
+type testStmt struct {
+	state *TestDriverState
+	query string
+}
+
+func (it *testStmt) Close() error {
+	it.state.lock(func() { it.state.ClosedStmts++ })
+	return nil
+}
+
+func (it *testStmt) NumInput() int {
+	return -1
+}
+
+func (it *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	var err error
+	it.state.lock(func() {
+		err = it.state.ExecErr
+		if err == nil {
+			it.state.Execs = append(it.state.Execs, it.query)
+			it.state.ExecArgs = append(it.state.ExecArgs, args)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (it *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	var rows = &testRows{}
+	it.state.lock(func() {
+		it.state.Queries = append(it.state.Queries, it.query)
+		rows.columns = it.state.Columns
+		rows.rows = it.state.Rows
+	})
+	return rows, nil
+}
+
+type testRows struct {
+	columns []string
+	rows    [][]driver.Value
+	index   int
+}
+
+func (it *testRows) Columns() []string {
+	return it.columns
+}
+
+func (it *testRows) Close() error {
+	return nil
+}
+
+func (it *testRows) Next(dest []driver.Value) error {
+	if it.index >= len(it.rows) {
+		return io.EOF
+	}
+	if len(dest) != len(it.rows[it.index]) {
+		return errors.New("testRows: column count not match")
+	}
+	copy(dest, it.rows[it.index])
+	it.index++
+	return nil
+}
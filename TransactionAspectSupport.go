@@ -22,12 +22,21 @@ func AopProxyService(service interface{}, engine SessionEngine) {
 func AopProxyServiceValue(service reflect.Value, engine SessionEngine) {
 	var beanType = service.Type().Elem()
 	var beanName = beanType.PkgPath() + beanType.Name()
+	var serviceDataSource = findMapperDataSource(beanType)
 	ProxyValue(service, func(funcField reflect.StructField, field reflect.Value) func(arg ProxyArg) []reflect.Value {
 		//init data
 		var propagation = tx.PROPAGATION_NEVER
 		var nativeImplFunc = reflect.ValueOf(field.Interface())
 		var txTag, haveTx = funcField.Tag.Lookup("tx")
 		var rollbackTag = funcField.Tag.Get("rollback")
+		//同mapper，方法tag datasource 优先于结构体tag
+		var dataSource = funcField.Tag.Get(DataSourceTag)
+		if dataSource == "" {
+			dataSource = serviceDataSource
+		}
+		if dataSource != "" {
+			checkDataSourceTag(engine, dataSource, beanName+"."+funcField.Name)
+		}
 		if haveTx {
 			propagation = tx.NewPropagation(txTag)
 		}
@@ -40,9 +49,14 @@ func AopProxyServiceValue(service reflect.Value, engine SessionEngine) {
 			}
 			var session = engine.GoroutineSessionMap().Get(goroutineID)
 			if session == nil {
+				defer trackActive(engine)()
 				//todo newSession is use service bean name?
 				var err error
-				session, err = engine.NewSession(beanName)
+				if dataSource != "" {
+					session, err = routerSession(RouterRequest{MapperName: beanName, DataSource: dataSource}, engine)
+				} else {
+					session, err = engine.NewSession(beanName)
+				}
 				defer func() {
 					if session != nil {
 						session.Close()
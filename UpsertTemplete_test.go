@@ -0,0 +1,103 @@
+package GoMybatis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zhuxiujia/GoMybatis/example"
+)
+
+type UpsertTestMapper struct {
+	Upsert      func(session *Session, arg example.Activity) (int64, error)    `mapperParams:"session,arg"`
+	UpsertBatch func(session *Session, args []example.Activity) (int64, error) `mapperParams:"session,args"`
+}
+
+func newUpsertTestMapper(attrs string) UpsertTestMapper {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper UpsertTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <resultMap id="BaseResultMap" tables="biz_activity">
+        <id column="id" property="id"/>
+        <result column="name" property="name" langType="string"/>
+        <result column="version" property="version" langType="int" version_enable="true"/>
+        <result column="delete_flag" property="delete_flag" langType="int" logic_enable="true" logic_undelete="1" logic_deleted="0"/>
+    </resultMap>
+    <upsertTemplete id="upsert" `+attrs+`/>
+    <upsertTemplete id="upsertBatch" `+attrs+`/>
+</mapper>`))
+	return mapper
+}
+
+func upsertSql(t *testing.T, mapper UpsertTestMapper) string {
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	if _, err := mapper.Upsert(&session, example.Activity{Id: "1", Name: "a", Version: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if len(capture.LastArgs()) != 3 {
+		t.Fatal("upsert args fail:", capture.LastArgs())
+	}
+	return compactSql(capture.LastSql())
+}
+
+//去掉全部空白字符，便于比较生成的sql
+func compactSql(sql string) string {
+	return strings.Join(strings.Fields(sql), "")
+}
+
+func TestUpsertTemplete_MySQL(t *testing.T) {
+	var sql = upsertSql(t, newUpsertTestMapper(""))
+	var expect = "insert into biz_activity (id,name,version,delete_flag) values ( ? , ? , ? ,'1') on duplicate key update " +
+		"name = if(version = values(version),values(name),name),delete_flag = if(version = values(version),values(delete_flag),delete_flag)," +
+		"version = if(version = values(version),version + 1,version)"
+	if sql != compactSql(expect) {
+		t.Fatal("mysql upsert fail:", sql)
+	}
+}
+
+func TestUpsertTemplete_Postgres(t *testing.T) {
+	var sql = upsertSql(t, newUpsertTestMapper(`dialect="postgres" conflict="name"`))
+	var expect = "insert into biz_activity (id,name,version,delete_flag) values ( ? , ? , ? ,'1') on conflict (name) do update set " +
+		"id = excluded.id,delete_flag = excluded.delete_flag,version = biz_activity.version + 1 where biz_activity.version = excluded.version"
+	if sql != compactSql(expect) {
+		t.Fatal("postgres upsert fail:", sql)
+	}
+}
+
+func TestUpsertTemplete_SQLServer(t *testing.T) {
+	var sql = upsertSql(t, newUpsertTestMapper(`dialect="sqlserver"`))
+	var expect = "merge into biz_activity as t using (values ( ? , ? , ? ,'1')) as s (id,name,version,delete_flag) on t.id = s.id " +
+		"when matched and t.version = s.version then update set name = s.name,delete_flag = s.delete_flag,version = t.version + 1 " +
+		"when not matched then insert (id,name,version,delete_flag) values (s.id,s.name,s.version,s.delete_flag);"
+	if sql != compactSql(expect) {
+		t.Fatal("sqlserver upsert fail:", sql)
+	}
+}
+
+func TestUpsertTemplete_Batch(t *testing.T) {
+	var mapper = newUpsertTestMapper(`dialect="sqlite"`)
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	var acts = []example.Activity{{Id: "1", Name: "a"}, {Id: "2", Name: "b"}}
+	if _, err := mapper.UpsertBatch(&session, acts); err != nil {
+		t.Fatal(err)
+	}
+	var sql = compactSql(capture.LastSql())
+	if !strings.HasPrefix(sql, compactSql("insert into biz_activity (id,name,version,delete_flag) values ( ? , ? , ? ,'1'),( ? , ? , ? ,'1') on conflict (id) do update set ")) {
+		t.Fatal("batch upsert fail:", sql)
+	}
+	if len(capture.LastArgs()) != 6 || capture.LastArgs()[3] != "2" {
+		t.Fatal("batch upsert args fail:", capture.LastArgs())
+	}
+}
+
+func TestUpsertTemplete_BadConflict(t *testing.T) {
+	defer func() {
+		if e := recover(); e == nil {
+			t.Fatal("unknown conflict column must panic")
+		}
+	}()
+	newUpsertTestMapper(`conflict="not_exist"`)
+}
@@ -0,0 +1,510 @@
+package GoMybatis
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zhuxiujia/GoMybatis/ast"
+	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
+	"github.com/zhuxiujia/GoMybatis/utils"
+)
+
+//mapper xml文件，Name用于诊断信息中的文件名
+type MapperFile struct {
+	Name string
+	Data []byte
+}
+
+//校验诊断信息
+type Diagnostic struct {
+	File    string //xml文件名
+	Line    int    //行号，未知为0
+	Column  int    //列号，未知为0
+	Element string //元素id（或mapper方法名）
+	Message string
+}
+
+//例如 "ActivityMapper.xml:12:5: [selectByCondition] unknown element <iff>"
+func (it Diagnostic) String() string {
+	var s = it.File
+	if it.Line > 0 {
+		s += ":" + strconv.Itoa(it.Line)
+		if it.Column > 0 {
+			s += ":" + strconv.Itoa(it.Column)
+		}
+	}
+	if s != "" {
+		s += ": "
+	}
+	if it.Element != "" {
+		s += "[" + it.Element + "] "
+	}
+	return s + it.Message
+}
+
+//校验mapper与xml，返回全部诊断信息（不会panic）。ptr 为mapper结构体指针，可为nil（只校验xml）。
+//检查项：xml格式、未知元素、重复id、include引用、模板、表达式编译、resultMap属性与返回值类型、mapperParams个数与方法参数
+func ValidateMapper(ptr interface{}, files []MapperFile, engine SessionEngine) []Diagnostic {
+	var validator = mapperValidator{engine: engine, tree: map[string]etree.Token{}, refs: newIncludeRefs(), files: map[string]string{}, checked: map[string]bool{}}
+	for _, file := range files {
+		validator.loadFile(file)
+	}
+	var beanType reflect.Type
+	if ptr != nil {
+		beanType = reflect.TypeOf(ptr)
+		for beanType.Kind() == reflect.Ptr {
+			beanType = beanType.Elem()
+		}
+		validator.loadTags(beanType)
+	}
+	validator.processIncludes()
+	validator.decodeTempletes(beanType)
+	for _, id := range validator.sortedIds() {
+		var element = validator.tree[id].(*etree.Element)
+		if element.Tag == Element_ResultMap {
+			validator.checkResultMap(id, element)
+		} else if isMethodElement(element.Tag) {
+			validator.checkStatement(id, element)
+		}
+	}
+	if beanType != nil && beanType.Kind() == reflect.Struct {
+		validator.checkMapperStruct(beanType)
+	}
+	sort.SliceStable(validator.diagnostics, func(i, j int) bool {
+		var a, b = validator.diagnostics[i], validator.diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Element < b.Element
+	})
+	return validator.diagnostics
+}
+
+type mapperValidator struct {
+	engine      SessionEngine
+	tree        map[string]etree.Token //同 WriteMapper 的 mapperTree
+	refs        *includeRefs           //include refid查找表
+	files       map[string]string      //元素id -> 文件名
+	parseFail   bool                   //有xml解析失败，不再检查方法是否缺失
+	checked     map[string]bool        //已检查的 resultMap id + 返回值类型
+	diagnostics []Diagnostic
+}
+
+//记录诊断信息，位置取元素或最近的有位置的上级元素
+func (it *mapperValidator) report(id string, element *etree.Element, message string) {
+	var diagnostic = Diagnostic{File: it.files[id], Element: id, Message: message}
+	for e := element; e != nil; e = e.Parent() {
+		if position, ok := ast.PositionOf(e); ok {
+			diagnostic.File = position.File
+			diagnostic.Line = position.Line
+			diagnostic.Column = position.Column
+			break
+		}
+	}
+	it.diagnostics = append(it.diagnostics, diagnostic)
+}
+
+func (it *mapperValidator) sortedIds() []string {
+	var ids = make([]string, 0, len(it.tree))
+	for id := range it.tree {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (it *mapperValidator) loadFile(file MapperFile) {
+	var bytes = file.Data
+	utils.FixTestExpressionSymbol(&bytes)
+	var doc = etree.NewDocument()
+	if err := doc.ReadFromBytes(bytes); err != nil {
+		it.diagnostics = append(it.diagnostics, xmlParseDiagnostic(file.Name, err))
+		it.parseFail = true
+		return
+	}
+	recordXmlPositions(bytes, doc, file.Name)
+	var root = doc.SelectElement(Element_Mapper)
+	if root == nil {
+		it.diagnostics = append(it.diagnostics, Diagnostic{File: file.Name, Message: "root element <" + Element_Mapper + "> not find"})
+		return
+	}
+	var namespace = root.SelectAttrValue(Namespace, "")
+	var variants = it.mergeDatabaseIds(root, file)
+	for _, element := range root.ChildElements() {
+		var id = element.SelectAttrValue(ID, "")
+		if id == "" {
+			id = element.Tag
+		}
+		if merged, ok := variants[id]; ok {
+			//同id的databaseId版本已合并，只处理一次
+			if merged == nil {
+				continue
+			}
+			variants[id] = nil
+			element = merged
+		}
+		if !isMapperElement(element.Tag) {
+			it.files[id] = file.Name
+			it.report(id, element, "unknown element <"+element.Tag+"> in <"+Element_Mapper+">")
+			continue
+		}
+		var registered = it.refs.add(namespace, id, element)
+		if old := it.tree[id]; old != nil {
+			//不同namespace中的同id <sql> 片段通过 namespace.id 区分
+			if registered && element.Tag == Element_Sql && old.(*etree.Element).Tag == Element_Sql {
+				continue
+			}
+			var message = "duplicate id '" + id + "'"
+			if position, ok := ast.PositionOf(old.(*etree.Element)); ok {
+				message += ", first defined at " + position.String()
+			}
+			var firstFile = it.files[id]
+			it.files[id] = file.Name
+			it.report(id, element, message)
+			it.files[id] = firstFile
+			continue
+		}
+		it.tree[id] = element
+		it.files[id] = file.Name
+	}
+}
+
+//合并同id的databaseId版本，返回 map[id]合并后的元素，合并失败的id对应nil
+func (it *mapperValidator) mergeDatabaseIds(root *etree.Element, file MapperFile) map[string]*etree.Element {
+	var ids = []string{}
+	var groups = make(map[string][]*etree.Element)
+	for _, element := range root.ChildElements() {
+		if !isMapperElement(element.Tag) {
+			continue
+		}
+		var id = element.SelectAttrValue(ID, "")
+		if id == "" {
+			id = element.Tag
+		}
+		if groups[id] == nil {
+			ids = append(ids, id)
+		}
+		groups[id] = append(groups[id], element)
+	}
+	var merged = make(map[string]*etree.Element)
+	for _, id := range ids {
+		if !hasDatabaseId(groups[id]) {
+			continue
+		}
+		var element, err = mergeDatabaseIdElements(groups[id])
+		if err != nil {
+			var elementError = err.(*xmlElementError)
+			it.files[id] = file.Name
+			it.report(id, elementError.element, elementError.message)
+		}
+		merged[id] = element
+	}
+	return merged
+}
+
+//合并方法tag定义的sql
+func (it *mapperValidator) loadTags(beanType reflect.Type) {
+	if beanType.Kind() != reflect.Struct {
+		return
+	}
+	var items, err = loadMapperTags(beanType)
+	if err != nil {
+		it.diagnostics = append(it.diagnostics, Diagnostic{Message: err.Error()})
+		return
+	}
+	for name, element := range items {
+		if id, xmlElement := it.findStatement(name); xmlElement != nil {
+			it.report(id, xmlElement, "method "+beanType.String()+"."+name+"() define sql both in struct tag and xml")
+			continue
+		}
+		it.tree[name] = element
+	}
+}
+
+//逐个元素展开include，每个元素的错误分别记录
+func (it *mapperValidator) processIncludes() {
+	for _, id := range it.sortedIds() {
+		var err = processIncludeElementByRefs(map[string]etree.Token{id: it.tree[id]}, it.refs)
+		if err == nil {
+			continue
+		}
+		if elementError, ok := err.(*xmlElementError); ok {
+			it.report(id, elementError.element, elementError.message)
+		} else {
+			it.report(id, it.tree[id].(*etree.Element), err.Error())
+		}
+	}
+}
+
+//逐个解码模板元素，模板的panic转为诊断信息
+func (it *mapperValidator) decodeTempletes(beanType reflect.Type) {
+	var decoder = it.engine.TempleteDecoder()
+	if decoder == nil {
+		return
+	}
+	for _, id := range it.sortedIds() {
+		var element = it.tree[id].(*etree.Element)
+		if !strings.HasSuffix(element.Tag, "Templete") {
+			continue
+		}
+		//模板需要通过tree查找resultMap
+		var tree = map[string]etree.Token{id: element}
+		for k, v := range it.tree {
+			if !isMethodElement(v.(*etree.Element).Tag) {
+				tree[k] = v
+			}
+		}
+		func() {
+			defer func() {
+				if e := recover(); e != nil {
+					it.report(id, element, fmt.Sprint(e))
+				}
+			}()
+			if err := decoder.DecodeTree(tree, beanType); err != nil {
+				it.report(id, element, err.Error())
+			}
+		}()
+	}
+}
+
+func (it *mapperValidator) checkResultMap(id string, resultMap *etree.Element) {
+	for _, element := range resultMap.ChildElements() {
+		switch element.Tag {
+		case Element_Include:
+			it.checkResultMap(id, element)
+		case "id", "result":
+		case "association", "collection":
+			for _, child := range element.ChildElements() {
+				if child.Tag != "id" && child.Tag != "result" {
+					it.report(id, child, "unknown element <"+child.Tag+"> in <"+element.Tag+">")
+				}
+			}
+		default:
+			it.report(id, element, "unknown element <"+element.Tag+"> in <"+Element_ResultMap+">")
+		}
+	}
+}
+
+func (it *mapperValidator) checkStatement(id string, statement *etree.Element) {
+	if resultMap := statement.SelectAttrValue(Element_ResultMap, ""); resultMap != "" {
+		if _, ok := it.tree[resultMap]; !ok {
+			it.report(id, statement, "resultMap '"+resultMap+"' not define")
+		}
+	}
+	var errNum = len(it.diagnostics)
+	it.checkTokens(id, statement.Child)
+	if len(it.diagnostics) != errNum || it.engine.SqlBuilder() == nil {
+		return
+	}
+	//其余结构错误（例如多个<otherwise>、自定义元素解析失败）由NodeParser检查
+	func() {
+		defer func() {
+			if e := recover(); e != nil {
+				it.report(id, statement, fmt.Sprint(e))
+			}
+		}()
+		var parser = engineNodeParser(it.engine)
+		parser.Mapper = id
+		parser.Parser(statement.Child)
+	}()
+}
+
+func (it *mapperValidator) checkTokens(id string, tokens []etree.Token) {
+	for _, token := range tokens {
+		switch item := token.(type) {
+		case *etree.CharData:
+			for _, express := range ast.FindExpress(item.Data) {
+				it.checkExpression(id, item.Parent(), "#{"+express+"}", express)
+			}
+			for _, express := range ast.FindRawExpressString(item.Data) {
+				it.checkExpression(id, item.Parent(), "${"+express+"}", express)
+			}
+		case *etree.Element:
+			it.checkElement(id, item)
+		}
+	}
+}
+
+func (it *mapperValidator) checkElement(id string, element *etree.Element) {
+	switch element.Tag {
+	case Element_If, Element_when:
+		var test = element.SelectAttrValue("test", "")
+		if test == "" {
+			it.report(id, element, "<"+element.Tag+"> attribute 'test' can not be empty")
+		} else {
+			it.checkExpression(id, element, "<"+element.Tag+` test="`+test+`">`, test)
+		}
+	case Element_bind:
+		if element.SelectAttrValue("name", "") == "" {
+			it.report(id, element, "<bind> attribute 'name' can not be empty")
+		}
+		var value = element.SelectAttrValue("value", "")
+		if value == "" {
+			it.report(id, element, "<bind> attribute 'value' can not be empty")
+		} else {
+			it.checkExpression(id, element, `<bind value="`+value+`">`, value)
+		}
+	case Element_Foreach:
+		if element.SelectAttrValue("collection", "") == "" {
+			it.report(id, element, "<foreach> attribute 'collection' can not be empty")
+		}
+	case Element_Trim, Element_Set, Element_choose, Element_otherwise, Element_where, Element_Include:
+	default:
+		if !ast.IsBuiltinElement(element.Tag) && ast.GetNodeFactory(element.Tag) == nil {
+			it.report(id, element, "unknown element <"+element.Tag+">, custom element must register by ast.RegisterNode()")
+			return
+		}
+	}
+	it.checkTokens(id, element.Child)
+}
+
+//检查表达式能否被当前表达式引擎编译
+func (it *mapperValidator) checkExpression(id string, element *etree.Element, source string, express string) {
+	var engine = it.engine.ExpressionEngine()
+	if engine == nil {
+		return
+	}
+	//部分表达式引擎编译失败时会panic
+	defer func() {
+		if e := recover(); e != nil {
+			it.report(id, element, "expression "+source+" compile fail: "+fmt.Sprint(e))
+		}
+	}()
+	if _, err := engine.Lexer(express); err != nil {
+		it.report(id, element, "expression "+source+" compile fail: "+strings.Replace(err.Error(), "\n", " ", -1))
+	}
+}
+
+//检查mapper结构体的方法：xml方法是否存在、mapperParams个数、结构体参数个数、resultMap属性
+func (it *mapperValidator) checkMapperStruct(beanType reflect.Type) {
+	for i := 0; i < beanType.NumField(); i++ {
+		var field = beanType.Field(i)
+		if field.Type.Kind() != reflect.Func {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				it.checkMapperStruct(field.Type)
+			}
+			continue
+		}
+		if field.Name == NewSessionFunc {
+			continue
+		}
+		var id, statement = it.findStatement(field.Name)
+		if statement == nil {
+			if it.parseFail {
+				continue
+			}
+			it.diagnostics = append(it.diagnostics, Diagnostic{Element: field.Name, Message: "can not find method " + beanType.String() + "." + field.Name + "() in xml"})
+			continue
+		}
+		var mapperParams = field.Tag.Get("mapperParams")
+		if mapperParams != "" {
+			var names = strings.Split(mapperParams, ",")
+			if len(names) != field.Type.NumIn() {
+				it.report(id, statement, fmt.Sprintf(`%s.%s() tag mapperParams:"%s" has %d names but func has %d args`, beanType.String(), field.Name, mapperParams, len(names), field.Type.NumIn()))
+			}
+		} else if field.Type.NumIn() > 1 {
+			var customLen = 0
+			for argIndex := 0; argIndex < field.Type.NumIn(); argIndex++ {
+				if isCustomStruct(field.Type.In(argIndex)) {
+					customLen++
+				}
+			}
+			if customLen > 1 {
+				it.report(id, statement, beanType.String()+"."+field.Name+`() has more than one struct arg, must add tag mapperParams:"*,*..."`)
+			}
+		}
+		var resultMapId = statement.SelectAttrValue(Element_ResultMap, "")
+		if resultMapId == "" || field.Type.NumOut() < 2 {
+			continue
+		}
+		if resultMap, ok := it.tree[resultMapId].(*etree.Element); ok {
+			var resultType = validateResultStructType(field.Type.Out(0))
+			if resultType != nil && !it.checked[resultMapId+" "+(*resultType).String()] {
+				it.checked[resultMapId+" "+(*resultType).String()] = true
+				it.checkResultProperties(resultMapId, resultMap, *resultType, field.Name)
+			}
+		}
+	}
+}
+
+//同 findMapperXml，id 忽略大小写
+func (it *mapperValidator) findStatement(methodName string) (string, *etree.Element) {
+	for _, id := range it.sortedIds() {
+		var element = it.tree[id].(*etree.Element)
+		if isMethodElement(element.Tag) && strings.EqualFold(element.SelectAttrValue(ID, ""), methodName) {
+			return id, element
+		}
+	}
+	return "", nil
+}
+
+func (it *mapperValidator) checkResultProperties(id string, resultMap *etree.Element, resultType reflect.Type, methodName string) {
+	for _, element := range resultMap.ChildElements() {
+		if element.Tag == Element_Include {
+			it.checkResultProperties(id, element, resultType, methodName)
+			continue
+		}
+		var property = element.SelectAttrValue("property", "")
+		if property == "" {
+			continue
+		}
+		var field = findValidateField(resultType, property)
+		if field == nil {
+			it.report(id, element, "property '"+property+"' not find in "+resultType.String()+" (return type of "+methodName+"())")
+			continue
+		}
+		if element.Tag == "association" || element.Tag == "collection" {
+			var childType = validateResultStructType(*field)
+			if childType == nil {
+				continue
+			}
+			for _, child := range element.ChildElements() {
+				var childProperty = child.SelectAttrValue("property", "")
+				if childProperty != "" && findValidateField(*childType, childProperty) == nil {
+					it.report(id, child, "property '"+property+"."+childProperty+"' not find in "+resultType.String()+" (return type of "+methodName+"())")
+				}
+			}
+		}
+	}
+}
+
+//按 findPropertyField 的规则查找字段类型，找不到返回nil
+func findValidateField(structType reflect.Type, property string) *reflect.Type {
+	var field = findPropertyField(reflect.New(structType).Elem(), property)
+	if !field.IsValid() {
+		return nil
+	}
+	var fieldType = field.Type()
+	return &fieldType
+}
+
+//返回值（或切片、数组、指针元素）为自定义结构体时返回该结构体类型，否则返回nil
+func validateResultStructType(t reflect.Type) *reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || !isCustomStruct(t) {
+		return nil
+	}
+	return &t
+}
+
+//xml解析失败的诊断信息，带文件名与行号
+func xmlParseDiagnostic(file string, err error) Diagnostic {
+	var diagnostic = Diagnostic{File: file, Message: "xml parse fail: " + err.Error()}
+	if syntaxError, ok := err.(*xml.SyntaxError); ok {
+		diagnostic.Line = syntaxError.Line
+		diagnostic.Message = "xml parse fail: " + syntaxError.Msg
+	}
+	return diagnostic
+}
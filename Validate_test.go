@@ -0,0 +1,91 @@
+package GoMybatis
+
+import (
+	"strings"
+	"testing"
+)
+
+type validateTestActivity struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type ValidateTestMapper struct {
+	SelectByName func(name string, page int) ([]validateTestActivity, error) `mapperParams:"name"`
+	SelectById   func(id string) (validateTestActivity, error)               `mapperParams:"id"`
+	DeleteById   func(id string) (int64, error)                              `mapperParams:"id"`
+}
+
+func TestValidateMapper(t *testing.T) {
+	var engine = GoMybatisEngine{}.New()
+	var diagnostics = engine.Validate(&ValidateTestMapper{}, MapperFile{Name: "ValidateTestMapper.xml", Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <resultMap id="BaseResultMap">
+        <id column="id" property="id"/>
+        <result column="title" property="title"/>
+    </resultMap>
+    <select id="selectByName" resultMap="BaseResultMap">
+        select * from biz_activity
+        <where>
+            <if test="(name != nil">name = #{name}</if>
+            <iff test="true">and 1 = 1</iff>
+        </where>
+    </select>
+    <select id="selectById" resultMap="BaseResultMap">select * from biz_activity where id = #{id +}</select>
+    <select id="selectById">select 1</select>
+    <delete id="deleteById">delete from biz_activity where id = #{id}</delete>
+</mapper>`)})
+
+	var expects = []string{
+		"ValidateTestMapper.xml:5:9: [BaseResultMap] property 'title' not find",
+		"ValidateTestMapper.xml:7:5: [selectByName]",
+		"mapperParams:\"name\" has 1 names but func has 2 args",
+		"ValidateTestMapper.xml:10:13: [selectByName] expression <if test=\"(name != nil\"> compile fail",
+		"ValidateTestMapper.xml:11:13: [selectByName] unknown element <iff>",
+		"ValidateTestMapper.xml:14:5: [selectById] expression #{id +} compile fail",
+		"ValidateTestMapper.xml:15:5: [selectById] duplicate id 'selectById', first defined at line 14, column 5",
+	}
+	var all = []string{}
+	for _, diagnostic := range diagnostics {
+		all = append(all, diagnostic.String())
+	}
+	var text = strings.Join(all, "\n")
+	for _, expect := range expects {
+		if !strings.Contains(text, expect) {
+			t.Fatal("diagnostics must contain '"+expect+"', but got:\n", text)
+		}
+	}
+	if len(diagnostics) != 6 {
+		t.Fatal("diagnostics num must be 6, but got:\n", text)
+	}
+}
+
+func TestValidateMapper_Include(t *testing.T) {
+	var engine = GoMybatisEngine{}.New()
+	var diagnostics = engine.Validate(nil, MapperFile{Name: "a.xml", Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <sql id="a"><include refid="b"/></sql>
+    <sql id="b"><include refid="a"/></sql>
+    <select id="selectAll">select * from t <include refid="missing"/></select>
+    <update id="update">update t set name = 1</update>
+</mapper>`)}, MapperFile{Name: "b.xml", Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <update id="update">update t set name = 2</update>
+    <cache/>
+</mapper>`)})
+	var all = []string{}
+	for _, diagnostic := range diagnostics {
+		all = append(all, diagnostic.String())
+	}
+	var text = strings.Join(all, "\n")
+	for _, expect := range []string{
+		"a.xml:4:17: [a] [GoMybatis] xml <include refid=\"a\"> cycle reference: a -> b -> a",
+		"a.xml:5:44: [selectAll] [GoMybatis] xml <include refid=\"missing\"> element can not find",
+		"b.xml:3:5: [update] duplicate id 'update', first defined at line 6, column 5 of a.xml",
+		"b.xml:4:5: [cache] unknown element <cache> in <mapper>",
+	} {
+		if !strings.Contains(text, expect) {
+			t.Fatal("diagnostics must contain '"+expect+"', but got:\n", text)
+		}
+	}
+}
@@ -1,90 +1,340 @@
 package GoMybatis
 
 import (
+	"encoding/xml"
+	"errors"
 	"reflect"
+	"sort"
+	"strings"
 
+	"github.com/zhuxiujia/GoMybatis/ast"
 	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
 	"github.com/zhuxiujia/GoMybatis/utils"
 )
 
 const Element_Mapper = "mapper"
 const ID = `id`
+const Namespace = `namespace`
 
 func LoadMapperXml(bytes []byte) (items map[string]etree.Token) {
 	return LoadMapperXmlNew(bytes, true)
 }
 
 func LoadMapperXmlNew(bytes []byte, processInclude bool) (items map[string]etree.Token) {
+	return LoadMapperXmlFile(MapperFile{Data: bytes}, processInclude)
+}
+
+//同 LoadMapperXmlNew，file.Name 用于解析错误与元素位置信息（例如 "ActivityMapper.xml:12"）
+func LoadMapperXmlFile(file MapperFile, processInclude bool) (items map[string]etree.Token) {
+	var namespace string
+	items, namespace = loadMapperXml(file.Data, file.Name)
+	if processInclude {
+		var refs = newIncludeRefs()
+		for k, v := range items {
+			refs.add(namespace, k, v.(*etree.Element))
+		}
+		if err := processIncludeElementByRefs(items, refs); err != nil {
+			panic(err)
+		}
+	}
+	return items
+}
+
+//解析xml，返回 map[id]元素 和 <mapper namespace="">，file为xml文件名，可为空
+func loadMapperXml(bytes []byte, file string) (items map[string]etree.Token, namespace string) {
 	utils.FixTestExpressionSymbol(&bytes)
 	doc := etree.NewDocument()
 	if err := doc.ReadFromBytes(bytes); err != nil {
-		panic(err)
+		panic(errors.New("[GoMybatis] " + xmlParseDiagnostic(file, err).String()))
 	}
+	recordXmlPositions(bytes, doc, file)
 	items = make(map[string]etree.Token)
 	root := doc.SelectElement(Element_Mapper)
+	namespace = root.SelectAttrValue(Namespace, "")
+	normalizeDatabaseIds(root)
+	//同id的元素，带databaseId时合并为一个元素（见 mergeDatabaseIdElements）
+	var ids = []string{}
+	var groups = make(map[string][]*etree.Element)
 	for _, s := range root.ChildElements() {
-		if s.Tag == Element_Insert ||
-			s.Tag == Element_Delete ||
-			s.Tag == Element_Update ||
-			s.Tag == Element_Select ||
-			s.Tag == Element_ResultMap ||
-			s.Tag == Element_Sql ||
-			s.Tag == Element_Insert_Templete ||
-			s.Tag == Element_Delete_Templete ||
-			s.Tag == Element_Update_Templete ||
-			s.Tag == Element_Select_Templete {
+		if isMapperElement(s.Tag) {
 			var elementID = s.SelectAttrValue(ID, "")
 
 			if elementID == "" {
 				//如果id不存在，id设置为tag
 				elementID = s.Tag
 			}
-			if elementID != "" {
-				var oldItem = items[elementID]
-				if oldItem != nil {
-					panic("[GoMybatis] element Id can not repeat in xml! elementId=" + elementID)
+			if groups[elementID] == nil {
+				ids = append(ids, elementID)
+			}
+			groups[elementID] = append(groups[elementID], s)
+		}
+	}
+	for _, elementID := range ids {
+		var elements = groups[elementID]
+		if !hasDatabaseId(elements) {
+			if len(elements) > 1 {
+				panic("[GoMybatis] element Id can not repeat in xml! elementId=" + elementID)
+			}
+			items[elementID] = elements[0]
+			continue
+		}
+		var merged, err = mergeDatabaseIdElements(elements)
+		if err != nil {
+			panic(err)
+		}
+		items[elementID] = merged
+	}
+	return items, namespace
+}
+
+//<mapper>下可定义的元素
+func isMapperElement(tag string) bool {
+	switch tag {
+	case Element_ResultMap, Element_Sql:
+		return true
+	}
+	return isMethodElement(tag)
+}
+
+//记录每个元素在xml中的行列号（见 ast.PositionOf），用于错误提示。
+//etree不保留位置信息，这里用encoding/xml再解析一遍，两者的元素顺序一致
+func recordXmlPositions(bytes []byte, doc *etree.Document, file string) {
+	var elements = []*etree.Element{}
+	var walk func(element *etree.Element)
+	walk = func(element *etree.Element) {
+		elements = append(elements, element)
+		for _, child := range element.ChildElements() {
+			walk(child)
+		}
+	}
+	for _, element := range doc.ChildElements() {
+		walk(element)
+	}
+	var decoder = xml.NewDecoder(strings.NewReader(string(bytes)))
+	var index = 0
+	var line = 1
+	var lineStart = 0 //当前行起始偏移
+	var scanned = 0   //已统计换行的偏移
+	for index < len(elements) {
+		var offset = int(decoder.InputOffset())
+		var token, err = decoder.RawToken()
+		if err != nil {
+			return
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			for ; scanned < offset; scanned++ {
+				if bytes[scanned] == '\n' {
+					line++
+					lineStart = scanned + 1
 				}
 			}
-			items[elementID] = s
+			ast.SetPosition(elements[index], ast.Position{File: file, Line: line, Column: offset - lineStart + 1})
+			index++
 		}
 	}
-	if processInclude {
-		processIncludeElement(&items)
+}
+
+func processIncludeElement(xmlMap *map[string]etree.Token) {
+	var refs = newIncludeRefs()
+	for k, v := range *xmlMap {
+		refs.add("", k, v.(*etree.Element))
 	}
-	return items
+	if err := processIncludeElementByRefs(*xmlMap, refs); err != nil {
+		panic(err)
+	}
+}
+
+//include refid查找表。<mapper namespace="">中的元素以 namespace.id 注册，
+//不带namespace的refid先在引用所在的namespace中查找，再查找全部xml中唯一的同id元素，存在多个时报错
+type includeRefs struct {
+	elements   map[string]*etree.Element //namespace.id（xml没有namespace时为id）
+	ids        map[string][]string       //id -> 同id元素的key
+	namespaces map[*etree.Element]string //元素所在的namespace
+}
+
+func newIncludeRefs() *includeRefs {
+	return &includeRefs{
+		elements:   map[string]*etree.Element{},
+		ids:        map[string][]string{},
+		namespaces: map[*etree.Element]string{},
+	}
+}
+
+//注册元素，同一namespace（或都没有namespace）中id重复时返回false
+func (it *includeRefs) add(namespace string, id string, element *etree.Element) bool {
+	var key = id
+	if namespace != "" {
+		key = namespace + "." + id
+	}
+	if it.elements[key] != nil {
+		return false
+	}
+	it.elements[key] = element
+	it.ids[id] = append(it.ids[id], key)
+	it.namespaces[element] = namespace
+	return true
+}
+
+//查找refid对应元素的key，namespace为引用所在的namespace；找不到返回""，
+//不带namespace的refid在多个xml中都有定义时返回这些元素的key
+func (it *includeRefs) find(namespace string, refid string) (string, []string) {
+	if namespace != "" && it.elements[namespace+"."+refid] != nil {
+		return namespace + "." + refid, nil
+	}
+	if it.elements[refid] != nil {
+		return refid, nil
+	}
+	var keys = it.ids[refid]
+	if len(keys) > 1 {
+		var ambiguous = append([]string{}, keys...)
+		sort.Strings(ambiguous)
+		return "", ambiguous
+	}
+	if len(keys) == 1 {
+		return keys[0], nil
+	}
+	return "", nil
+}
+
+//xml元素相关的错误，错误信息带元素位置
+type xmlElementError struct {
+	element *etree.Element
+	message string
 }
 
-func processIncludeElement(xmlMap *map[string]etree.Token)  {
-	for _, mapperXml := range *xmlMap {
+func (it *xmlElementError) Error() string {
+	return it.message + xmlPositionString(it.element)
+}
+
+//展开items中的 <include>，refs 为 refid 查找表
+func processIncludeElementByRefs(items map[string]etree.Token, refs *includeRefs) error {
+	//include 引用未展开的原始片段，展开结果与处理顺序无关
+	var sources = make(map[string]*etree.Element, len(refs.elements))
+	for k, v := range refs.elements {
+		sources[k] = copyIncludeElement(v, nil)
+	}
+	//按id顺序展开，错误信息稳定
+	var ids = make([]string, 0, len(items))
+	for k := range items {
+		ids = append(ids, k)
+	}
+	sort.Strings(ids)
+	for _, k := range ids {
+		var mapperXml = items[k]
 		var typeString = reflect.TypeOf(mapperXml).String()
 		if typeString == "*etree.Element" {
 			var el = mapperXml.(*etree.Element)
+			var namespace = refs.namespaces[el]
+			var key = k
+			if namespace != "" {
+				key = namespace + "." + k
+			}
 			for _, v := range el.ChildElements() {
-				includeElementReplace(v, xmlMap)
+				if err := includeElementReplace(v, refs, sources, namespace, nil, []string{key}); err != nil {
+					return err
+				}
 			}
 		}
 	}
+	return nil
 }
 
-func includeElementReplace(xml *etree.Element, xmlMap *map[string]etree.Token) {
+//展开 <include refid=""><property name="" value=""/></include>，片段中的 ${name} 替换为property的值。
+//namespace:当前元素所在的namespace，properties:外层include传入的属性，refStack:正在展开的refid，用于检查循环引用
+func includeElementReplace(xml *etree.Element, refs *includeRefs, sources map[string]*etree.Element, namespace string, properties map[string]string, refStack []string) error {
 	if xml.Tag == Element_Include {
-		var refid = xml.SelectAttr("refid").Value
+		var refid = replaceIncludeProperties(xml.SelectAttrValue("refid", ""), properties)
 		if refid == "" {
-			panic(`[GoMybatis] xml <include refid=""> 'refid' can not be ""`)
+			return &xmlElementError{element: xml, message: `[GoMybatis] xml <include refid=""> 'refid' can not be ""`}
+		}
+		var key, ambiguous = refs.find(namespace, refid)
+		if len(ambiguous) != 0 {
+			return &xmlElementError{element: xml, message: `[GoMybatis] xml <include refid="` + refid + `"> is ambiguous, use one of: ` + strings.Join(ambiguous, ", ")}
 		}
-		var mapperXml = (*xmlMap)[refid]
-		if mapperXml == nil {
-			panic(`[GoMybatis] xml <include refid="` + refid + `"> element can not find !`)
+		if key == "" {
+			return &xmlElementError{element: xml, message: `[GoMybatis] xml <include refid="` + refid + `"> element can not find !`}
 		}
-		if xml != nil {
-			(*xml).Child = mapperXml.(*etree.Element).Child
+		for _, item := range refStack {
+			if item == key {
+				return &xmlElementError{element: xml, message: `[GoMybatis] xml <include refid="` + refid + `"> cycle reference: ` + strings.Join(append(refStack, refid), " -> ")}
+			}
 		}
+		var mapperXml = sources[key]
+		//外层属性可被当前include的同名属性覆盖
+		var includeProperties = make(map[string]string, len(properties))
+		for k, v := range properties {
+			includeProperties[k] = v
+		}
+		for _, property := range xml.SelectElements("property") {
+			var name = property.SelectAttrValue("name", "")
+			if name == "" {
+				return &xmlElementError{element: property, message: `[GoMybatis] xml <include refid="` + refid + `"> <property name=""> 'name' can not be ""`}
+			}
+			includeProperties[name] = replaceIncludeProperties(property.SelectAttrValue("value", ""), properties)
+		}
+		var fragment = copyIncludeElement(mapperXml, includeProperties)
+		xml.Child = []etree.Token{}
+		for _, child := range fragment.Child {
+			xml.AddChild(child)
+		}
+		//片段中的refid在片段所在的namespace中查找
+		var fragmentNamespace = refs.namespaces[refs.elements[key]]
+		var stack = append(refStack[:len(refStack):len(refStack)], key)
+		for _, v := range xml.ChildElements() {
+			if err := includeElementReplace(v, refs, sources, fragmentNamespace, includeProperties, stack); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 	if xml.Child != nil {
 		for _, v := range xml.ChildElements() {
-			includeElementReplace(v, xmlMap)
+			if err := includeElementReplace(v, refs, sources, namespace, properties, refStack); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
+
+//复制元素（保留位置信息），文本和属性值中的 ${name} 替换为properties中的值。注释等不参与构建sql的节点不复制
+func copyIncludeElement(src *etree.Element, properties map[string]string) *etree.Element {
+	var element = etree.NewElement(src.Tag)
+	element.Space = src.Space
+	for _, attr := range src.Attr {
+		element.Attr = append(element.Attr, etree.Attr{Space: attr.Space, Key: attr.Key, Value: replaceIncludeProperties(attr.Value, properties)})
+	}
+	for _, child := range src.Child {
+		switch token := child.(type) {
+		case *etree.Element:
+			element.AddChild(copyIncludeElement(token, properties))
+		case *etree.CharData:
+			element.CreateCharData(replaceIncludeProperties(token.Data, properties))
+		}
+	}
+	if position, ok := ast.PositionOf(src); ok {
+		ast.SetPosition(element, position)
+	}
+	return element
+}
+
+//只替换已定义的属性，其余 ${} 保留给执行期处理
+func replaceIncludeProperties(text string, properties map[string]string) string {
+	if len(properties) == 0 || !strings.Contains(text, "${") {
+		return text
+	}
+	for k, v := range properties {
+		text = strings.Replace(text, "${"+k+"}", v, -1)
+	}
+	return text
+}
+
+//元素位置描述，例如 " at line 12, column 9"，没有位置返回""
+func xmlPositionString(element *etree.Element) string {
+	if position, ok := ast.PositionOf(element); ok {
+		return " at " + position.String()
+	}
+	return ""
 }
 
 ////标签上下级关系检查
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -23,3 +24,138 @@ func Test_Load_Xml(t *testing.T) {
 	time.Sleep(time.Second)
 	fmt.Println(xmlItems)
 }
+
+type IncludeTestMapper struct {
+	SelectJoin func(session *Session, name string) ([]map[string]string, error) `mapperParams:"session,name"`
+}
+
+func TestInclude_Property(t *testing.T) {
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper IncludeTestMapper
+	engine.WriteMapperPtr(&mapper, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper namespace="common">
+    <sql id="BaseColumns">${alias}.id,${alias}.name</sql>
+    <sql id="Columns"><include refid="BaseColumns"><property name="alias" value="${prefix}"/></include>,${prefix}.remark</sql>
+</mapper>`), []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectJoin">
+        select <include refid="common.Columns"><property name="prefix" value="a"/></include>,
+        <include refid="common.BaseColumns"><property name="alias" value="b"/></include>
+        from biz_activity a join biz_activity b on a.id = b.id where a.name = #{name}
+    </select>
+</mapper>`))
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	mapper.SelectJoin(&session, "a")
+	var sql = compactSql(capture.LastSql())
+	if !strings.HasPrefix(sql, compactSql("select a.id,a.name,a.remark,b.id,b.name from")) {
+		t.Fatal("include property fail:", capture.LastSql())
+	}
+}
+
+func TestInclude_Cycle(t *testing.T) {
+	defer func() {
+		var e = recover()
+		if e == nil || !strings.Contains(fmt.Sprint(e), "A -> B -> A") {
+			t.Fatal("include cycle must panic,", e)
+		}
+	}()
+	LoadMapperXml([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <sql id="A">a <include refid="B"/></sql>
+    <sql id="B">b <include refid="A"/></sql>
+</mapper>`))
+}
+
+type IncludeNamespaceTestMapper struct {
+	SelectA func(session *Session) ([]map[string]string, error) `mapperParams:"session"`
+	SelectB func(session *Session) ([]map[string]string, error) `mapperParams:"session"`
+}
+
+func TestInclude_Namespace(t *testing.T) {
+	var xmlA = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper namespace="a">
+    <sql id="Columns">id,name</sql>
+    <select id="selectA">select <include refid="Columns"/> from biz_activity</select>
+</mapper>`)
+	var xmlB = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper namespace="b">
+    <sql id="Columns">id,remark</sql>
+    <select id="selectB">select <include refid="Columns"/> from biz_activity</select>
+</mapper>`)
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper IncludeNamespaceTestMapper
+	engine.WriteMapperPtr(&mapper, xmlA, xmlB)
+	var capture = CaptureSession{}
+	var session = Session(&capture)
+	mapper.SelectA(&session)
+	if compactSql(capture.LastSql()) != compactSql("select id,name from biz_activity") {
+		t.Fatal("refid must resolve in the same namespace first:", capture.LastSql())
+	}
+	mapper.SelectB(&session)
+	if compactSql(capture.LastSql()) != compactSql("select id,remark from biz_activity") {
+		t.Fatal("refid must resolve in the same namespace first:", capture.LastSql())
+	}
+
+	//没有namespace的xml引用多个namespace中的同id片段
+	func() {
+		defer func() {
+			if e := recover(); e == nil || !strings.Contains(fmt.Sprint(e), "ambiguous") {
+				t.Fatal("ambiguous refid must panic,", e)
+			}
+		}()
+		var mapper IncludeNamespaceTestMapper
+		engine.WriteMapperPtr(&mapper, xmlA, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper namespace="b">
+    <sql id="Columns">id,remark</sql>
+</mapper>`), []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectB">select <include refid="Columns"/> from biz_activity</select>
+</mapper>`))
+	}()
+
+	//语句id不能跨xml重复
+	defer func() {
+		if e := recover(); e == nil || !strings.Contains(fmt.Sprint(e), "repeat") {
+			t.Fatal("duplicate statement id must panic,", e)
+		}
+	}()
+	engine.WriteMapperPtr(&mapper, xmlA, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper namespace="c">
+    <select id="selectA">select 1</select>
+</mapper>`))
+}
+
+func TestLoadMapperXml_FileName(t *testing.T) {
+	//xml语法错误带文件名与行号
+	func() {
+		defer func() {
+			if e := recover(); e == nil || !strings.Contains(fmt.Sprint(e), "ActivityMapper.xml:3") {
+				t.Fatal("parse error must contain file name and line,", e)
+			}
+		}()
+		LoadMapperXmlFile(MapperFile{Name: "ActivityMapper.xml", Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id=selectA>select 1</select>
+</mapper>`)}, true)
+	}()
+
+	//构建期错误的元素位置带文件名
+	defer func() {
+		if e := recover(); e == nil || !strings.Contains(fmt.Sprint(e), "of B.xml") {
+			t.Fatal("element position must contain file name,", e)
+		}
+	}()
+	var engine = GoMybatisEngine{}.New()
+	engine.SetLogEnable(false)
+	var mapper IncludeNamespaceTestMapper
+	engine.WriteMapperFilePtr(&mapper, MapperFile{Name: "A.xml", Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectA">select 1</select>
+</mapper>`)}, MapperFile{Name: "B.xml", Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<mapper>
+    <select id="selectA">select 2</select>
+</mapper>`)})
+}
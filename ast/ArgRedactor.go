@@ -0,0 +1,50 @@
+package ast
+
+import "database/sql/driver"
+
+//日志中脱敏参数的显示值
+const RedactMask = "***"
+
+//#{} 参数的日志脱敏判断
+type ArgRedactor interface {
+	//express为 #{} 中的表达式，options为逗号之后的选项（例如 redact=true），env为sql构建参数；返回true时参数在日志中显示为 ***
+	Redact(express string, options string, env map[string]interface{}) bool
+}
+
+//需要在日志中脱敏的参数，执行前由框架还原为 Arg；直接交给 database/sql 时按 Arg 转换
+type RedactedArg struct {
+	Arg interface{}
+}
+
+func (it RedactedArg) String() string {
+	return RedactMask
+}
+
+func (it RedactedArg) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + RedactMask + `"`), nil
+}
+
+func (it RedactedArg) Value() (driver.Value, error) {
+	return driver.DefaultParameterConverter.ConvertValue(it.Arg)
+}
+
+//拆分为执行用的参数（还原脱敏参数）和日志用的参数（脱敏参数为 ***），没有脱敏参数时两者相同
+func SplitRedactedArgs(args []interface{}) ([]interface{}, []interface{}) {
+	var execArgs, logArgs []interface{}
+	for i, arg := range args {
+		var redacted, ok = arg.(RedactedArg)
+		if !ok {
+			continue
+		}
+		if execArgs == nil {
+			execArgs = append([]interface{}{}, args...)
+			logArgs = append([]interface{}{}, args...)
+		}
+		execArgs[i] = redacted.Arg
+		logArgs[i] = RedactMask
+	}
+	if execArgs == nil {
+		return args, args
+	}
+	return execArgs, logArgs
+}
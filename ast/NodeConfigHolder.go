@@ -2,8 +2,10 @@ package ast
 
 
 type NodeConfigHolder struct {
-	Convert SqlArgTypeConvert
-	Proxy   ExpressionEngine
+	Convert  SqlArgTypeConvert
+	Proxy    ExpressionEngine
+	RawGuard RawArgGuard //${}检查器，为nil时不检查
+	Redactor ArgRedactor //#{}参数日志脱敏，为nil时不脱敏
 }
 
 func (it *NodeConfigHolder) GetSqlArgTypeConvert() SqlArgTypeConvert {
@@ -13,3 +15,11 @@ func (it *NodeConfigHolder) GetSqlArgTypeConvert() SqlArgTypeConvert {
 func (it *NodeConfigHolder) GetExpressionEngineProxy() ExpressionEngine {
 	return it.Proxy
 }
+
+func (it *NodeConfigHolder) GetRawArgGuard() RawArgGuard {
+	return it.RawGuard
+}
+
+func (it *NodeConfigHolder) GetArgRedactor() ArgRedactor {
+	return it.Redactor
+}
@@ -0,0 +1,28 @@
+package ast
+
+import "strings"
+
+//表达式中的数据库厂商变量，值为执行session的数据库（例如 mysql、sqlite），例如 <if test="_databaseId == 'mysql'">
+const DatabaseIdVar = "_databaseId"
+
+//元素的 databaseId 属性，例如 <if test="name != nil" databaseId="mysql">
+const DatabaseIdAttr = "databaseId"
+
+//databaseId 节点，包装带 databaseId 属性的元素，执行期的数据库与之相同时才输出
+type NodeDatabaseId struct {
+	node       Node
+	databaseId string
+}
+
+//节点类型与被包装的节点相同，<choose>中的<when databaseId="">仍作为when处理
+func (it *NodeDatabaseId) Type() NodeType {
+	return it.node.Type()
+}
+
+func (it *NodeDatabaseId) Eval(env map[string]interface{}, arg_array *[]interface{}) ([]byte, error) {
+	var databaseId, _ = env[DatabaseIdVar].(string)
+	if !strings.EqualFold(databaseId, it.databaseId) {
+		return nil, nil
+	}
+	return it.node.Eval(env, arg_array)
+}
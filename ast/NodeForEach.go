@@ -2,10 +2,22 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/zhuxiujia/GoMybatis/utils"
 )
 
+//foreach 迭代器，collection的值实现该接口时逐个读取，直到ok为false
+type Iterator interface {
+	Next() (item interface{}, ok bool)
+}
+
 //foreach 节点
+//collection为表达式，例如 ids、query.Ids；支持slice、array、map（按key排序，保证sql稳定）、chan（读取到关闭）、Iterator。
+//嵌套foreach时，循环结束后恢复外层的 item、index
 type NodeForEach struct {
 	childs []Node
 	t      NodeType
@@ -16,6 +28,9 @@ type NodeForEach struct {
 	open       string
 	close      string
 	separator  string
+	location   string //元素位置描述（见 NodeParser.location），用于错误提示
+
+	holder *NodeConfigHolder
 }
 
 func (it *NodeForEach) Type() NodeType {
@@ -24,81 +39,152 @@ func (it *NodeForEach) Type() NodeType {
 
 func (it *NodeForEach) Eval(env map[string]interface{}, arg_array *[]interface{}) ([]byte, error) {
 	if it.collection == "" {
-		panic(`[GoMybatis] collection value can not be "" in <foreach collection=""> !`)
+		return nil, utils.NewError("GoMybatisSqlBuilder", `[GoMybatis] collection value can not be "" in <foreach collection=""> !`)
 	}
-	var tempSql bytes.Buffer
-	var datas = env[it.collection]
-	var collectionValue = reflect.ValueOf(datas)
-	if collectionValue.Kind() != reflect.Slice && collectionValue.Kind() != reflect.Map {
-		panic(`[GoMybatis] collection value must be a slice or map !`)
+	var datas, err = it.evalCollection(env)
+	if err != nil {
+		return nil, err
 	}
-	var collectionValueLen = collectionValue.Len()
-	if collectionValueLen == 0 {
-		return nil, nil
-	}
-	if it.index == "" {
-		it.index = "index"
+	var index = it.index
+	if index == "" {
+		index = "index"
 	}
-	if it.item == "" {
-		it.item = "item"
+	var item = it.item
+	if item == "" {
+		item = "item"
 	}
-	switch collectionValue.Kind() {
-	case reflect.Map:
-		var mapKeys = collectionValue.MapKeys()
-		var collectionKeyLen = len(mapKeys)
-		if collectionKeyLen == 0 {
-			return nil, nil
+	//保存外层同名变量，循环结束后恢复
+	var oldItem, hasItem = env[item]
+	var oldIndex, hasIndex = env[index]
+	defer func() {
+		restoreEnv(env, item, oldItem, hasItem)
+		restoreEnv(env, index, oldIndex, hasIndex)
+	}()
+
+	var tempSql bytes.Buffer
+	var count = 0
+	var each = func(key interface{}, value interface{}) error {
+		env[item] = value
+		env[index] = key
+		var r, err = DoChildNodes(it.childs, env, arg_array)
+		if err != nil {
+			return err
 		}
-		var tempArgMap = env
-		for _, keyValue := range mapKeys {
-			var key = keyValue.Interface()
-			var collectionItem = collectionValue.MapIndex(keyValue)
-			if it.item != "" {
-				tempArgMap[it.item] = collectionItem.Interface()
+		if count > 0 {
+			tempSql.WriteString(it.separator)
+		}
+		tempSql.Write(r)
+		count++
+		return nil
+	}
+	if iterator, ok := datas.(Iterator); ok {
+		for i := 0; ; i++ {
+			var value, ok = iterator.Next()
+			if !ok {
+				break
 			}
-			tempArgMap[it.index] = key
-			var r, err = DoChildNodes(it.childs, tempArgMap, arg_array)
-			if err != nil {
+			if err := each(i, value); err != nil {
 				return nil, err
 			}
-			if r != nil {
-				tempSql.Write(r)
-			}
-			tempSql.WriteString(it.separator)
-			delete(tempArgMap, it.item)
 		}
-		break
-	case reflect.Slice:
-		var tempArgMap = env
-		for i := 0; i < collectionValueLen; i++ {
-			var collectionItem = collectionValue.Index(i)
-			if it.item != "" {
-				tempArgMap[it.item] = collectionItem.Interface()
-			}
-			if it.index != "" {
-				tempArgMap[it.index] = i
+	} else {
+		var collectionValue = reflect.ValueOf(datas)
+		for collectionValue.Kind() == reflect.Ptr || collectionValue.Kind() == reflect.Interface {
+			collectionValue = collectionValue.Elem()
+		}
+		switch collectionValue.Kind() {
+		case reflect.Invalid:
+			//集合不存在或为nil，通常是collection拼写错误，明确的空集合才输出空sql
+			return nil, utils.NewError("GoMybatisSqlBuilder", "[GoMybatis] <foreach collection=\""+it.collection+"\"> value can not be nil"+it.location+" !")
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < collectionValue.Len(); i++ {
+				if err := each(i, collectionValue.Index(i).Interface()); err != nil {
+					return nil, err
+				}
 			}
-			var r, err = DoChildNodes(it.childs, tempArgMap, arg_array)
-			if err != nil {
-				return nil, err
+		case reflect.Map:
+			for _, keyValue := range sortMapKeys(collectionValue.MapKeys()) {
+				if err := each(keyValue.Interface(), collectionValue.MapIndex(keyValue).Interface()); err != nil {
+					return nil, err
+				}
 			}
-			if r != nil {
-				tempSql.Write(r)
+		case reflect.Chan:
+			for i := 0; ; i++ {
+				var value, ok = collectionValue.Recv()
+				if !ok {
+					break
+				}
+				if err := each(i, value.Interface()); err != nil {
+					return nil, err
+				}
 			}
-			tempSql.WriteString(it.separator)
-			delete(tempArgMap, it.item)
+		default:
+			return nil, utils.NewError("GoMybatisSqlBuilder", "[GoMybatis] <foreach collection=\""+it.collection+"\"> value must be a slice, array, map, chan or ast.Iterator, but got "+collectionValue.Type().String()+" !")
 		}
-		break
+	}
+	if count == 0 {
+		return nil, nil
 	}
 	var newTempSql bytes.Buffer
-	// bytes.Trim(s []byte, cutset string)是从cutset中匹配任意一个字符并截断，在separator含多个字符时会出现问题
-	var tempSqlString = bytes.TrimPrefix(tempSql.Bytes(), ([]byte)(it.separator))
-	tempSqlString = bytes.TrimSuffix(tempSqlString, ([]byte)(it.separator))
-	tempSql.Reset()
 	newTempSql.WriteString(it.open)
-	newTempSql.Write(tempSqlString)
+	newTempSql.Write(tempSql.Bytes())
 	newTempSql.WriteString(it.close)
-	var newTempSqlBytes = newTempSql.Bytes()
-	newTempSql.Reset()
-	return newTempSqlBytes, nil
+	return newTempSql.Bytes(), nil
+}
+
+//读取collection：优先按参数名查找，否则作为表达式求值
+func (it *NodeForEach) evalCollection(env map[string]interface{}) (interface{}, error) {
+	if value, ok := env[it.collection]; ok {
+		return value, nil
+	}
+	if it.holder == nil || it.holder.GetExpressionEngineProxy() == nil {
+		return nil, utils.NewError("GoMybatisSqlBuilder", "[GoMybatis] <foreach collection=\""+it.collection+"\"> not find arg and no expression engine to eval it"+it.location+" !")
+	}
+	var expression = strings.TrimSpace(strings.ReplaceAll(it.collection, "\n", " "))
+	var result, err = it.holder.GetExpressionEngineProxy().LexerAndEval(expression, env)
+	if err != nil {
+		return nil, utils.NewError("GoMybatisSqlBuilder", "[GoMybatis] <foreach collection=\""+it.collection+"\"> fail,"+err.Error())
+	}
+	return result, nil
+}
+
+func restoreEnv(env map[string]interface{}, key string, value interface{}, exist bool) {
+	if exist {
+		env[key] = value
+	} else {
+		delete(env, key)
+	}
+}
+
+//map的key排序：数字按大小，字符串按字典序，其他类型按 fmt.Sprint 结果
+func sortMapKeys(keys []reflect.Value) []reflect.Value {
+	sort.SliceStable(keys, func(i, j int) bool {
+		var a, b = keys[i], keys[j]
+		for a.Kind() == reflect.Interface || a.Kind() == reflect.Ptr {
+			if a.IsNil() {
+				break
+			}
+			a = a.Elem()
+		}
+		for b.Kind() == reflect.Interface || b.Kind() == reflect.Ptr {
+			if b.IsNil() {
+				break
+			}
+			b = b.Elem()
+		}
+		if a.Kind() == b.Kind() {
+			switch a.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return a.Int() < b.Int()
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				return a.Uint() < b.Uint()
+			case reflect.Float32, reflect.Float64:
+				return a.Float() < b.Float()
+			case reflect.String:
+				return a.String() < b.String()
+			}
+		}
+		return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+	})
+	return keys
 }
@@ -26,10 +26,14 @@ func (it *NodeIf) Eval(env map[string]interface{}, arg_array *[]interface{}) ([]
 	var test = strings.ReplaceAll(it.test, "\n", " ")
 	var result, err = it.holder.GetExpressionEngineProxy().LexerAndEval(test, env)
 	if err != nil {
-		err = utils.NewError("GoMybatisSqlBuilder", "[GoMybatis] <test `", it.test, "`> fail,", err.Error())
+		return nil, utils.NewError("GoMybatisSqlBuilder", "[GoMybatis] <test `", it.test, "`> fail,", err.Error())
 	}
-	if result.(bool) {
+	var ok, isBool = result.(bool)
+	if !isBool {
+		return nil, utils.NewError("GoMybatisSqlBuilder", "[GoMybatis] <test `", it.test, "`> result must be a bool!")
+	}
+	if ok {
 		return DoChildNodes(it.childs, env, arg_array)
 	}
-	return nil, err
+	return nil, nil
 }
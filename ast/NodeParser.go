@@ -13,6 +13,7 @@ const DefaultWhereElement_PrefixOverrides = "and |or |And |Or |AND |OR "
 //节点解析器
 type NodeParser struct {
 	Holder NodeConfigHolder
+	Mapper string //正在解析的mapper方法（例如 ExampleActivityMapper.SelectAll），用于错误提示
 }
 
 //解析为node
@@ -41,6 +42,8 @@ func (it NodeParser) Parser(mapperXml []etree.Token) []Node {
 			}
 			if len(n.expressMap) == 0 {
 				n.expressMap = nil
+			} else {
+				n.expressOptions = FindExpressOptions(charData.Data)
 			}
 			node = &n
 		} else if typeString == "*etree.Element" {
@@ -101,6 +104,8 @@ func (it NodeParser) Parser(mapperXml []etree.Token) []Node {
 					open:       v.SelectAttrValue("open", ""),
 					close:      v.SelectAttrValue("close", ""),
 					separator:  v.SelectAttrValue("separator", ""),
+					location:   it.location(v),
+					holder:     &it.Holder,
 				}
 				if childItems != nil {
 					var childNodes = it.Parser(childItems)
@@ -193,7 +198,25 @@ func (it NodeParser) Parser(mapperXml []etree.Token) []Node {
 				}
 				node = &n
 			default:
-				continue
+				var factory = GetNodeFactory(v.Tag)
+				if factory == nil {
+					panic("[GoMybatis] unknown element <" + v.Tag + ">" + it.location(v) + "! custom element must register by ast.RegisterNode()")
+				}
+				var childNodes []Node
+				if childItems != nil {
+					childNodes = it.Parser(childItems)
+				}
+				var n, err = factory(v, childNodes, &it.Holder)
+				if err != nil {
+					panic("[GoMybatis] element <" + v.Tag + ">" + it.location(v) + " parse fail: " + err.Error())
+				}
+				if n == nil {
+					continue
+				}
+				node = n
+			}
+			if databaseId := v.SelectAttrValue(DatabaseIdAttr, ""); databaseId != "" && node != nil {
+				node = &NodeDatabaseId{node: node, databaseId: databaseId}
 			}
 		} else {
 			continue
@@ -205,3 +228,15 @@ func (it NodeParser) Parser(mapperXml []etree.Token) []Node {
 	}
 	return nodes
 }
+
+//元素位置描述，例如 " in mapper 'ExampleActivityMapper.SelectAll' at line 12, column 9"
+func (it NodeParser) location(element *etree.Element) string {
+	var location = ""
+	if it.Mapper != "" {
+		location += " in mapper '" + it.Mapper + "'"
+	}
+	if position, ok := PositionOf(element); ok {
+		location += " at " + position.String()
+	}
+	return location
+}
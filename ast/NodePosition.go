@@ -0,0 +1,51 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
+)
+
+//元素在xml中的位置（行列从1开始）
+type Position struct {
+	File   string //xml文件名，未知为""
+	Line   int
+	Column int
+}
+
+func (it Position) String() string {
+	var s = "line " + strconv.Itoa(it.Line) + ", column " + strconv.Itoa(it.Column)
+	if it.File != "" {
+		s += " of " + it.File
+	}
+	return s
+}
+
+//位置记录在元素的属性中（随元素释放），命名空间避免与mapper中的属性冲突
+const positionAttr = "gomybatis:_position"
+
+//记录元素在xml中的位置，加载xml时写入，用于错误提示
+func SetPosition(element *etree.Element, position Position) {
+	element.CreateAttr(positionAttr, strconv.Itoa(position.Line)+":"+strconv.Itoa(position.Column)+":"+position.File)
+}
+
+//是否为记录位置的属性（不属于mapper中定义的属性）
+func IsPositionAttr(attr etree.Attr) bool {
+	return attr.Space+":"+attr.Key == positionAttr
+}
+
+//获取元素在xml中的位置，模板等动态生成的元素没有位置
+func PositionOf(element *etree.Element) (Position, bool) {
+	var attr = element.SelectAttr(positionAttr)
+	if attr == nil {
+		return Position{}, false
+	}
+	var items = strings.SplitN(attr.Value, ":", 3)
+	if len(items) != 3 {
+		return Position{}, false
+	}
+	var line, _ = strconv.Atoi(items[0])
+	var column, _ = strconv.Atoi(items[1])
+	return Position{File: items[2], Line: line, Column: column}, true
+}
@@ -0,0 +1,60 @@
+package ast
+
+import (
+	"sync"
+
+	"github.com/zhuxiujia/GoMybatis/lib/github.com/beevik/etree"
+)
+
+//自定义元素的节点工厂。element:xml元素，childs:已解析的子节点，holder:表达式引擎与参数类型转换
+type NodeFactory func(element *etree.Element, childs []Node, holder *NodeConfigHolder) (Node, error)
+
+//内置元素，不允许注册覆盖
+var builtinElements = map[string]bool{
+	"if": true, "trim": true, "set": true, "foreach": true, "choose": true,
+	"when": true, "otherwise": true, "where": true, "bind": true, "include": true,
+	"orderBy": true, "#criteria": true, "#databaseIdMismatch": true,
+}
+
+//由GoMybatis包在init中注册的内置元素，只允许注册一次，之后不允许覆盖、移除
+var packageElements = map[string]bool{
+	"orderBy": true, "#criteria": true, "#databaseIdMismatch": true,
+}
+
+var nodeFactoryMap = map[string]NodeFactory{}
+var nodeFactoryMutex sync.RWMutex
+
+//注册自定义元素，例如 <tenant/>、<dataScope/>。需在加载mapper xml之前注册，同名则覆盖
+func RegisterNode(tag string, factory NodeFactory) {
+	if tag == "" || factory == nil {
+		panic("[GoMybatis] RegisterNode() tag and factory can not be empty!")
+	}
+	nodeFactoryMutex.Lock()
+	defer nodeFactoryMutex.Unlock()
+	if builtinElements[tag] && (!packageElements[tag] || nodeFactoryMap[tag] != nil) {
+		panic("[GoMybatis] RegisterNode() can not override builtin element <" + tag + ">!")
+	}
+	nodeFactoryMap[tag] = factory
+}
+
+//移除自定义元素
+func UnregisterNode(tag string) {
+	if builtinElements[tag] {
+		panic("[GoMybatis] UnregisterNode() can not remove builtin element <" + tag + ">!")
+	}
+	nodeFactoryMutex.Lock()
+	defer nodeFactoryMutex.Unlock()
+	delete(nodeFactoryMap, tag)
+}
+
+//是否内置元素
+func IsBuiltinElement(tag string) bool {
+	return builtinElements[tag]
+}
+
+//获取自定义元素的节点工厂，未注册返回nil
+func GetNodeFactory(tag string) NodeFactory {
+	nodeFactoryMutex.RLock()
+	defer nodeFactoryMutex.RUnlock()
+	return nodeFactoryMap[tag]
+}
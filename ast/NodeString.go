@@ -7,6 +7,7 @@ type NodeString struct {
 
 	//args
 	expressMap          []string //去重的，需要替换的express 例如 map[ #{} ]interface
+	expressOptions      []string //与expressMap对应的 #{express,options} 选项，都没有选项时为nil
 	noConvertExpressMap []string //去重的，需要替换的express 例如 map[ ${} ]interface
 
 	holder *NodeConfigHolder
@@ -23,13 +24,13 @@ func (it *NodeString) Eval(env map[string]interface{}, arg_array *[]interface{})
 	var data = it.value
 	var err error
 	if it.expressMap != nil {
-		data, err = Replace(it.expressMap, data, it.holder.Convert, env, it.holder.GetExpressionEngineProxy(), arg_array)
+		data, err = ReplaceRedact(it.expressMap, it.expressOptions, data, it.holder.Convert, env, it.holder.GetExpressionEngineProxy(), it.holder.GetArgRedactor(), arg_array)
 		if err != nil {
 			return nil, err
 		}
 	}
 	if it.noConvertExpressMap != nil {
-		data, err = ReplaceRaw(it.noConvertExpressMap, data, nil, env, it.holder.GetExpressionEngineProxy())
+		data, err = ReplaceRawGuard(it.noConvertExpressMap, data, nil, env, it.holder.GetExpressionEngineProxy(), it.holder.GetRawArgGuard())
 		if err != nil {
 			return nil, err
 		}
@@ -13,6 +13,7 @@ const (
 	NWhen
 	NBind
 	NInclude
+	NCustom //自定义元素节点，见 RegisterNode
 )
 
 func (it NodeType) ToString() string {
@@ -35,6 +36,8 @@ func (it NodeType) ToString() string {
 		return "NBind"
 	case NInclude:
 		return "NInclude"
+	case NCustom:
+		return "NCustom"
 	}
 	return "Unknow"
 }
@@ -20,9 +20,13 @@ func (it *NodeWhen) Eval(env map[string]interface{}, arg_array *[]interface{}) (
 	}
 	var result, err = it.holder.GetExpressionEngineProxy().LexerAndEval(it.test, env)
 	if err != nil {
-		err = utils.NewError("GoMybatisSqlBuilder", "[GoMybatis] <test `", it.test, `> fail,`, err.Error())
+		return nil, utils.NewError("GoMybatisSqlBuilder", "[GoMybatis] <test `", it.test, `> fail,`, err.Error())
 	}
-	if result.(bool) {
+	var ok, isBool = result.(bool)
+	if !isBool {
+		return nil, utils.NewError("GoMybatisSqlBuilder", "[GoMybatis] <test `", it.test, "`> result must be a bool!")
+	}
+	if ok {
 		return DoChildNodes(it.childs, env, arg_array)
 	}
 	return nil, nil
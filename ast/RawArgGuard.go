@@ -0,0 +1,7 @@
+package ast
+
+//${} 原样替换参数的检查器，用于防止sql注入
+type RawArgGuard interface {
+	//检查 ${expression} 的值，返回替换到sql中的文本，不允许的值返回错误。env为sql构建参数（含 _databaseId）
+	Guard(expression string, value interface{}, env map[string]interface{}) (string, error)
+}
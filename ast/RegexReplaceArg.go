@@ -13,23 +13,36 @@ const (
 
 //执行替换操作
 func Replace(findStrs []string, data string, typeConvert SqlArgTypeConvert, arg map[string]interface{}, engine ExpressionEngine, arg_array *[]interface{}) (string, error) {
-	for _, findStr := range findStrs {
+	return ReplaceRedact(findStrs, nil, data, typeConvert, arg, engine, nil, arg_array)
+}
 
+//执行替换操作，options为与findStrs对应的选项（见 FindExpressOptions，可为nil），redactor不为nil时需要脱敏的参数以 RedactedArg 加入arg_array
+func ReplaceRedact(findStrs []string, options []string, data string, typeConvert SqlArgTypeConvert, arg map[string]interface{}, engine ExpressionEngine, redactor ArgRedactor, arg_array *[]interface{}) (string, error) {
+	for i, findStr := range findStrs {
+		var option = ""
+		if options != nil {
+			option = options[i]
+		}
 		//find param arg
 		var argValue = arg[findStr]
-		if argValue != nil {
-			*arg_array = append(*arg_array, argValue)
-		} else {
+		if argValue == nil {
 			//exec lexer
 			var err error
-			evalData, err := engine.LexerAndEval(findStr, arg)
+			argValue, err = engine.LexerAndEval(findStr, arg)
 			if err != nil {
 				return "", errors.New(engine.Name() + ":" + err.Error())
 			}
-			*arg_array = append(*arg_array, evalData)
 		}
+		if redactor != nil && redactor.Redact(findStr, option, arg) {
+			argValue = RedactedArg{Arg: argValue}
+		}
+		*arg_array = append(*arg_array, argValue)
 		//replace to ' ? '
-		data = strings.Replace(data, "#{"+findStr+"}", SQLPlaceholder, -1)
+		if option == "" {
+			data = strings.Replace(data, "#{"+findStr+"}", SQLPlaceholder, -1)
+		} else {
+			data = strings.Replace(data, "#{"+findStr+","+option+"}", SQLPlaceholder, -1)
+		}
 	}
 
 	return data, nil
@@ -37,6 +50,11 @@ func Replace(findStrs []string, data string, typeConvert SqlArgTypeConvert, arg
 
 //执行替换操作
 func ReplaceRaw(findStrs []string, data string, typeConvert SqlArgTypeConvert, arg map[string]interface{}, engine ExpressionEngine) (string, error) {
+	return ReplaceRawGuard(findStrs, data, typeConvert, arg, engine, nil)
+}
+
+//执行替换操作，guard不为nil时由guard检查值并转换为sql文本
+func ReplaceRawGuard(findStrs []string, data string, typeConvert SqlArgTypeConvert, arg map[string]interface{}, engine ExpressionEngine, guard RawArgGuard) (string, error) {
 	for _, findStr := range findStrs {
 		var evalData interface{}
 		//find param arg
@@ -52,7 +70,13 @@ func ReplaceRaw(findStrs []string, data string, typeConvert SqlArgTypeConvert, a
 			}
 		}
 		var resultStr string
-		if typeConvert != nil {
+		if guard != nil {
+			var err error
+			resultStr, err = guard.Guard(findStr, evalData, arg)
+			if err != nil {
+				return "", err
+			}
+		} else if typeConvert != nil {
 			resultStr = typeConvert.Convert(evalData)
 		} else {
 			resultStr = fmt.Sprint(evalData)
@@ -66,6 +90,36 @@ func ReplaceRaw(findStrs []string, data string, typeConvert SqlArgTypeConvert, a
 
 //find like #{*} value *
 func FindExpress(str string) []string {
+	var finds = findExpressItems(str)
+	for i, item := range finds {
+		//去掉逗号之后的部分
+		if index := strings.Index(item, ","); index != -1 {
+			finds[i] = item[:index]
+		}
+	}
+	return finds
+}
+
+//#{express,options} 的options，与 FindExpress 一一对应，都没有选项时返回nil
+func FindExpressOptions(str string) []string {
+	var finds = findExpressItems(str)
+	var haveOption = false
+	for i, item := range finds {
+		if index := strings.Index(item, ","); index != -1 {
+			finds[i] = item[index+1:]
+			haveOption = true
+		} else {
+			finds[i] = ""
+		}
+	}
+	if !haveOption {
+		return nil
+	}
+	return finds
+}
+
+//find like #{*} value *（包含逗号之后的部分）
+func findExpressItems(str string) []string {
 	var finds = []string{}
 	var item []byte
 	var lastIndex = -1
@@ -80,11 +134,6 @@ func FindExpress(str string) []string {
 		}
 		if v == 125 && startIndex != -1 {
 			item = strBytes[startIndex:index]
-
-			//去掉逗号之后的部分
-			if bytes.Contains(item, []byte(",")) {
-				item = bytes.Split(item, []byte(","))[0]
-			}
 			finds = append(finds, string(item))
 			item = nil
 			startIndex = -1
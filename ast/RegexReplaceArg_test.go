@@ -43,3 +43,14 @@ func TestFindRawExpressString(t *testing.T) {
 		}
 	}
 }
+
+func TestFindExpressOptions(t *testing.T) {
+	var str = "#{name1}#{password,redact=true}"
+	var names, options = FindExpress(str), FindExpressOptions(str)
+	if !(names[1] == "password" && options[0] == "" && options[1] == "redact=true") {
+		t.Fatal("FindExpressOptions fail:", names, options)
+	}
+	if FindExpressOptions("#{name1}#{name2}") != nil {
+		t.Fatal("FindExpressOptions without option must return nil")
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"crypto/rand"
+	"time"
+)
+
+//Crockford base32 字符表
+const ulidEncoding = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+//创建ULID，48位毫秒时间戳+80位随机数，按Crockford base32编码为26位字符串（字典序与生成时间一致）
+func CreateULID() string {
+	var id [16]byte
+	var ms = uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+	if _, err := rand.Read(id[6:]); err != nil {
+		panic(NewError("ULID", "read random bytes fail:", err.Error()))
+	}
+	return encodeULID(id)
+}
+
+func encodeULID(id [16]byte) string {
+	var dst = make([]byte, 26)
+	//10 byte timestamp
+	dst[0] = ulidEncoding[(id[0]&224)>>5]
+	dst[1] = ulidEncoding[id[0]&31]
+	dst[2] = ulidEncoding[(id[1]&248)>>3]
+	dst[3] = ulidEncoding[((id[1]&7)<<2)|((id[2]&192)>>6)]
+	dst[4] = ulidEncoding[(id[2]&62)>>1]
+	dst[5] = ulidEncoding[((id[2]&1)<<4)|((id[3]&240)>>4)]
+	dst[6] = ulidEncoding[((id[3]&15)<<1)|((id[4]&128)>>7)]
+	dst[7] = ulidEncoding[(id[4]&124)>>2]
+	dst[8] = ulidEncoding[((id[4]&3)<<3)|((id[5]&224)>>5)]
+	dst[9] = ulidEncoding[id[5]&31]
+	//16 byte entropy
+	dst[10] = ulidEncoding[(id[6]&248)>>3]
+	dst[11] = ulidEncoding[((id[6]&7)<<2)|((id[7]&192)>>6)]
+	dst[12] = ulidEncoding[(id[7]&62)>>1]
+	dst[13] = ulidEncoding[((id[7]&1)<<4)|((id[8]&240)>>4)]
+	dst[14] = ulidEncoding[((id[8]&15)<<1)|((id[9]&128)>>7)]
+	dst[15] = ulidEncoding[(id[9]&124)>>2]
+	dst[16] = ulidEncoding[((id[9]&3)<<3)|((id[10]&224)>>5)]
+	dst[17] = ulidEncoding[id[10]&31]
+	dst[18] = ulidEncoding[(id[11]&248)>>3]
+	dst[19] = ulidEncoding[((id[11]&7)<<2)|((id[12]&192)>>6)]
+	dst[20] = ulidEncoding[(id[12]&62)>>1]
+	dst[21] = ulidEncoding[((id[12]&1)<<4)|((id[13]&240)>>4)]
+	dst[22] = ulidEncoding[((id[13]&15)<<1)|((id[14]&128)>>7)]
+	dst[23] = ulidEncoding[(id[14]&124)>>2]
+	dst[24] = ulidEncoding[((id[14]&3)<<3)|((id[15]&224)>>5)]
+	dst[25] = ulidEncoding[id[15]&31]
+	return string(dst)
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestCreateULID(t *testing.T) {
+	var id = CreateULID()
+	if len(id) != 26 {
+		t.Fatal("CreateULID fail,len =", len(id))
+	}
+	if CreateULID() == id {
+		t.Fatal("CreateULID fail,repeat id")
+	}
+}
+
+func TestEncodeULID(t *testing.T) {
+	var id [16]byte
+	for i := range id {
+		id[i] = 0xff
+	}
+	if encodeULID(id) != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Fatal("encodeULID fail:", encodeULID(id))
+	}
+	if encodeULID([16]byte{}) != "00000000000000000000000000" {
+		t.Fatal("encodeULID fail:", encodeULID([16]byte{}))
+	}
+}